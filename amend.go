@@ -0,0 +1,31 @@
+package orderbook
+
+import (
+	"github.com/shopspring/decimal"
+)
+
+// Amendment is a change of order rate and volumes, nil fields are not changed
+type Amendment struct {
+	Rate      *decimal.Decimal `json:"rate,omitempty"`
+	MaxVolume *decimal.Decimal `json:"max_volume,omitempty"`
+	MinVolume *decimal.Decimal `json:"min_volume,omitempty"`
+}
+
+// Apply returning amended order and true if it loses time priority.
+// Priority is lost when rate is changed or max volume is increased, decreasing volumes keeps it.
+func (a Amendment) Apply(order Order) (Order, bool) {
+	losesPriority := false
+	if a.Rate != nil && !a.Rate.Equal(order.Rate) {
+		order.Rate = *a.Rate
+		losesPriority = true
+	}
+	if a.MaxVolume != nil {
+		losesPriority = losesPriority || a.MaxVolume.GreaterThan(order.MaxVolume)
+		order.MaxVolume = *a.MaxVolume
+	}
+	if a.MinVolume != nil {
+		order.MinVolume = *a.MinVolume
+	}
+
+	return order, losesPriority
+}
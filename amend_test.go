@@ -0,0 +1,46 @@
+package orderbook
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func decimalPtr(value string) *decimal.Decimal {
+	d := decimal.RequireFromString(value)
+	return &d
+}
+
+func TestAmendmentApply(t *testing.T) {
+	order := makerOrder("1", "2", "10", "1")
+
+	tests := []struct {
+		name          string
+		amendment     Amendment
+		losesPriority bool
+	}{
+		{"nothing", Amendment{}, false},
+		{"same rate", Amendment{Rate: decimalPtr("2")}, false},
+		{"rate", Amendment{Rate: decimalPtr("3")}, true},
+		{"decreased max volume", Amendment{MaxVolume: decimalPtr("5")}, false},
+		{"increased max volume", Amendment{MaxVolume: decimalPtr("15")}, true},
+		{"min volume", Amendment{MinVolume: decimalPtr("2")}, false},
+	}
+
+	for _, test := range tests {
+		amended, losesPriority := test.amendment.Apply(order)
+		if losesPriority != test.losesPriority {
+			t.Errorf("%s: expected loss of priority %v, got %v", test.name, test.losesPriority, losesPriority)
+		}
+		if test.amendment.Rate != nil && !amended.Rate.Equal(*test.amendment.Rate) ||
+			test.amendment.MaxVolume != nil && !amended.MaxVolume.Equal(*test.amendment.MaxVolume) ||
+			test.amendment.MinVolume != nil && !amended.MinVolume.Equal(*test.amendment.MinVolume) {
+			t.Errorf("%s: amendment is not applied to %v", test.name, amended)
+		}
+	}
+
+	// order is passed by value and isn't changed
+	if !order.Rate.Equal(decimal.NewFromInt(2)) || !order.MaxVolume.Equal(decimal.NewFromInt(10)) {
+		t.Errorf("expected order not to change, got %v", order)
+	}
+}
@@ -0,0 +1,24 @@
+package orderbook
+
+import (
+	"time"
+)
+
+// Clock is a source of time for order expiry, it may be replaced in tests
+type Clock interface {
+	// Now returns current time
+	Now() time.Time
+	// After returns channel receiving current time after duration d
+	After(d time.Duration) <-chan time.Time
+}
+
+// SystemClock is a Clock using time package
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (SystemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
@@ -0,0 +1,43 @@
+package orderbook
+
+import (
+	"fmt"
+
+	"github.com/shopspring/decimal"
+)
+
+// DepthLevel is a group of orders with rates in [Rate, Rate+tick)
+type DepthLevel struct {
+	Rate decimal.Decimal `json:"rate"`
+	// Volume is a sum of MaxVolume of level orders
+	Volume decimal.Decimal `json:"volume"`
+	Orders int             `json:"orders"`
+}
+
+// Depth is a ladder of rate levels of both pair directions, levels are sorted by best (max) rate first
+type Depth struct {
+	TokenBid string `json:"token_bid"`
+	TokenAsk string `json:"token_ask"`
+	// Levels are levels of orders tokenBid_tokenAsk
+	Levels []DepthLevel `json:"levels"`
+	// ReverseLevels are levels of orders tokenAsk_tokenBid
+	ReverseLevels []DepthLevel `json:"reverse_levels"`
+}
+
+// CheckDepth checking tick of GetDepth, returns ErrInvalidQuery
+func CheckDepth(tick decimal.Decimal) error {
+	if tick.IsNegative() {
+		return fmt.Errorf("%w: tick must not be negative", ErrInvalidQuery)
+	}
+
+	return nil
+}
+
+// LevelRate returning rate of level containing rate, it is rate rounded down to multiple of tick, zero tick doesn't round
+func LevelRate(rate, tick decimal.Decimal) decimal.Decimal {
+	if tick.IsZero() {
+		return rate
+	}
+
+	return rate.Div(tick).Floor().Mul(tick)
+}
@@ -0,0 +1,35 @@
+package orderbook
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestLevelRate(t *testing.T) {
+	tests := []struct {
+		rate, tick, level string
+	}{
+		{"2.37", "0", "2.37"},
+		{"2.37", "0.5", "2"},
+		{"2.5", "0.5", "2.5"},
+		{"2.37", "0.01", "2.37"},
+		{"2.37", "1", "2"},
+		{"0.3", "0.5", "0"},
+	}
+
+	for _, test := range tests {
+		level := LevelRate(decimal.RequireFromString(test.rate), decimal.RequireFromString(test.tick))
+		requireDecimal(t, "level of rate "+test.rate+" with tick "+test.tick, level, test.level)
+	}
+}
+
+func TestCheckDepth(t *testing.T) {
+	if err := CheckDepth(decimal.Zero); err != nil {
+		t.Fatalf("expected zero tick to be valid, got %v", err)
+	}
+	if err := CheckDepth(decimal.NewFromInt(-1)); !errors.Is(err, ErrInvalidQuery) {
+		t.Fatalf("expected ErrInvalidQuery, got %v", err)
+	}
+}
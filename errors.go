@@ -0,0 +1,35 @@
+package orderbook
+
+import (
+	"errors"
+)
+
+// Errors returned by every OrderBook implementation, they may be wrapped, so check them with errors.Is
+var (
+	// ErrOrderNotFound is returned when order with given id or pair doesn't exist
+	ErrOrderNotFound = errors.New("order not found")
+	// ErrPairNotFound is returned when pair doesn't exist
+	ErrPairNotFound = errors.New("pair not found")
+	// ErrPairExists is returned when adding pair which already exists
+	ErrPairExists = errors.New("pair already exists")
+	// ErrInvalidPair is returned when pair tokens, sizes or status are invalid
+	ErrInvalidPair = errors.New("invalid pair")
+	// ErrPairHalted is returned when adding order to halted pair
+	ErrPairHalted = errors.New("pair is halted")
+	// ErrDuplicateOrder is returned when adding order with id which already exists
+	ErrDuplicateOrder = errors.New("order already exists")
+	// ErrInvalidOrder is returned when order can't be added or matched
+	ErrInvalidOrder = errors.New("invalid order")
+	// ErrInvalidFill is returned when filled volume is out of order volumes
+	ErrInvalidFill = errors.New("invalid fill volume")
+	// ErrTradeNotFound is returned when trade with given id doesn't exist
+	ErrTradeNotFound = errors.New("trade not found")
+	// ErrInvalidCursor is returned when cursor is not returned by list
+	ErrInvalidCursor = errors.New("invalid cursor")
+	// ErrInvalidQuery is returned when query of orders is invalid
+	ErrInvalidQuery = errors.New("invalid query")
+	// ErrNoRoute is returned by Router when there is no route between tokens filling the amount
+	ErrNoRoute = errors.New("no route")
+	// ErrInvalidSnapshot is returned by Export and Import when snapshot format or rows are invalid
+	ErrInvalidSnapshot = errors.New("invalid snapshot")
+)
@@ -0,0 +1,272 @@
+package orderbook
+
+import (
+	"sync"
+	"time"
+)
+
+// EventType is a type of book change
+type EventType string
+
+const (
+	// EventPairAdded pair is added by AddNewPair
+	EventPairAdded EventType = "pair_added"
+	// EventPairUpdated pair metadata or status is changed by UpdatePair
+	EventPairUpdated EventType = "pair_updated"
+	// EventPairRemoved pair is removed by RemovePair, OrderRemoved events of its orders go before it
+	EventPairRemoved EventType = "pair_removed"
+	// EventOrderAdded order is added by AddOrder
+	EventOrderAdded EventType = "order_added"
+	// EventOrderRemoved order is cancelled or expired
+	EventOrderRemoved EventType = "order_removed"
+	// EventOrderUpdated order is amended by AmendOrder
+	EventOrderUpdated EventType = "order_updated"
+	// EventOrderFilled order is filled by MatchOrder or FillOrder
+	EventOrderFilled EventType = "order_filled"
+)
+
+// DefaultEventBuffer is a buffer of subscription channel used when EventFilter.Buffer is not positive
+const DefaultEventBuffer = 64
+
+// Event is a change of book emitted after the change is committed
+type Event struct {
+	Type EventType `json:"type"`
+	// TokenBid and TokenAsk are tokens of pair or order
+	TokenBid string `json:"token_bid"`
+	TokenAsk string `json:"token_ask"`
+	// Seq is a number of event among events of pair of both directions, it grows by one without gaps
+	Seq uint64 `json:"seq"`
+	// Pair is set for pair events
+	Pair *Pair `json:"pair,omitempty"`
+	// Order is order after the change, it is set for order events
+	Order *Order `json:"order,omitempty"`
+	// Trade is set for EventOrderFilled
+	Trade *Trade    `json:"trade,omitempty"`
+	Time  time.Time `json:"time"`
+	// Missed is a number of events dropped for slow subscriber before this event
+	Missed uint64 `json:"missed,omitempty"`
+}
+
+// NewPairEvent returns event of pair at time
+func NewPairEvent(eventType EventType, pair Pair, at time.Time) Event {
+	return Event{Type: eventType, TokenBid: pair.TokenBid, TokenAsk: pair.TokenAsk, Pair: &pair, Time: at}
+}
+
+// NewOrderEvent returns event of order at time, trade is nil for events other than EventOrderFilled
+func NewOrderEvent(eventType EventType, order Order, trade *Trade, at time.Time) Event {
+	return Event{Type: eventType, TokenBid: order.TokenBid, TokenAsk: order.TokenAsk, Order: &order, Trade: trade, Time: at}
+}
+
+// EventFilter selects events of subscription and sets its slow consumer handling
+type EventFilter struct {
+	// TokenBid and TokenAsk select events of pair of both directions, empty tokens select every pair
+	TokenBid string `json:"token_bid,omitempty"`
+	TokenAsk string `json:"token_ask,omitempty"`
+	// Types select events of types, empty Types select every type
+	Types []EventType `json:"types,omitempty"`
+	// Buffer is a buffer of subscription channel, it is DefaultEventBuffer if not positive
+	Buffer int `json:"buffer,omitempty"`
+	// Block makes publishing wait until slow subscriber reads events, so book changes are slowed down by it.
+	// Otherwise events which don't fit in buffer are dropped and counted in Event.Missed of the next delivered event.
+	Block bool `json:"block,omitempty"`
+}
+
+// Match checks that event passes filter
+func (f EventFilter) Match(event Event) bool {
+	if f.TokenBid != "" && !(Pair{TokenBid: f.TokenBid, TokenAsk: f.TokenAsk}).Has(event.TokenBid, event.TokenAsk) {
+		return false
+	}
+	if len(f.Types) == 0 {
+		return true
+	}
+	for _, eventType := range f.Types {
+		if eventType == event.Type {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Subscription is a stream of events selected by filter
+type Subscription struct {
+	filter EventFilter
+	broker *Broker
+	events chan Event
+	done   chan struct{}
+	once   sync.Once
+
+	mu     sync.Mutex // guards sending to and closing of events
+	closed bool
+	missed uint64
+}
+
+// Events returns channel of events, it is closed by Unsubscribe
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Unsubscribe stopping subscription and closing its channel, it may be called many times
+func (s *Subscription) Unsubscribe() {
+	s.once.Do(func() {
+		close(s.done)
+		s.broker.remove(s)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.closed = true
+		close(s.events)
+	})
+}
+
+// deliver sending event to subscriber, blocks until it is read or subscription is stopped if filter Block is set
+func (s *Subscription) deliver(event Event) {
+	if !s.filter.Match(event) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return
+	}
+
+	event.Missed = s.missed
+	if s.filter.Block {
+		select {
+		case s.events <- event:
+			s.missed = 0
+		case <-s.done:
+		}
+		return
+	}
+
+	select {
+	case s.events <- event:
+		s.missed = 0
+	default:
+		s.missed++
+	}
+}
+
+// EventBatch is events of one committed change prepared for publishing
+type EventBatch struct {
+	ticket uint64
+	events []Event
+}
+
+// Broker publishes events of OrderBook implementation to subscriptions.
+// Events are numbered by Prepare and published in the same order by Publish, batches wait for previous ones.
+type Broker struct {
+	mu          sync.Mutex
+	turn        *sync.Cond
+	seqs        map[Pair]uint64 // last event numbers by pair tokens sorted with pairKey
+	tickets     uint64          // last prepared batch
+	published   uint64          // last published batch
+	subscribers map[*Subscription]struct{}
+}
+
+// NewBroker returns broker without subscriptions
+func NewBroker() *Broker {
+	b := &Broker{seqs: make(map[Pair]uint64), subscribers: make(map[*Subscription]struct{})}
+	b.turn = sync.NewCond(&b.mu)
+	return b
+}
+
+// Subscribe returns subscription of events selected by filter
+func (b *Broker) Subscribe(filter EventFilter) *Subscription {
+	buffer := filter.Buffer
+	if buffer <= 0 {
+		buffer = DefaultEventBuffer
+	}
+
+	s := &Subscription{filter: filter, broker: b, events: make(chan Event, buffer), done: make(chan struct{})}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscribers[s] = struct{}{}
+
+	return s
+}
+
+// Prepare numbering events of committed change, implementations call it while the change is still isolated,
+// so batches are numbered in order of changes
+func (b *Broker) Prepare(events ...Event) EventBatch {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for i := range events {
+		key := pairKey(events[i].TokenBid, events[i].TokenAsk)
+		b.seqs[key]++
+		events[i].Seq = b.seqs[key]
+	}
+	b.tickets++
+
+	return EventBatch{ticket: b.tickets, events: events}
+}
+
+// PrepareSequenced preparing events numbered by implementation, for example with sequences shared by database.
+// Implementations call it while sequences of the change are locked, so batches are published in order of sequences.
+// Nothing is prepared without events.
+func (b *Broker) PrepareSequenced(events ...Event) EventBatch {
+	if len(events) == 0 {
+		return EventBatch{}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tickets++
+	return EventBatch{ticket: b.tickets, events: events}
+}
+
+// Discard releasing prepared batch of change which is not committed, its events are not delivered
+func (b *Broker) Discard(batch EventBatch) {
+	b.Publish(EventBatch{ticket: batch.ticket})
+}
+
+// Publish delivering prepared batch to subscriptions after previous batches, zero batch is ignored.
+// Every prepared batch must be published or discarded, otherwise the next batches wait forever.
+func (b *Broker) Publish(batch EventBatch) {
+	if batch.ticket == 0 {
+		return
+	}
+
+	b.mu.Lock()
+	for b.published != batch.ticket-1 {
+		b.turn.Wait()
+	}
+	subscribers := make([]*Subscription, 0, len(b.subscribers))
+	for s := range b.subscribers {
+		subscribers = append(subscribers, s)
+	}
+	b.mu.Unlock()
+
+	for _, event := range batch.events {
+		for _, s := range subscribers {
+			s.deliver(event)
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.published = batch.ticket
+	b.turn.Broadcast()
+}
+
+// remove removing subscription from broker
+func (b *Broker) remove(s *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.subscribers, s)
+}
+
+// pairKey returning pair of tokens of both directions
+func pairKey(tokenBid, tokenAsk string) Pair {
+	if tokenAsk < tokenBid {
+		tokenBid, tokenAsk = tokenAsk, tokenBid
+	}
+
+	return Pair{TokenBid: tokenBid, TokenAsk: tokenAsk}
+}
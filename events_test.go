@@ -0,0 +1,187 @@
+package orderbook
+
+import (
+	"testing"
+	"time"
+)
+
+func pairEvent(tokenBid, tokenAsk string) Event {
+	return NewPairEvent(EventPairAdded, Pair{TokenBid: tokenBid, TokenAsk: tokenAsk}, time.Time{})
+}
+
+// receive reading event of subscription, test fails if there is no event
+func receive(t *testing.T, s *Subscription) Event {
+	t.Helper()
+
+	select {
+	case event := <-s.Events():
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("expected event")
+		return Event{}
+	}
+}
+
+// requireNoEvent checking that subscription has no events to read
+func requireNoEvent(t *testing.T, s *Subscription) {
+	t.Helper()
+
+	select {
+	case event := <-s.Events():
+		t.Fatalf("expected no event, got %v", event)
+	default:
+	}
+}
+
+func TestEventFilterMatch(t *testing.T) {
+	event := NewOrderEvent(EventOrderAdded, makerOrder("1", "2", "10", "1"), nil, time.Time{})
+
+	tests := []struct {
+		name   string
+		filter EventFilter
+		match  bool
+	}{
+		{"empty", EventFilter{}, true},
+		{"pair", EventFilter{TokenBid: "ETH", TokenAsk: "BTC"}, true},
+		{"reversed pair", EventFilter{TokenBid: "BTC", TokenAsk: "ETH"}, true},
+		{"other pair", EventFilter{TokenBid: "BTC", TokenAsk: "USDT"}, false},
+		{"type", EventFilter{Types: []EventType{EventOrderFilled, EventOrderAdded}}, true},
+		{"other type", EventFilter{Types: []EventType{EventOrderFilled}}, false},
+	}
+
+	for _, test := range tests {
+		if got := test.filter.Match(event); got != test.match {
+			t.Errorf("%s: expected match %v, got %v", test.name, test.match, got)
+		}
+	}
+}
+
+func TestBrokerNumbersEventsByPair(t *testing.T) {
+	broker := NewBroker()
+	s := broker.Subscribe(EventFilter{})
+	defer s.Unsubscribe()
+
+	// events of both pair directions share seqs
+	broker.Publish(broker.Prepare(pairEvent("BTC", "ETH"), pairEvent("ETH", "BTC"), pairEvent("BTC", "USDT")))
+	broker.Publish(broker.Prepare(pairEvent("BTC", "ETH")))
+
+	for _, want := range []uint64{1, 2, 1, 3} {
+		if event := receive(t, s); event.Seq != want {
+			t.Fatalf("expected seq %d, got %v", want, event)
+		}
+	}
+}
+
+func TestBrokerPublishesBatchesInOrder(t *testing.T) {
+	broker := NewBroker()
+	s := broker.Subscribe(EventFilter{})
+	defer s.Unsubscribe()
+
+	first := broker.Prepare(pairEvent("BTC", "ETH"))
+	second := broker.Prepare(pairEvent("BTC", "ETH"))
+	published := make(chan struct{})
+	go func() {
+		broker.Publish(second)
+		close(published)
+	}()
+
+	// the second batch waits for the first one
+	select {
+	case <-published:
+		t.Fatal("expected second batch to wait for the first one")
+	case <-time.After(10 * time.Millisecond):
+	}
+	broker.Publish(first)
+	<-published
+
+	if event := receive(t, s); event.Seq != 1 {
+		t.Fatalf("expected event 1 first, got %v", event)
+	}
+	if event := receive(t, s); event.Seq != 2 {
+		t.Fatalf("expected event 2 second, got %v", event)
+	}
+}
+
+func TestBrokerDiscard(t *testing.T) {
+	broker := NewBroker()
+	s := broker.Subscribe(EventFilter{})
+	defer s.Unsubscribe()
+
+	discarded := broker.Prepare(pairEvent("BTC", "ETH"))
+	published := broker.Prepare(pairEvent("BTC", "USDT"))
+	broker.Discard(discarded)
+	broker.Publish(published)
+
+	if event := receive(t, s); event.TokenAsk != "USDT" {
+		t.Fatalf("expected event of published batch, got %v", event)
+	}
+	requireNoEvent(t, s)
+}
+
+func TestBrokerPrepareSequenced(t *testing.T) {
+	broker := NewBroker()
+	s := broker.Subscribe(EventFilter{})
+	defer s.Unsubscribe()
+
+	// zero batch is ignored and doesn't hold next batches
+	broker.Publish(broker.PrepareSequenced())
+
+	event := pairEvent("BTC", "ETH")
+	event.Seq = 7
+	broker.Publish(broker.PrepareSequenced(event))
+	if event := receive(t, s); event.Seq != 7 {
+		t.Fatalf("expected seq of implementation, got %v", event)
+	}
+}
+
+func TestSubscriptionFilter(t *testing.T) {
+	broker := NewBroker()
+	s := broker.Subscribe(EventFilter{TokenBid: "ETH", TokenAsk: "BTC"})
+	defer s.Unsubscribe()
+
+	broker.Publish(broker.Prepare(pairEvent("BTC", "USDT"), pairEvent("BTC", "ETH")))
+	if event := receive(t, s); event.TokenAsk != "ETH" {
+		t.Fatalf("expected event of pair, got %v", event)
+	}
+	requireNoEvent(t, s)
+}
+
+func TestSlowSubscriptionMissesEvents(t *testing.T) {
+	broker := NewBroker()
+	s := broker.Subscribe(EventFilter{Buffer: 1})
+	defer s.Unsubscribe()
+
+	broker.Publish(broker.Prepare(pairEvent("BTC", "ETH"), pairEvent("BTC", "ETH"), pairEvent("BTC", "ETH")))
+	if event := receive(t, s); event.Seq != 1 || event.Missed != 0 {
+		t.Fatalf("expected the first event, got %v", event)
+	}
+
+	broker.Publish(broker.Prepare(pairEvent("BTC", "ETH")))
+	if event := receive(t, s); event.Seq != 4 || event.Missed != 2 {
+		t.Fatalf("expected event 4 after 2 missed events, got %v", event)
+	}
+}
+
+func TestBlockingSubscription(t *testing.T) {
+	broker := NewBroker()
+	s := broker.Subscribe(EventFilter{Buffer: 1, Block: true})
+
+	published := make(chan struct{})
+	go func() {
+		broker.Publish(broker.Prepare(pairEvent("BTC", "ETH"), pairEvent("BTC", "ETH"), pairEvent("BTC", "ETH")))
+		close(published)
+	}()
+
+	for _, want := range []uint64{1, 2} {
+		if event := receive(t, s); event.Seq != want || event.Missed != 0 {
+			t.Fatalf("expected event %d without missed events, got %v", want, event)
+		}
+	}
+
+	// unsubscribing releases blocked publishing and closes channel
+	s.Unsubscribe()
+	<-published
+	for range s.Events() {
+	}
+	s.Unsubscribe()
+}
@@ -2,4 +2,9 @@ module github.com/SashaBokov/orderbook
 
 go 1.19
 
-require github.com/pkg/errors v0.9.1
+require (
+	github.com/google/btree v1.1.2
+	github.com/lib/pq v1.10.9
+	github.com/pkg/errors v0.9.1
+	github.com/shopspring/decimal v1.3.1
+)
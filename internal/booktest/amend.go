@@ -0,0 +1,67 @@
+package booktest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/SashaBokov/orderbook"
+	"github.com/shopspring/decimal"
+)
+
+func testAmendOrder(t *testing.T, newBook NewBook) {
+	book := newBook(t)
+	ctx := context.Background()
+	AddOrders(t, book, Order("1", 2, 10), Order("2", 2, 10))
+	amount := func(value int64) *decimal.Decimal {
+		d := decimal.NewFromInt(value)
+		return &d
+	}
+
+	first, err := book.GetOrderById(ctx, "1")
+	if err != nil {
+		t.Fatalf("getting order: %v", err)
+	}
+
+	// decreasing volume keeps priority, order 1 is still the first of equal rates
+	amended, err := book.AmendOrder(ctx, "1", orderbook.Amendment{MaxVolume: amount(5)})
+	if err != nil || amended.Priority != first.Priority || !amended.MaxVolume.Equal(decimal.NewFromInt(5)) {
+		t.Fatalf("expected order with 5 volume keeping priority %d, got %v, %v", first.Priority, amended, err)
+	}
+	if best, err := book.GetOrderWithMaxRate(ctx, "BTC", "ETH"); err != nil || best.Id != "1" {
+		t.Fatalf("expected order 1 with max rate, got %v, %v", best, err)
+	}
+
+	// increasing volume loses priority, so order 2 goes first
+	amended, err = book.AmendOrder(ctx, "1", orderbook.Amendment{MaxVolume: amount(20)})
+	if err != nil {
+		t.Fatalf("amending order: %v", err)
+	}
+	if order, err := book.GetOrderById(ctx, "1"); err != nil || order.Priority != amended.Priority || order.Priority <= first.Priority {
+		t.Fatalf("expected stored order losing priority %d, got %v, %v", first.Priority, order, err)
+	}
+	if best, err := book.GetOrderWithMaxRate(ctx, "BTC", "ETH"); err != nil || best.Id != "2" {
+		t.Fatalf("expected order 2 with max rate, got %v, %v", best, err)
+	}
+	if best, err := book.GetOrderWithMaxVolume(ctx, "BTC", "ETH"); err != nil || best.Id != "1" {
+		t.Fatalf("expected order 1 with max volume, got %v, %v", best, err)
+	}
+
+	// rate change is applied to rate index
+	if _, err := book.AmendOrder(ctx, "2", orderbook.Amendment{Rate: amount(1)}); err != nil {
+		t.Fatalf("amending order: %v", err)
+	}
+	if best, err := book.GetOrderWithMinRate(ctx, "BTC", "ETH"); err != nil || best.Id != "2" || !best.Rate.Equal(decimal.NewFromInt(1)) {
+		t.Fatalf("expected order 2 with min rate 1, got %v, %v", best, err)
+	}
+
+	if _, err := book.AmendOrder(ctx, "1", orderbook.Amendment{MinVolume: amount(30)}); !errors.Is(err, orderbook.ErrInvalidOrder) {
+		t.Fatalf("expected ErrInvalidOrder, got %v", err)
+	}
+	if order, err := book.GetOrderById(ctx, "1"); err != nil || !order.MinVolume.Equal(decimal.NewFromInt(1)) {
+		t.Fatalf("expected refused amendment not applied, got %v, %v", order, err)
+	}
+	if _, err := book.AmendOrder(ctx, "3", orderbook.Amendment{Rate: amount(3)}); !errors.Is(err, orderbook.ErrOrderNotFound) {
+		t.Fatalf("expected ErrOrderNotFound, got %v", err)
+	}
+}
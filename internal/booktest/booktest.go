@@ -0,0 +1,121 @@
+// Package booktest checks behaviour expected from every orderbook.OrderBook implementation,
+// repository packages run it from their tests with a constructor of empty books.
+package booktest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/SashaBokov/orderbook"
+	"github.com/shopspring/decimal"
+)
+
+// NewBook returns an empty book with opts, it is called by every test
+type NewBook func(t *testing.T, opts ...orderbook.Option) orderbook.OrderBook
+
+// test is a behaviour test run against books of NewBook
+type test struct {
+	name string
+	run  func(t *testing.T, newBook NewBook)
+}
+
+// tests are behaviour tests of every OrderBook implementation
+var tests = []test{
+	{"GetOrdersWithBestValues", testGetOrdersWithBestValues},
+	{"GetOrderById", testGetOrderById},
+	{"AddOrderErrors", testAddOrderErrors},
+	{"ListOrders", testListOrders},
+	{"ListOrderPages", testListOrderPages},
+	{"ListOrderPagesOfChangingBook", testListOrderPagesOfChangingBook},
+	{"RemoveOrder", testRemoveOrder},
+	{"RemovePairRemovesOrdersOfBothDirections", testRemovePairRemovesOrdersOfBothDirections},
+	{"MatchOrder", testMatchOrder},
+	{"MatchOrderByTimePriority", testMatchOrderByTimePriority},
+	{"MatchOrderKeepsExactDecimals", testMatchOrderKeepsExactDecimals},
+	{"FillOrder", testFillOrder},
+	{"AmendOrder", testAmendOrder},
+	{"CancelledContextLeavesBookUnchanged", testCancelledContextLeavesBookUnchanged},
+	{"SentinelErrors", testSentinelErrors},
+	{"ValidationErrors", testValidationErrors},
+	{"ValidatorOfOptions", testValidatorOfOptions},
+	{"PairRegistry", testPairRegistry},
+	{"HaltedPairRefusesOrders", testHaltedPairRefusesOrders},
+	{"ExpiredOrdersAreSkipped", testExpiredOrdersAreSkipped},
+	{"GetOrderHistory", testGetOrderHistory},
+	{"Trades", testTrades},
+	{"GetDepth", testGetDepth},
+	{"Quote", testQuote},
+	{"GetTopOfBook", testGetTopOfBook},
+	{"Subscribe", testSubscribe},
+	{"RemovePairEmitsOrderEvents", testRemovePairEmitsOrderEvents},
+	{"UpdatePairEmitsEvent", testUpdatePairEmitsEvent},
+}
+
+// Run running every behaviour test against books returned by newBook
+func Run(t *testing.T, newBook NewBook) {
+	for _, test := range tests {
+		run := test.run
+		t.Run(test.name, func(t *testing.T) {
+			run(t, newBook)
+		})
+	}
+}
+
+// Pair returns active BTC_ETH pair without sizes
+func Pair() orderbook.Pair {
+	return orderbook.Pair{TokenBid: "BTC", TokenAsk: "ETH"}
+}
+
+// Order returns an order of BTC_ETH pair of maker
+func Order(id string, rate, volume int64) orderbook.Order {
+	return orderbook.Order{
+		Id:        id,
+		MakerId:   "maker",
+		TokenBid:  "BTC",
+		TokenAsk:  "ETH",
+		Rate:      decimal.NewFromInt(rate),
+		MaxVolume: decimal.NewFromInt(volume),
+		MinVolume: decimal.NewFromInt(1),
+	}
+}
+
+// MakerOrder returns an order of ETH_BTC pair giving rate of ETH for every BTC of volume
+func MakerOrder(id string, rate, volume int64) orderbook.Order {
+	order := Order(id, rate, volume)
+	order.TokenBid, order.TokenAsk = "ETH", "BTC"
+	return order
+}
+
+// AddOrders adding BTC_ETH pair and orders to book in order of arguments
+func AddOrders(t testing.TB, book orderbook.OrderBook, orders ...orderbook.Order) {
+	t.Helper()
+	ctx := context.Background()
+
+	if err := book.AddNewPair(ctx, Pair()); err != nil {
+		t.Fatalf("adding pair: %v", err)
+	}
+	for _, order := range orders {
+		if err := book.AddOrder(ctx, order); err != nil {
+			t.Fatalf("adding order %s: %v", order.Id, err)
+		}
+	}
+}
+
+// RequireDecimal failing test if got isn't equal to decimal want
+func RequireDecimal(t *testing.T, name string, got decimal.Decimal, want string) {
+	t.Helper()
+
+	if !got.Equal(decimal.RequireFromString(want)) {
+		t.Fatalf("expected %s %s, got %s", name, want, got)
+	}
+}
+
+// OrderIds returns ids of orders
+func OrderIds(orders []orderbook.Order) []string {
+	ids := make([]string, 0, len(orders))
+	for _, order := range orders {
+		ids = append(ids, order.Id)
+	}
+
+	return ids
+}
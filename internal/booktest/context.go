@@ -0,0 +1,35 @@
+package booktest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/SashaBokov/orderbook"
+)
+
+func testCancelledContextLeavesBookUnchanged(t *testing.T, newBook NewBook) {
+	book := newBook(t)
+	AddOrders(t, book, Order("1", 2, 10))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := book.AddOrder(ctx, Order("2", 2, 10)); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled of adding order, got %v", err)
+	}
+	if err := book.RemoveOrder(ctx, "1"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled of removing order, got %v", err)
+	}
+	if _, err := book.GetOrderById(ctx, "1"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled of getting order, got %v", err)
+	}
+	if _, err := book.ListOrders(ctx, orderbook.Query{TokenBid: "BTC", TokenAsk: "ETH"}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled of listing orders, got %v", err)
+	}
+
+	page, err := book.ListOrders(context.Background(), orderbook.Query{TokenBid: "BTC", TokenAsk: "ETH"})
+	if ids := OrderIds(page.Orders); err != nil || len(ids) != 1 || ids[0] != "1" {
+		t.Fatalf("expected book with order 1 only, got %v, %v", ids, err)
+	}
+}
@@ -0,0 +1,73 @@
+package booktest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/SashaBokov/orderbook"
+	"github.com/shopspring/decimal"
+)
+
+// formatLevels returns rate, volume and number of orders of every level
+func formatLevels(levels []orderbook.DepthLevel) string {
+	formatted := make([]string, 0, len(levels))
+	for _, level := range levels {
+		formatted = append(formatted, fmt.Sprintf("%s:%s:%d", level.Rate, level.Volume, level.Orders))
+	}
+
+	return fmt.Sprint(formatted)
+}
+
+func testGetDepth(t *testing.T, newBook NewBook) {
+	book := newBook(t)
+	ctx := context.Background()
+	order := func(id, rate string, volume int64) orderbook.Order {
+		order := Order(id, 1, volume)
+		order.Rate = decimal.RequireFromString(rate)
+		return order
+	}
+	reversed := order("5", "0.4", 7)
+	reversed.TokenBid, reversed.TokenAsk = "ETH", "BTC"
+	AddOrders(t, book, order("1", "2.2", 10), order("2", "2.4", 5), order("3", "2.6", 1), order("4", "1.1", 3), reversed)
+
+	tests := []struct {
+		name    string
+		levels  int
+		tick    string
+		ladder  string
+		reverse string
+	}{
+		{"all levels", 0, "0.5", "[2.5:1:1 2:15:2 1:3:1]", "[0:7:1]"},
+		{"best levels", 2, "0.5", "[2.5:1:1 2:15:2]", "[0:7:1]"},
+		{"fractional tick", 0, "0.25", "[2.5:1:1 2.25:5:1 2:10:1 1:3:1]", "[0.25:7:1]"},
+		{"zero tick", 0, "0", "[2.6:1:1 2.4:5:1 2.2:10:1 1.1:3:1]", "[0.4:7:1]"},
+	}
+	for _, test := range tests {
+		depth, err := book.GetDepth(ctx, "BTC", "ETH", test.levels, decimal.RequireFromString(test.tick))
+		if err != nil {
+			t.Errorf("%s: getting depth: %v", test.name, err)
+			continue
+		}
+		if levels := formatLevels(depth.Levels); levels != test.ladder {
+			t.Errorf("%s: expected levels %s, got %s", test.name, test.ladder, levels)
+		}
+		if levels := formatLevels(depth.ReverseLevels); levels != test.reverse {
+			t.Errorf("%s: expected reverse levels %s, got %s", test.name, test.reverse, levels)
+		}
+	}
+
+	// reversed tokens swap levels
+	depth, err := book.GetDepth(ctx, "ETH", "BTC", 0, decimal.NewFromInt(1))
+	if err != nil || formatLevels(depth.Levels) != "[0:7:1]" || formatLevels(depth.ReverseLevels) != "[2:16:3 1:3:1]" {
+		t.Fatalf("expected swapped levels of reversed pair, got %v, %v", depth, err)
+	}
+
+	if _, err := book.GetDepth(ctx, "BTC", "USDT", 0, decimal.Zero); !errors.Is(err, orderbook.ErrPairNotFound) {
+		t.Fatalf("expected ErrPairNotFound, got %v", err)
+	}
+	if _, err := book.GetDepth(ctx, "BTC", "ETH", 0, decimal.NewFromInt(-1)); !errors.Is(err, orderbook.ErrInvalidQuery) {
+		t.Fatalf("expected ErrInvalidQuery, got %v", err)
+	}
+}
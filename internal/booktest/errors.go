@@ -0,0 +1,47 @@
+package booktest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/SashaBokov/orderbook"
+)
+
+func testSentinelErrors(t *testing.T, newBook NewBook) {
+	book := newBook(t)
+	ctx := context.Background()
+	AddOrders(t, book, Order("1", 2, 10))
+
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"adding existing pair", book.AddNewPair(ctx, Pair()), orderbook.ErrPairExists},
+		{"adding invalid pair", book.AddNewPair(ctx, orderbook.Pair{TokenBid: "BTC", TokenAsk: "BTC"}), orderbook.ErrInvalidPair},
+		{"adding order without id", book.AddOrder(ctx, Order("", 2, 10)), orderbook.ErrInvalidOrder},
+		{"removing missing order", book.RemoveOrder(ctx, "2"), orderbook.ErrOrderNotFound},
+		{"removing missing pair", book.RemovePair(ctx, "BTC", "USDT"), orderbook.ErrPairNotFound},
+	}
+	for _, test := range tests {
+		if !errors.Is(test.err, test.want) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.want, test.err)
+		}
+	}
+
+	if _, err := book.GetOrderWithMinVolume(ctx, "BTC", "USDT"); !errors.Is(err, orderbook.ErrPairNotFound) {
+		t.Fatalf("expected ErrPairNotFound of getting order of missing pair, got %v", err)
+	}
+	if _, err := book.ListOrders(ctx, orderbook.Query{TokenBid: "BTC", TokenAsk: "USDT", Sort: orderbook.SortByRate}); !errors.Is(err, orderbook.ErrPairNotFound) {
+		t.Fatalf("expected ErrPairNotFound of listing orders of missing pair, got %v", err)
+	}
+
+	// empty lists aren't errors
+	if err := book.RemoveOrder(ctx, "1"); err != nil {
+		t.Fatalf("removing order: %v", err)
+	}
+	if page, err := book.ListOrders(ctx, orderbook.Query{TokenBid: "BTC", TokenAsk: "ETH"}); err != nil || len(page.Orders) != 0 || page.NextCursor != "" {
+		t.Fatalf("expected empty list, got %v, %v", page, err)
+	}
+}
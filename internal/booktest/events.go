@@ -0,0 +1,97 @@
+package booktest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/SashaBokov/orderbook"
+	"github.com/shopspring/decimal"
+)
+
+func testSubscribe(t *testing.T, newBook NewBook) {
+	book := newBook(t)
+	ctx := context.Background()
+	all := book.Subscribe(orderbook.EventFilter{})
+	defer all.Unsubscribe()
+	fills := book.Subscribe(orderbook.EventFilter{Types: []orderbook.EventType{orderbook.EventOrderFilled}})
+	defer fills.Unsubscribe()
+
+	if err := book.AddNewPair(ctx, Pair()); err != nil {
+		t.Fatalf("adding pair: %v", err)
+	}
+	if err := book.AddOrder(ctx, MakerOrder("1", 2, 10)); err != nil {
+		t.Fatalf("adding order: %v", err)
+	}
+	if _, err := book.FillOrder(ctx, "1", decimal.NewFromInt(4)); err != nil {
+		t.Fatalf("filling order: %v", err)
+	}
+	if err := book.RemoveOrder(ctx, "1"); err != nil {
+		t.Fatalf("removing order: %v", err)
+	}
+	// failed change emits nothing
+	if err := book.RemoveOrder(ctx, "1"); err == nil {
+		t.Fatal("expected error of removed order")
+	}
+
+	types := []orderbook.EventType{orderbook.EventPairAdded, orderbook.EventOrderAdded, orderbook.EventOrderFilled, orderbook.EventOrderRemoved}
+	for i, eventType := range types {
+		event := <-all.Events()
+		if event.Type != eventType || event.Seq != uint64(i+1) {
+			t.Fatalf("expected event %s with seq %d, got %v", eventType, i+1, event)
+		}
+	}
+	select {
+	case event := <-all.Events():
+		t.Fatalf("expected no more events, got %v", event)
+	default:
+	}
+
+	event := <-fills.Events()
+	if event.Type != orderbook.EventOrderFilled || event.Trade == nil || !event.Order.MaxVolume.Equal(decimal.NewFromInt(6)) {
+		t.Fatalf("expected fill event with trade and 6 left, got %v", event)
+	}
+}
+
+func testRemovePairEmitsOrderEvents(t *testing.T, newBook NewBook) {
+	book := newBook(t)
+	ctx := context.Background()
+	AddOrders(t, book, Order("1", 2, 10), MakerOrder("2", 2, 10))
+	s := book.Subscribe(orderbook.EventFilter{})
+	defer s.Unsubscribe()
+
+	if err := book.RemovePair(ctx, "BTC", "ETH"); err != nil {
+		t.Fatalf("removing pair: %v", err)
+	}
+
+	removed := make([]string, 0)
+	for i := 0; i < 3; i++ {
+		event := <-s.Events()
+		if event.Type == orderbook.EventOrderRemoved && event.Order.Status == orderbook.OrderCancelled {
+			removed = append(removed, event.Order.Id)
+		} else if i != 2 || event.Type != orderbook.EventPairRemoved {
+			t.Fatalf("expected order events before pair removal, got %v", event)
+		}
+	}
+	if len(removed) != 2 {
+		t.Fatalf("expected cancelled orders 1 and 2, got %v", removed)
+	}
+}
+
+func testUpdatePairEmitsEvent(t *testing.T, newBook NewBook) {
+	book := newBook(t)
+	ctx := context.Background()
+	AddOrders(t, book)
+	s := book.Subscribe(orderbook.EventFilter{Types: []orderbook.EventType{orderbook.EventPairUpdated}})
+	defer s.Unsubscribe()
+
+	halted := orderbook.Pair{TokenBid: "ETH", TokenAsk: "BTC", Status: orderbook.PairHalted}
+	if err := book.UpdatePair(ctx, halted); err != nil {
+		t.Fatalf("updating pair: %v", err)
+	}
+
+	// event has pair as registered, so its seq follows the pair addition
+	event := <-s.Events()
+	if event.Pair == nil || event.Pair.Status != orderbook.PairHalted || event.Pair.TokenBid != "BTC" || event.Seq != 2 {
+		t.Fatalf("expected update event of halted BTC_ETH pair with seq 2, got %v", event)
+	}
+}
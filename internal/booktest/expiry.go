@@ -0,0 +1,126 @@
+package booktest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SashaBokov/orderbook"
+	"github.com/shopspring/decimal"
+)
+
+// Clock is an orderbook.Clock moved by test, After returns channel receiving ticks sent to Ticks
+type Clock struct {
+	mu  sync.Mutex
+	now time.Time
+	// Ticks are returned by After
+	Ticks chan time.Time
+}
+
+func NewClock() *Clock {
+	return &Clock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Ticks: make(chan time.Time)}
+}
+
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *Clock) After(time.Duration) <-chan time.Time {
+	return c.Ticks
+}
+
+// Advance moving clock forward by d
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+func testExpiredOrdersAreSkipped(t *testing.T, newBook NewBook) {
+	clock := NewClock()
+	book := newBook(t, orderbook.WithClock(clock))
+	ctx := context.Background()
+
+	expiresAt := clock.Now().Add(time.Minute)
+	expiring := Order("1", 3, 10)
+	expiring.ExpiresAt = &expiresAt
+	expiringMaker := MakerOrder("3", 3, 10)
+	expiringMaker.ExpiresAt = &expiresAt
+	AddOrders(t, book, expiring, Order("2", 2, 10), expiringMaker, MakerOrder("4", 2, 10))
+
+	if best, err := book.GetOrderWithMaxRate(ctx, "BTC", "ETH"); err != nil || best.Id != "1" {
+		t.Fatalf("expected order 1 before expiry, got %v, %v", best, err)
+	}
+	if order, err := book.GetOrderById(ctx, "1"); err != nil || order.ExpiresAt == nil || !order.ExpiresAt.Equal(expiresAt) {
+		t.Fatalf("expected order 1 expiring at %v, got %v, %v", expiresAt, order, err)
+	}
+
+	// order expires at ExpiresAt
+	clock.Advance(time.Minute)
+
+	if _, err := book.GetOrderById(ctx, "1"); !errors.Is(err, orderbook.ErrOrderNotFound) {
+		t.Fatalf("expected expired order not returned, got %v", err)
+	}
+	if best, err := book.GetOrderWithMaxRate(ctx, "BTC", "ETH"); err != nil || best.Id != "2" {
+		t.Fatalf("expected order 2 after expiry, got %v, %v", best, err)
+	}
+
+	queries := []struct {
+		name  string
+		query orderbook.Query
+		ids   []string
+	}{
+		{"by pair", orderbook.Query{TokenBid: "BTC", TokenAsk: "ETH", Limit: 1}, []string{"2"}},
+		{"by maker", orderbook.Query{MakerId: "maker", Limit: 1}, []string{"2", "4"}},
+		{"max rate", orderbook.Query{TokenBid: "BTC", TokenAsk: "ETH", Sort: orderbook.SortByRate, Descending: true, Limit: 1}, []string{"2"}},
+		{"min volume", orderbook.Query{TokenBid: "BTC", TokenAsk: "ETH", Sort: orderbook.SortByMinVolume, Limit: 1}, []string{"2"}},
+	}
+	for _, test := range queries {
+		if ids := ListIds(t, book, test.query); fmt.Sprint(ids) != fmt.Sprint(test.ids) {
+			t.Errorf("%s: expected orders %v, got %v", test.name, test.ids, ids)
+		}
+	}
+
+	if _, err := book.FillOrder(ctx, "1", decimal.NewFromInt(1)); !errors.Is(err, orderbook.ErrOrderNotFound) {
+		t.Fatalf("expected expired order not filled, got %v", err)
+	}
+	rate := decimal.NewFromInt(1)
+	if _, err := book.AmendOrder(ctx, "1", orderbook.Amendment{Rate: &rate}); !errors.Is(err, orderbook.ErrOrderNotFound) {
+		t.Fatalf("expected expired order not amended, got %v", err)
+	}
+
+	// taker crossing both makers is matched with order 4 only
+	result, err := book.MatchOrder(ctx, Order("taker", 1, 10))
+	if err != nil || len(result.Fills) != 1 || result.Fills[0].Order.Id != "4" {
+		t.Fatalf("expected fill of order 4, got %v, %v", result, err)
+	}
+
+	expired := Order("5", 2, 10)
+	expired.ExpiresAt = &expiresAt
+	var validationErr *orderbook.ValidationError
+	if err := book.AddOrder(ctx, expired); !errors.As(err, &validationErr) || validationErr.Fields[0].Field != "expires_at" {
+		t.Fatalf("expected expires_at validation error, got %v", err)
+	}
+
+	removed, err := book.RemoveExpiredOrders(ctx)
+	if err != nil || removed != 2 {
+		t.Fatalf("expected 2 removed orders, got %d, %v", removed, err)
+	}
+	if removed, err := book.RemoveExpiredOrders(ctx); err != nil || removed != 0 {
+		t.Fatalf("expected nothing removed again, got %d, %v", removed, err)
+	}
+	if _, err := book.GetOrderById(ctx, "2"); err != nil {
+		t.Fatalf("expected order without expiry kept, got %v", err)
+	}
+	history, err := book.GetOrderHistory(ctx, "1")
+	if err != nil || history[len(history)-1].Status != orderbook.OrderExpired {
+		t.Fatalf("expected expired status in history, got %v, %v", history, err)
+	}
+}
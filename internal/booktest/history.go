@@ -0,0 +1,65 @@
+package booktest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/SashaBokov/orderbook"
+	"github.com/shopspring/decimal"
+)
+
+func testGetOrderHistory(t *testing.T, newBook NewBook) {
+	book := newBook(t)
+	ctx := context.Background()
+	AddOrders(t, book, Order("1", 2, 10), Order("2", 2, 10), Order("3", 2, 10))
+
+	if _, err := book.FillOrder(ctx, "1", decimal.NewFromInt(4)); err != nil {
+		t.Fatalf("filling order: %v", err)
+	}
+	if _, err := book.FillOrder(ctx, "1", decimal.NewFromInt(6)); err != nil {
+		t.Fatalf("filling order: %v", err)
+	}
+	if err := book.RemoveOrder(ctx, "2"); err != nil {
+		t.Fatalf("removing order: %v", err)
+	}
+	if err := book.RemoveOrder(ctx, "2"); !errors.Is(err, orderbook.ErrOrderNotFound) {
+		t.Fatalf("expected ErrOrderNotFound of cancelled order, got %v", err)
+	}
+	if err := book.RemovePair(ctx, "BTC", "ETH"); err != nil {
+		t.Fatalf("removing pair: %v", err)
+	}
+
+	tests := []struct {
+		id       string
+		statuses []orderbook.OrderStatus
+	}{
+		{"1", []orderbook.OrderStatus{orderbook.OrderOpen, orderbook.OrderPartiallyFilled, orderbook.OrderFilled}},
+		{"2", []orderbook.OrderStatus{orderbook.OrderOpen, orderbook.OrderCancelled}},
+		{"3", []orderbook.OrderStatus{orderbook.OrderOpen, orderbook.OrderCancelled}},
+	}
+	for _, test := range tests {
+		history, err := book.GetOrderHistory(ctx, test.id)
+		if err != nil {
+			t.Fatalf("getting history of order %s: %v", test.id, err)
+		}
+		if len(history) != len(test.statuses) {
+			t.Fatalf("expected %d status changes of order %s, got %v", len(test.statuses), test.id, history)
+		}
+		for i, status := range test.statuses {
+			if history[i].Status != status || history[i].OrderId != test.id {
+				t.Errorf("expected status %s of order %s at %d, got %v", status, test.id, i, history[i])
+			}
+		}
+	}
+
+	history, _ := book.GetOrderHistory(ctx, "1")
+	RequireDecimal(t, "partially filled max volume", history[1].MaxVolume, "6")
+
+	if _, err := book.GetOrderById(ctx, "3"); !errors.Is(err, orderbook.ErrOrderNotFound) {
+		t.Fatalf("expected orders of removed pair cancelled, got %v", err)
+	}
+	if _, err := book.GetOrderHistory(ctx, "4"); !errors.Is(err, orderbook.ErrOrderNotFound) {
+		t.Fatalf("expected ErrOrderNotFound, got %v", err)
+	}
+}
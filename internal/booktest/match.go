@@ -0,0 +1,124 @@
+package booktest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/SashaBokov/orderbook"
+	"github.com/shopspring/decimal"
+)
+
+func testMatchOrder(t *testing.T, newBook NewBook) {
+	book := newBook(t)
+	ctx := context.Background()
+	AddOrders(t, book, MakerOrder("1", 3, 5), MakerOrder("2", 2, 10), MakerOrder("3", 1, 10))
+
+	// taker gives 1 BTC for 2 ETH and gets 25 ETH: 15 ETH of order 1 and 10 ETH of order 2
+	taker := Order("taker", 1, 25)
+	taker.Rate = decimal.RequireFromString("0.5")
+	result, err := book.MatchOrder(ctx, taker)
+	if err != nil {
+		t.Fatalf("matching order: %v", err)
+	}
+	if len(result.Fills) != 2 || !result.Remainder.MaxVolume.IsZero() {
+		t.Fatalf("expected 2 fills without remainder, got %v", result)
+	}
+
+	if order, err := book.GetOrderById(ctx, "1"); !errors.Is(err, orderbook.ErrOrderNotFound) {
+		t.Fatalf("expected filled order 1 removed, got %v", order)
+	}
+	order, err := book.GetOrderById(ctx, "2")
+	if err != nil || !order.MaxVolume.Equal(decimal.NewFromInt(5)) {
+		t.Fatalf("expected order 2 with 5 BTC left, got %v, %v", order, err)
+	}
+	if best, err := book.GetOrderWithMaxRate(ctx, "ETH", "BTC"); err != nil || best.Id != "2" {
+		t.Fatalf("expected order 2 with max rate, got %v, %v", best, err)
+	}
+
+	// taker giving 0.1 BTC for ETH doesn't cross left orders
+	taker.Rate = decimal.RequireFromString("0.1")
+	result, err = book.MatchOrder(ctx, taker)
+	if err != nil || len(result.Fills) != 0 || !result.Remainder.MaxVolume.Equal(taker.MaxVolume) {
+		t.Fatalf("expected no fills, got %v, %v", result, err)
+	}
+
+	taker.TokenAsk = "USDT"
+	if _, err := book.MatchOrder(ctx, taker); !errors.Is(err, orderbook.ErrPairNotFound) {
+		t.Fatalf("expected ErrPairNotFound of taker of missing pair, got %v", err)
+	}
+}
+
+func testMatchOrderByTimePriority(t *testing.T, newBook NewBook) {
+	book := newBook(t)
+	ctx := context.Background()
+	AddOrders(t, book, MakerOrder("1", 2, 10), MakerOrder("2", 2, 10))
+
+	// orders with equal rates are matched in order of time priority
+	result, err := book.MatchOrder(ctx, Order("taker", 1, 10))
+	if err != nil || len(result.Fills) != 1 || result.Fills[0].Order.Id != "1" {
+		t.Fatalf("expected fill of order 1, got %v, %v", result, err)
+	}
+}
+
+func testMatchOrderKeepsExactDecimals(t *testing.T, newBook NewBook) {
+	book := newBook(t)
+	ctx := context.Background()
+	maker := MakerOrder("1", 1, 1)
+	maker.MaxVolume = decimal.RequireFromString("0.3")
+	maker.MinVolume = decimal.RequireFromString("0.1")
+	AddOrders(t, book, maker)
+
+	// two takers of 0.1 take 0.2 without rounding rest, so 0.1 is left exactly
+	taker := Order("taker", 1, 1)
+	taker.MaxVolume = decimal.RequireFromString("0.1")
+	taker.MinVolume = decimal.Zero
+	for i := 0; i < 2; i++ {
+		result, err := book.MatchOrder(ctx, taker)
+		if err != nil || len(result.Fills) != 1 {
+			t.Fatalf("expected fill %d, got %v, %v", i+1, result, err)
+		}
+	}
+
+	order, err := book.GetOrderById(ctx, "1")
+	if err != nil {
+		t.Fatalf("getting order: %v", err)
+	}
+	RequireDecimal(t, "rest", order.MaxVolume, "0.1")
+}
+
+func testFillOrder(t *testing.T, newBook NewBook) {
+	book := newBook(t)
+	ctx := context.Background()
+	order := Order("1", 2, 10)
+	order.MinVolume = decimal.NewFromInt(3)
+	AddOrders(t, book, order, Order("2", 2, 5))
+
+	filled, err := book.FillOrder(ctx, "1", decimal.NewFromInt(6))
+	if err != nil || !filled.MaxVolume.Equal(decimal.NewFromInt(4)) {
+		t.Fatalf("expected order with 4 left, got %v, %v", filled, err)
+	}
+	if order, err := book.GetOrderById(ctx, "1"); err != nil || !order.MaxVolume.Equal(decimal.NewFromInt(4)) {
+		t.Fatalf("expected stored order with 4 left, got %v, %v", order, err)
+	}
+	// volume index is updated, so order 2 has max volume now
+	if best, err := book.GetOrderWithMaxVolume(ctx, "BTC", "ETH"); err != nil || best.Id != "2" {
+		t.Fatalf("expected order 2 with max volume, got %v, %v", best, err)
+	}
+
+	if _, err := book.FillOrder(ctx, "1", decimal.NewFromInt(5)); !errors.Is(err, orderbook.ErrInvalidFill) {
+		t.Fatalf("expected ErrInvalidFill, got %v", err)
+	}
+
+	// rest of 1 is less than min volume, so order is closed
+	filled, err = book.FillOrder(ctx, "1", decimal.NewFromInt(3))
+	if err != nil || !filled.MaxVolume.Equal(decimal.NewFromInt(1)) {
+		t.Fatalf("expected filled order with 1 left, got %v, %v", filled, err)
+	}
+	if _, err := book.GetOrderById(ctx, "1"); !errors.Is(err, orderbook.ErrOrderNotFound) {
+		t.Fatalf("expected filled order removed, got %v", err)
+	}
+	if _, err := book.FillOrder(ctx, "1", decimal.NewFromInt(1)); !errors.Is(err, orderbook.ErrOrderNotFound) {
+		t.Fatalf("expected ErrOrderNotFound, got %v", err)
+	}
+}
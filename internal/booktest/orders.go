@@ -0,0 +1,177 @@
+package booktest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/SashaBokov/orderbook"
+	"github.com/shopspring/decimal"
+)
+
+func testGetOrdersWithBestValues(t *testing.T, newBook NewBook) {
+	book := newBook(t)
+	ctx := context.Background()
+	min := Order("1", 1, 30)
+	min.MinVolume = decimal.NewFromInt(5)
+	AddOrders(t, book, min, Order("2", 3, 10), Order("3", 3, 20), Order("4", 2, 40))
+
+	tests := []struct {
+		name string
+		get  func(ctx context.Context, tokenBid, tokenAsk string) (orderbook.Order, error)
+		id   string
+	}{
+		// orders with equal rates are ordered by time priority, the earliest of them is the best,
+		// orders with equal volumes are ordered by id, the last of them is max
+		{"max rate", book.GetOrderWithMaxRate, "2"},
+		{"min rate", book.GetOrderWithMinRate, "1"},
+		{"max volume", book.GetOrderWithMaxVolume, "4"},
+		{"min volume", book.GetOrderWithMinVolume, "2"},
+	}
+	for _, test := range tests {
+		order, err := test.get(ctx, "BTC", "ETH")
+		if err != nil || order.Id != test.id {
+			t.Errorf("%s: expected order %s, got %v, %v", test.name, test.id, order, err)
+		}
+	}
+
+	// orders of reversed direction are kept apart
+	if order, err := book.GetOrderWithMaxRate(ctx, "ETH", "BTC"); !errors.Is(err, orderbook.ErrOrderNotFound) {
+		t.Fatalf("expected no orders of reversed direction, got %v", order)
+	}
+}
+
+func testGetOrderById(t *testing.T, newBook NewBook) {
+	book := newBook(t)
+	ctx := context.Background()
+	expected := Order("1", 2, 10)
+	expected.Rate = decimal.RequireFromString("2.000000000000000001")
+	expected.MinVolume = decimal.RequireFromString("0.1")
+	AddOrders(t, book, expected)
+
+	// decimals are kept exactly
+	order, err := book.GetOrderById(ctx, "1")
+	if err != nil {
+		t.Fatalf("getting order: %v", err)
+	}
+	if order.Id != expected.Id || order.MakerId != expected.MakerId || order.TokenBid != expected.TokenBid || order.TokenAsk != expected.TokenAsk {
+		t.Fatalf("expected order %v, got %v", expected, order)
+	}
+	RequireDecimal(t, "rate", order.Rate, "2.000000000000000001")
+	RequireDecimal(t, "max volume", order.MaxVolume, "10")
+	RequireDecimal(t, "min volume", order.MinVolume, "0.1")
+
+	if order, err := book.GetOrderById(ctx, "2"); !errors.Is(err, orderbook.ErrOrderNotFound) {
+		t.Fatalf("expected no order 2, got %v", order)
+	}
+}
+
+func testAddOrderErrors(t *testing.T, newBook NewBook) {
+	book := newBook(t)
+	ctx := context.Background()
+	AddOrders(t, book, Order("1", 2, 10))
+
+	if err := book.AddOrder(ctx, Order("1", 3, 10)); !errors.Is(err, orderbook.ErrDuplicateOrder) {
+		t.Fatalf("expected ErrDuplicateOrder, got %v", err)
+	}
+
+	order := Order("2", 2, 10)
+	order.TokenAsk = "USDT"
+	if err := book.AddOrder(ctx, order); !errors.Is(err, orderbook.ErrPairNotFound) {
+		t.Fatalf("expected ErrPairNotFound of order of missing pair, got %v", err)
+	}
+
+	if order, err := book.GetOrderWithMaxRate(ctx, "BTC", "ETH"); err != nil || !order.Rate.Equal(decimal.NewFromInt(2)) {
+		t.Fatalf("expected the first order 1, got %v, %v", order, err)
+	}
+}
+
+func testListOrders(t *testing.T, newBook NewBook) {
+	book := newBook(t)
+	ctx := context.Background()
+	other := Order("6", 2, 15)
+	other.MakerId = "other"
+	addPagedOrders(t, book, other, MakerOrder("7", 2, 10))
+	amount := func(value int64) *decimal.Decimal {
+		d := decimal.NewFromInt(value)
+		return &d
+	}
+
+	tests := []struct {
+		name  string
+		query orderbook.Query
+		ids   string
+	}{
+		{"pair with min rate", orderbook.Query{TokenBid: "BTC", TokenAsk: "ETH", MinRate: amount(2), Sort: orderbook.SortByRate, Descending: true}, "[2 3 1 5 6]"},
+		{"pair with max rate", orderbook.Query{TokenBid: "BTC", TokenAsk: "ETH", MaxRate: amount(2), Sort: orderbook.SortByRate}, "[4 6 5 1]"},
+		{"pair with rate range", orderbook.Query{TokenBid: "BTC", TokenAsk: "ETH", MinRate: amount(2), MaxRate: amount(2), Sort: orderbook.SortByRate, Descending: true}, "[1 5 6]"},
+		{"pair with min volume", orderbook.Query{TokenBid: "BTC", TokenAsk: "ETH", MinVolume: amount(15), Sort: orderbook.SortByMaxVolume}, "[6 3 4 1]"},
+		{"pair with max volume", orderbook.Query{TokenBid: "BTC", TokenAsk: "ETH", MaxVolume: amount(20), Sort: orderbook.SortByMaxVolume, Descending: true}, "[4 3 6 5 2]"},
+		{"pair and maker", orderbook.Query{TokenBid: "BTC", TokenAsk: "ETH", MakerId: "other"}, "[6]"},
+		{"other pair direction", orderbook.Query{TokenBid: "ETH", TokenAsk: "BTC"}, "[7]"},
+		{"maker with min volume", orderbook.Query{MakerId: "maker", MinVolume: amount(20), Sort: orderbook.SortByMaxVolume}, "[3 4 1]"},
+		{"maker with max volume", orderbook.Query{MakerId: "maker", MaxVolume: amount(10)}, "[2 5 7]"},
+		{"rate and volume of all pairs", orderbook.Query{MinRate: amount(2), MaxVolume: amount(10)}, "[2 5 7]"},
+		{"unknown maker", orderbook.Query{MakerId: "nobody"}, "[]"},
+	}
+	for _, test := range tests {
+		page, err := book.ListOrders(ctx, test.query)
+		if err != nil {
+			t.Errorf("%s: listing orders: %v", test.name, err)
+			continue
+		}
+		if ids := fmt.Sprint(OrderIds(page.Orders)); ids != test.ids || page.NextCursor != "" {
+			t.Errorf("%s: expected orders %s without next page, got %s, %q", test.name, test.ids, ids, page.NextCursor)
+		}
+	}
+
+	if _, err := book.ListOrders(ctx, orderbook.Query{TokenBid: "BTC"}); !errors.Is(err, orderbook.ErrInvalidQuery) {
+		t.Fatalf("expected ErrInvalidQuery, got %v", err)
+	}
+	if _, err := book.ListOrders(ctx, orderbook.Query{TokenBid: "BTC", TokenAsk: "USDT"}); !errors.Is(err, orderbook.ErrPairNotFound) {
+		t.Fatalf("expected ErrPairNotFound, got %v", err)
+	}
+}
+
+func testRemoveOrder(t *testing.T, newBook NewBook) {
+	book := newBook(t)
+	ctx := context.Background()
+	AddOrders(t, book, Order("1", 2, 10), Order("2", 1, 10))
+
+	if err := book.RemoveOrder(ctx, "1"); err != nil {
+		t.Fatalf("removing order: %v", err)
+	}
+	if order, err := book.GetOrderById(ctx, "1"); !errors.Is(err, orderbook.ErrOrderNotFound) {
+		t.Fatalf("expected removed order to be missing, got %v", order)
+	}
+	if order, err := book.GetOrderWithMaxRate(ctx, "BTC", "ETH"); err != nil || order.Id != "2" {
+		t.Fatalf("expected order 2 with max rate, got %v, %v", order, err)
+	}
+	if page, err := book.ListOrders(ctx, orderbook.Query{MakerId: "maker"}); err != nil || len(page.Orders) != 1 {
+		t.Fatalf("expected a single order of maker, got %v, %v", page, err)
+	}
+}
+
+func testRemovePairRemovesOrdersOfBothDirections(t *testing.T, newBook NewBook) {
+	book := newBook(t)
+	ctx := context.Background()
+	reversed := Order("2", 1, 10)
+	reversed.TokenBid, reversed.TokenAsk = "ETH", "BTC"
+	AddOrders(t, book, Order("1", 2, 10), reversed)
+
+	if err := book.RemovePair(ctx, "BTC", "ETH"); err != nil {
+		t.Fatalf("removing pair: %v", err)
+	}
+	for _, id := range []string{"1", "2"} {
+		if order, err := book.GetOrderById(ctx, id); !errors.Is(err, orderbook.ErrOrderNotFound) {
+			t.Fatalf("expected order %s of removed pair to be missing, got %v", id, order)
+		}
+	}
+	if page, err := book.ListOrders(ctx, orderbook.Query{MakerId: "maker"}); err != nil || len(page.Orders) != 0 {
+		t.Fatalf("expected no orders of maker, got %v, %v", page, err)
+	}
+	if err := book.AddOrder(ctx, Order("3", 2, 10)); !errors.Is(err, orderbook.ErrPairNotFound) {
+		t.Fatalf("expected ErrPairNotFound of order of removed pair, got %v", err)
+	}
+}
@@ -0,0 +1,109 @@
+package booktest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/SashaBokov/orderbook"
+)
+
+// addPagedOrders adding BTC_ETH orders in order of ids, so priority of order is ascending with id
+func addPagedOrders(t *testing.T, book orderbook.OrderBook, orders ...orderbook.Order) {
+	t.Helper()
+
+	paged := []orderbook.Order{Order("1", 2, 30), Order("2", 3, 10), Order("3", 3, 20), Order("4", 1, 20), Order("5", 2, 10)}
+	AddOrders(t, book, append(paged, orders...)...)
+}
+
+// ListIds listing every page of query and returning ids of orders in order of pages
+func ListIds(t *testing.T, book orderbook.OrderBook, q orderbook.Query) []string {
+	t.Helper()
+
+	ids := make([]string, 0)
+	for {
+		page, err := book.ListOrders(context.Background(), q)
+		if err != nil {
+			t.Fatalf("listing orders: %v", err)
+		}
+		if len(page.Orders) > orderbook.PageLimit(q.Limit) {
+			t.Fatalf("expected at most %d orders in page, got %d", orderbook.PageLimit(q.Limit), len(page.Orders))
+		}
+		ids = append(ids, OrderIds(page.Orders)...)
+		if page.NextCursor == "" {
+			return ids
+		}
+		q.Cursor = page.NextCursor
+	}
+}
+
+func testListOrderPages(t *testing.T, newBook NewBook) {
+	book := newBook(t)
+	addPagedOrders(t, book)
+
+	tests := []struct {
+		sort       orderbook.SortKey
+		descending bool
+		ids        string
+	}{
+		{"", false, "[1 2 3 4 5]"},
+		{orderbook.SortById, true, "[5 4 3 2 1]"},
+		// orders with equal rate are sorted by priority in opposite direction
+		{orderbook.SortByRate, false, "[4 5 1 3 2]"},
+		{orderbook.SortByRate, true, "[2 3 1 5 4]"},
+		{orderbook.SortByMaxVolume, false, "[2 5 3 4 1]"},
+		{orderbook.SortByMaxVolume, true, "[1 4 3 5 2]"},
+		{orderbook.SortByMinVolume, false, "[1 2 3 4 5]"},
+	}
+
+	queries := map[string]orderbook.Query{
+		"pair":  {TokenBid: "BTC", TokenAsk: "ETH"},
+		"maker": {MakerId: "maker"},
+		"all":   {},
+	}
+
+	for name, q := range queries {
+		for _, test := range tests {
+			q.Sort, q.Descending = test.sort, test.descending
+			for _, limit := range []int{0, 2, 5} {
+				q.Limit = limit
+				if ids := fmt.Sprint(ListIds(t, book, q)); ids != test.ids {
+					t.Errorf("%s sorted by %q descending %v with limit %d: expected %s, got %s", name, test.sort, test.descending, limit, test.ids, ids)
+				}
+			}
+		}
+
+		q.Cursor = "not a cursor"
+		if _, err := book.ListOrders(context.Background(), q); !errors.Is(err, orderbook.ErrInvalidCursor) {
+			t.Errorf("%s: expected ErrInvalidCursor, got %v", name, err)
+		}
+	}
+}
+
+func testListOrderPagesOfChangingBook(t *testing.T, newBook NewBook) {
+	ctx := context.Background()
+	for _, q := range []orderbook.Query{{TokenBid: "BTC", TokenAsk: "ETH"}, {}} {
+		book := newBook(t)
+		addPagedOrders(t, book)
+		q.Sort, q.Descending, q.Limit = orderbook.SortByRate, true, 2
+
+		page, err := book.ListOrders(ctx, q)
+		if err != nil || fmt.Sprint(OrderIds(page.Orders)) != "[2 3]" {
+			t.Fatalf("expected first page of orders 2, 3, got %v, %v", page, err)
+		}
+
+		// removing listed order doesn't shift next pages, new order after cursor is listed
+		if err := book.RemoveOrder(ctx, "2"); err != nil {
+			t.Fatalf("removing order: %v", err)
+		}
+		if err := book.AddOrder(ctx, Order("6", 3, 10)); err != nil {
+			t.Fatalf("adding order: %v", err)
+		}
+
+		q.Cursor = page.NextCursor
+		if ids := fmt.Sprint(ListIds(t, book, q)); ids != "[6 1 5 4]" {
+			t.Errorf("expected next orders 6, 1, 5, 4, got %s", ids)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package booktest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/SashaBokov/orderbook"
+	"github.com/shopspring/decimal"
+)
+
+func testPairRegistry(t *testing.T, newBook NewBook) {
+	book := newBook(t)
+	ctx := context.Background()
+	AddOrders(t, book)
+
+	// reversed tokens are the same pair
+	if err := book.AddNewPair(ctx, orderbook.Pair{TokenBid: "ETH", TokenAsk: "BTC"}); !errors.Is(err, orderbook.ErrPairExists) {
+		t.Fatalf("expected ErrPairExists of reversed pair, got %v", err)
+	}
+	if err := book.AddNewPair(ctx, orderbook.Pair{TokenBid: "ADA", TokenAsk: "BTC"}); err != nil {
+		t.Fatalf("adding pair: %v", err)
+	}
+
+	registered, err := book.GetPair(ctx, "ETH", "BTC")
+	if err != nil || registered.TokenBid != "BTC" || registered.Status != orderbook.PairActive {
+		t.Fatalf("expected active pair BTC_ETH by reversed tokens, got %v, %v", registered, err)
+	}
+	if exists, err := book.PairExists(ctx, "BTC", "USDT"); err != nil || exists {
+		t.Fatalf("expected missing pair, got %v, %v", exists, err)
+	}
+	if exists, err := book.PairExists(ctx, "BTC", "ADA"); err != nil || !exists {
+		t.Fatalf("expected existing pair, got %v, %v", exists, err)
+	}
+
+	pairs, err := book.ListPairs(ctx)
+	if err != nil || len(pairs) != 2 || pairs[0].TokenBid != "ADA" || pairs[1].TokenBid != "BTC" {
+		t.Fatalf("expected pairs ADA_BTC and BTC_ETH, got %v, %v", pairs, err)
+	}
+
+	if err := book.RemovePair(ctx, "BTC", "ADA"); err != nil {
+		t.Fatalf("removing pair by reversed tokens: %v", err)
+	}
+	if _, err := book.GetPair(ctx, "ADA", "BTC"); !errors.Is(err, orderbook.ErrPairNotFound) {
+		t.Fatalf("expected ErrPairNotFound of removed pair, got %v", err)
+	}
+}
+
+func testHaltedPairRefusesOrders(t *testing.T, newBook NewBook) {
+	book := newBook(t)
+	ctx := context.Background()
+	AddOrders(t, book, MakerOrder("1", 2, 10))
+
+	// pair is updated by reversed tokens and keeps its registered direction
+	halted := orderbook.Pair{TokenBid: "ETH", TokenAsk: "BTC", TickSize: decimal.RequireFromString("0.5"), Status: orderbook.PairHalted}
+	if err := book.UpdatePair(ctx, halted); err != nil {
+		t.Fatalf("updating pair: %v", err)
+	}
+	registered, err := book.GetPair(ctx, "BTC", "ETH")
+	if err != nil || registered.TokenBid != "BTC" || registered.Status != orderbook.PairHalted || !registered.TickSize.Equal(halted.TickSize) {
+		t.Fatalf("expected halted pair BTC_ETH, got %v, %v", registered, err)
+	}
+
+	if err := book.AddOrder(ctx, Order("2", 2, 10)); !errors.Is(err, orderbook.ErrPairHalted) {
+		t.Fatalf("expected ErrPairHalted of adding order, got %v", err)
+	}
+	if _, err := book.MatchOrder(ctx, Order("taker", 1, 10)); !errors.Is(err, orderbook.ErrPairHalted) {
+		t.Fatalf("expected ErrPairHalted of matching order, got %v", err)
+	}
+	if _, err := book.GetOrderById(ctx, "1"); err != nil {
+		t.Fatalf("expected orders of halted pair kept, got %v", err)
+	}
+
+	halted.Status = orderbook.PairActive
+	if err := book.UpdatePair(ctx, halted); err != nil {
+		t.Fatalf("updating pair: %v", err)
+	}
+	if err := book.AddOrder(ctx, Order("2", 2, 10)); err != nil {
+		t.Fatalf("adding order of tick size: %v", err)
+	}
+
+	order := Order("3", 2, 10)
+	order.Rate = decimal.RequireFromString("2.2")
+	var validationErr *orderbook.ValidationError
+	if err := book.AddOrder(ctx, order); !errors.As(err, &validationErr) || validationErr.Fields[0].Field != "rate" {
+		t.Fatalf("expected tick size validation error, got %v", err)
+	}
+
+	if err := book.UpdatePair(ctx, orderbook.Pair{TokenBid: "BTC", TokenAsk: "USDT"}); !errors.Is(err, orderbook.ErrPairNotFound) {
+		t.Fatalf("expected ErrPairNotFound of updating missing pair, got %v", err)
+	}
+}
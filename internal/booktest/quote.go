@@ -0,0 +1,54 @@
+package booktest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/SashaBokov/orderbook"
+	"github.com/shopspring/decimal"
+)
+
+func testQuote(t *testing.T, newBook NewBook) {
+	book := newBook(t)
+	ctx := context.Background()
+	AddOrders(t, book, MakerOrder("1", 3, 5), MakerOrder("2", 2, 10))
+
+	quote, err := book.Quote(ctx, "BTC", "ETH", decimal.NewFromInt(20))
+	if err != nil {
+		t.Fatalf("getting quote: %v", err)
+	}
+	if len(quote.Fills) != 2 || !quote.Fillable.Equal(decimal.NewFromInt(20)) || !quote.Cost.Equal(decimal.RequireFromString("7.5")) {
+		t.Fatalf("expected 20 ETH for 7.5 BTC from both makers, got %v", quote)
+	}
+	RequireDecimal(t, "worst rate", quote.WorstRate, "2")
+
+	// quote doesn't change the book and matching makes the same fills
+	if order, err := book.GetOrderById(ctx, "1"); err != nil || !order.MaxVolume.Equal(decimal.NewFromInt(5)) {
+		t.Fatalf("expected order 1 not changed, got %v, %v", order, err)
+	}
+	taker := Order("taker", 1, 20)
+	taker.Rate = decimal.RequireFromString("0.5")
+	result, err := book.MatchOrder(ctx, taker)
+	if err != nil || len(result.Fills) != len(quote.Fills) {
+		t.Fatalf("expected %d fills, got %v, %v", len(quote.Fills), result, err)
+	}
+	for i, fill := range result.Fills {
+		if !fill.Volume.Equal(quote.Fills[i].Volume) {
+			t.Errorf("expected fill volume %s, got %s", quote.Fills[i].Volume, fill.Volume)
+		}
+	}
+
+	if _, err := book.Quote(ctx, "BTC", "ETH", decimal.Zero); !errors.Is(err, orderbook.ErrInvalidQuery) {
+		t.Fatalf("expected ErrInvalidQuery, got %v", err)
+	}
+	if _, err := book.Quote(ctx, "BTC", "USDT", decimal.NewFromInt(1)); !errors.Is(err, orderbook.ErrPairNotFound) {
+		t.Fatalf("expected ErrPairNotFound, got %v", err)
+	}
+	if err := book.UpdatePair(ctx, orderbook.Pair{TokenBid: "BTC", TokenAsk: "ETH", Status: orderbook.PairHalted}); err != nil {
+		t.Fatalf("updating pair: %v", err)
+	}
+	if _, err := book.Quote(ctx, "BTC", "ETH", decimal.NewFromInt(1)); !errors.Is(err, orderbook.ErrPairHalted) {
+		t.Fatalf("expected ErrPairHalted, got %v", err)
+	}
+}
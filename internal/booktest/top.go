@@ -0,0 +1,47 @@
+package booktest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/SashaBokov/orderbook"
+	"github.com/shopspring/decimal"
+)
+
+func testGetTopOfBook(t *testing.T, newBook NewBook) {
+	book := newBook(t)
+	ctx := context.Background()
+	bid := Order("1", 1, 10)
+	bid.Rate = decimal.RequireFromString("0.4")
+	worseBid := Order("2", 1, 10)
+	worseBid.Rate = decimal.RequireFromString("0.3")
+	AddOrders(t, book, bid, worseBid, MakerOrder("3", 2, 3), MakerOrder("4", 1, 5))
+
+	top, err := book.GetTopOfBook(ctx, "BTC", "ETH")
+	if err != nil || top.Bid == nil || top.Bid.Id != "1" || top.Ask == nil || top.Ask.Id != "3" {
+		t.Fatalf("expected bid 1 and ask 3, got %v, %v", top, err)
+	}
+	RequireDecimal(t, "spread", top.Spread, "0.1")
+
+	// reversed tokens swap sides and rates
+	top, err = book.GetTopOfBook(ctx, "ETH", "BTC")
+	if err != nil || top.Bid == nil || top.Bid.Id != "3" || top.Ask == nil || top.Ask.Id != "1" {
+		t.Fatalf("expected bid 3 and ask 1, got %v, %v", top, err)
+	}
+	RequireDecimal(t, "ask rate", top.AskRate, "2.5")
+
+	for _, id := range []string{"3", "4"} {
+		if err := book.RemoveOrder(ctx, id); err != nil {
+			t.Fatalf("removing order: %v", err)
+		}
+	}
+	top, err = book.GetTopOfBook(ctx, "BTC", "ETH")
+	if err != nil || top.Bid == nil || top.Bid.Id != "1" || top.Ask != nil || !top.Spread.IsZero() {
+		t.Fatalf("expected empty ask side, got %v, %v", top, err)
+	}
+
+	if _, err := book.GetTopOfBook(ctx, "BTC", "USDT"); !errors.Is(err, orderbook.ErrPairNotFound) {
+		t.Fatalf("expected ErrPairNotFound, got %v", err)
+	}
+}
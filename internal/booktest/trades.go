@@ -0,0 +1,133 @@
+package booktest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/SashaBokov/orderbook"
+	"github.com/shopspring/decimal"
+)
+
+// tradeKeys returns maker order id and volume of every trade
+func tradeKeys(trades []orderbook.Trade) []string {
+	keys := make([]string, 0, len(trades))
+	for _, trade := range trades {
+		keys = append(keys, trade.MakerOrderId+":"+trade.Volume.String())
+	}
+
+	return keys
+}
+
+func testTrades(t *testing.T, newBook NewBook) {
+	clock := NewClock()
+	start := clock.Now()
+	book := newBook(t, orderbook.WithClock(clock))
+	ctx := context.Background()
+	other := Order("2", 2, 10)
+	other.MakerId = "other"
+	AddOrders(t, book, Order("1", 2, 10), other, MakerOrder("3", 2, 10))
+
+	// trades of order 1 at start, of orders 1 and 2 an hour later and of order 1 two hours later
+	fills := []struct {
+		orderId string
+		volume  int64
+		after   time.Duration
+	}{
+		{"1", 2, 0},
+		{"1", 3, time.Hour},
+		{"2", 1, 0},
+		{"1", 1, time.Hour},
+	}
+	for _, fill := range fills {
+		clock.Advance(fill.after)
+		if _, err := book.FillOrder(ctx, fill.orderId, decimal.NewFromInt(fill.volume)); err != nil {
+			t.Fatalf("filling order: %v", err)
+		}
+	}
+
+	// taker getting 10 ETH takes 5 BTC of order 3
+	result, err := book.MatchOrder(ctx, Order("taker", 1, 10))
+	if err != nil || len(result.Trades) != 1 {
+		t.Fatalf("expected a trade of match, got %v, %v", result, err)
+	}
+	if trade := result.Trades[0]; trade.Id == 0 || trade.TakerOrderId != "taker" || trade.TakerId != "maker" || trade.MakerOrderId != "3" {
+		t.Fatalf("expected trade of taker with order 3, got %v", trade)
+	}
+
+	// trades of pair are listed by tokens of any direction
+	page, err := book.ListTradesByPair(ctx, "ETH", "BTC", orderbook.TimeRange{}, "", 0)
+	if err != nil || page.NextCursor != "" {
+		t.Fatalf("listing trades: %v, %v", page, err)
+	}
+	trades := page.Trades
+	if keys := fmt.Sprint(tradeKeys(trades)); keys != "[1:2 1:3 2:1 1:1 3:5]" {
+		t.Fatalf("expected every trade of pair, got %v", keys)
+	}
+
+	trade, err := book.GetTrade(ctx, trades[1].Id)
+	if err != nil || trade.MakerOrderId != "1" || trade.TakerOrderId != "" || !trade.Volume.Equal(decimal.NewFromInt(3)) || !trade.Time.Equal(start.Add(time.Hour)) {
+		t.Fatalf("expected trade of order 1 with volume 3 an hour later, got %v, %v", trade, err)
+	}
+	if _, err := book.GetTrade(ctx, trades[4].Id+100); !errors.Is(err, orderbook.ErrTradeNotFound) {
+		t.Fatalf("expected ErrTradeNotFound, got %v", err)
+	}
+
+	byPair := func(cursor orderbook.Cursor, limit int) (orderbook.TradePage, error) {
+		return book.ListTradesByPair(ctx, "BTC", "ETH", orderbook.TimeRange{}, cursor, limit)
+	}
+	tests := []struct {
+		name string
+		list func(cursor orderbook.Cursor, limit int) (orderbook.TradePage, error)
+		keys string
+	}{
+		{"by pair", byPair, "[1:2 1:3 2:1 1:1 3:5]"},
+		{"by maker", func(cursor orderbook.Cursor, limit int) (orderbook.TradePage, error) {
+			return book.ListTradesByMaker(ctx, "maker", orderbook.TimeRange{}, cursor, limit)
+		}, "[1:2 1:3 1:1 3:5]"},
+		{"by maker from", func(cursor orderbook.Cursor, limit int) (orderbook.TradePage, error) {
+			return book.ListTradesByMaker(ctx, "maker", orderbook.TimeRange{From: start.Add(time.Hour)}, cursor, limit)
+		}, "[1:3 1:1 3:5]"},
+		{"by maker to", func(cursor orderbook.Cursor, limit int) (orderbook.TradePage, error) {
+			return book.ListTradesByMaker(ctx, "maker", orderbook.TimeRange{To: start.Add(time.Hour)}, cursor, limit)
+		}, "[1:2]"},
+		{"by unknown maker", func(cursor orderbook.Cursor, limit int) (orderbook.TradePage, error) {
+			return book.ListTradesByMaker(ctx, "nobody", orderbook.TimeRange{}, cursor, limit)
+		}, "[]"},
+	}
+	for _, test := range tests {
+		// trades are listed by pages of 2 trades
+		listed := make([]orderbook.Trade, 0)
+		var cursor orderbook.Cursor
+		for {
+			page, err := test.list(cursor, 2)
+			if err != nil {
+				t.Fatalf("%s: listing trades: %v", test.name, err)
+			}
+			if len(page.Trades) > 2 {
+				t.Fatalf("%s: expected at most 2 trades in page, got %v", test.name, page.Trades)
+			}
+			listed = append(listed, page.Trades...)
+			if page.NextCursor == "" {
+				break
+			}
+			cursor = page.NextCursor
+		}
+		if keys := fmt.Sprint(tradeKeys(listed)); keys != test.keys {
+			t.Errorf("%s: expected trades %s, got %s", test.name, test.keys, keys)
+		}
+	}
+	if _, err := byPair("not a cursor", 2); !errors.Is(err, orderbook.ErrInvalidCursor) {
+		t.Fatalf("expected ErrInvalidCursor, got %v", err)
+	}
+
+	// trades are kept after pair is removed
+	if err := book.RemovePair(ctx, "BTC", "ETH"); err != nil {
+		t.Fatalf("removing pair: %v", err)
+	}
+	if page, err := byPair("", 0); err != nil || len(page.Trades) != 5 {
+		t.Fatalf("expected trades of removed pair, got %v, %v", page, err)
+	}
+}
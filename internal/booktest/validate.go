@@ -0,0 +1,60 @@
+package booktest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/SashaBokov/orderbook"
+	"github.com/shopspring/decimal"
+)
+
+func testValidationErrors(t *testing.T, newBook NewBook) {
+	book := newBook(t)
+	ctx := context.Background()
+	AddOrders(t, book)
+
+	order := Order("1", 2, 10)
+	order.Rate = decimal.NewFromInt(-1)
+	order.MinVolume = decimal.NewFromInt(11)
+
+	var validationErr *orderbook.ValidationError
+	if err := book.AddOrder(ctx, order); !errors.As(err, &validationErr) || len(validationErr.Fields) != 2 {
+		t.Fatalf("expected rate and min_volume validation errors, got %v", err)
+	}
+	if validationErr.Fields[0].Field != "rate" || validationErr.Fields[1].Field != "min_volume" {
+		t.Fatalf("expected rate and min_volume validation errors, got %v", validationErr.Fields)
+	}
+
+	taker := Order("taker", 1, 0)
+	if _, err := book.MatchOrder(ctx, taker); !errors.Is(err, orderbook.ErrInvalidOrder) {
+		t.Fatalf("expected ErrInvalidOrder of taker without volume, got %v", err)
+	}
+
+	if _, err := book.GetOrderById(ctx, "1"); !errors.Is(err, orderbook.ErrOrderNotFound) {
+		t.Fatalf("expected invalid order not added, got %v", err)
+	}
+}
+
+func testValidatorOfOptions(t *testing.T, newBook NewBook) {
+	requireMaker := func(order orderbook.Order) []orderbook.FieldError {
+		if order.MakerId != "trusted" {
+			return []orderbook.FieldError{{Field: "maker_id", Reason: "must be trusted"}}
+		}
+		return nil
+	}
+	book := newBook(t, orderbook.WithValidator(orderbook.NewValidator(append(orderbook.DefaultRules, requireMaker)...)))
+	ctx := context.Background()
+	AddOrders(t, book)
+
+	var validationErr *orderbook.ValidationError
+	if err := book.AddOrder(ctx, Order("1", 2, 10)); !errors.As(err, &validationErr) || validationErr.Fields[0].Field != "maker_id" {
+		t.Fatalf("expected maker_id validation error, got %v", err)
+	}
+
+	order := Order("1", 2, 10)
+	order.MakerId = "trusted"
+	if err := book.AddOrder(ctx, order); err != nil {
+		t.Fatalf("adding order: %v", err)
+	}
+}
@@ -0,0 +1,101 @@
+package orderbook
+
+import (
+	"fmt"
+
+	"github.com/shopspring/decimal"
+)
+
+// VolumePrecision is a number of decimal places of volume taken from maker order,
+// volume taken for a part of taker order is rounded down to it
+const VolumePrecision = 18
+
+// Fill is a part of maker order taken by taker order
+type Fill struct {
+	// Order is maker order after the fill, its MaxVolume is the remaining volume
+	Order Order `json:"order"`
+	// Volume is taken volume in maker TokenAsk
+	Volume decimal.Decimal `json:"volume"`
+	// Closed is true when maker order is fully taken or its remaining volume is less than MinVolume
+	Closed bool `json:"closed"`
+}
+
+// MatchResult is a result of matching taker order against orderbook
+type MatchResult struct {
+	Fills []Fill `json:"fills"`
+	// Trades are records of fills assigned by orderbook, MatchOrders leaves them empty
+	Trades []Trade `json:"trades"`
+	// Remainder is unfilled part of taker order, its MaxVolume is the remaining volume
+	Remainder Order `json:"remainder"`
+}
+
+// Crosses checks that maker order rate satisfies taker order rate.
+//
+// Taker order is ready to give taker.Rate of TokenBid for each unit of TokenAsk,
+// maker order of opposite pair gives maker.Rate of taker TokenAsk for each unit of taker TokenBid.
+func Crosses(taker, maker Order) bool {
+	return maker.Rate.Mul(taker.Rate).GreaterThanOrEqual(decimal.NewFromInt(1))
+}
+
+// MatchOrders matching taker order against maker orders of opposite pair sorted by best (max) rate.
+// Every maker is filled up to its MaxVolume, makers which can't be filled with at least MinVolume are skipped.
+// Taker MaxVolume is a volume of taker TokenAsk to get, taker MinVolume is not used.
+func MatchOrders(taker Order, makers []Order) MatchResult {
+	result := MatchResult{Fills: make([]Fill, 0), Trades: make([]Trade, 0), Remainder: taker}
+	for _, maker := range makers {
+		if !result.Remainder.MaxVolume.IsPositive() || !Crosses(taker, maker) {
+			break
+		}
+
+		volume, received, ok := takeVolume(maker, result.Remainder.MaxVolume)
+		if !ok {
+			continue
+		}
+
+		result.Remainder.MaxVolume = result.Remainder.MaxVolume.Sub(received)
+		result.Fills = append(result.Fills, maker.take(volume))
+	}
+
+	return result
+}
+
+// takeVolume returning volume of maker taken by taker to receive at most remaining of maker TokenBid and received amount,
+// ok is false when maker can't be filled with at least MinVolume
+func takeVolume(maker Order, remaining decimal.Decimal) (volume, received decimal.Decimal, ok bool) {
+	// taker gets maker.Rate of its TokenAsk for each unit of maker volume,
+	// volume for the rest of taker order is rounded down, so taker never receives more than the rest
+	volume = maker.MaxVolume
+	if volume.Mul(maker.Rate).GreaterThan(remaining) {
+		volume, _ = remaining.QuoRem(maker.Rate, VolumePrecision)
+	}
+	received = volume.Mul(maker.Rate)
+
+	return volume, received, volume.IsPositive() && !volume.LessThan(maker.MinVolume)
+}
+
+// Take taking volume of order, volume must be from MinVolume to MaxVolume of order, otherwise ErrInvalidFill is returned.
+// Returned fill order has MaxVolume decreased by volume, it is closed with OrderFilled status when the rest is less than MinVolume.
+func (o Order) Take(volume decimal.Decimal) (Fill, error) {
+	if !volume.IsPositive() || volume.LessThan(o.MinVolume) || volume.GreaterThan(o.MaxVolume) {
+		return Fill{}, fmt.Errorf("%w: volume %s is out of order volumes from %s to %s", ErrInvalidFill, volume, o.MinVolume, o.MaxVolume)
+	}
+
+	return o.take(volume), nil
+}
+
+// take taking volume of order without checks
+func (o Order) take(volume decimal.Decimal) Fill {
+	o.MaxVolume = o.MaxVolume.Sub(volume)
+	closed := !o.MaxVolume.IsPositive() || o.MaxVolume.LessThan(o.MinVolume)
+
+	o.Status = OrderPartiallyFilled
+	if closed {
+		o.Status = OrderFilled
+	}
+
+	return Fill{
+		Order:  o,
+		Volume: volume,
+		Closed: closed,
+	}
+}
@@ -0,0 +1,140 @@
+package orderbook
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+// makerOrder returns order ETH_BTC giving rate of ETH for every BTC of volume
+func makerOrder(id string, rate, volume, minVolume string) Order {
+	return Order{
+		Id:        id,
+		MakerId:   "maker" + id,
+		TokenBid:  "ETH",
+		TokenAsk:  "BTC",
+		Rate:      decimal.RequireFromString(rate),
+		MaxVolume: decimal.RequireFromString(volume),
+		MinVolume: decimal.RequireFromString(minVolume),
+	}
+}
+
+// takerOrder returns order BTC_ETH giving rate of BTC for every ETH to get volume of ETH
+func takerOrder(rate, volume string) Order {
+	return Order{
+		Id:        "taker",
+		MakerId:   "taker",
+		TokenBid:  "BTC",
+		TokenAsk:  "ETH",
+		Rate:      decimal.RequireFromString(rate),
+		MaxVolume: decimal.RequireFromString(volume),
+	}
+}
+
+func requireDecimal(t *testing.T, name string, got decimal.Decimal, want string) {
+	t.Helper()
+
+	if !got.Equal(decimal.RequireFromString(want)) {
+		t.Errorf("expected %s %s, got %s", name, want, got)
+	}
+}
+
+func TestMatchOrdersFillsBestMakersFirst(t *testing.T) {
+	makers := []Order{makerOrder("1", "3", "5", "1"), makerOrder("2", "2", "10", "1"), makerOrder("3", "2", "10", "1")}
+
+	result := MatchOrders(takerOrder("0.5", "30"), makers)
+	if len(result.Fills) != 2 {
+		t.Fatalf("expected 2 fills, got %v", result.Fills)
+	}
+
+	// the first maker gives 15 ETH for all its 5 BTC and is closed
+	first := result.Fills[0]
+	requireDecimal(t, "first fill volume", first.Volume, "5")
+	if !first.Closed {
+		t.Errorf("expected closed first maker, got %v", first)
+	}
+
+	// the second maker gives the rest 15 ETH for 7.5 BTC
+	second := result.Fills[1]
+	requireDecimal(t, "second fill volume", second.Volume, "7.5")
+	requireDecimal(t, "second maker rest", second.Order.MaxVolume, "2.5")
+	if second.Closed {
+		t.Errorf("expected second maker to stay open, got %v", second)
+	}
+
+	requireDecimal(t, "remainder", result.Remainder.MaxVolume, "0")
+}
+
+func TestMatchOrdersStopsAtNotCrossingMaker(t *testing.T) {
+	makers := []Order{makerOrder("1", "3", "5", "1"), makerOrder("2", "1", "10", "1")}
+
+	// taker gives 0.5 BTC for ETH, so it needs at least 2 ETH for BTC
+	result := MatchOrders(takerOrder("0.5", "30"), makers)
+	if len(result.Fills) != 1 || result.Fills[0].Order.Id != "1" {
+		t.Fatalf("expected fill of the first maker only, got %v", result.Fills)
+	}
+	requireDecimal(t, "remainder", result.Remainder.MaxVolume, "15")
+}
+
+func TestMatchOrdersSkipsMakerBelowMinVolume(t *testing.T) {
+	makers := []Order{makerOrder("1", "2", "10", "8"), makerOrder("2", "2", "10", "1")}
+
+	// 10 ETH are 5 BTC of the first maker which can't be filled with less than 8 BTC
+	result := MatchOrders(takerOrder("1", "10"), makers)
+	if len(result.Fills) != 1 || result.Fills[0].Order.Id != "2" {
+		t.Fatalf("expected fill of the second maker only, got %v", result.Fills)
+	}
+	requireDecimal(t, "fill volume", result.Fills[0].Volume, "5")
+}
+
+func TestCrosses(t *testing.T) {
+	taker := takerOrder("0.5", "1")
+	if !Crosses(taker, makerOrder("1", "2", "1", "0")) {
+		t.Error("expected maker with rate 2 to cross taker with rate 0.5")
+	}
+	if Crosses(taker, makerOrder("1", "1.9", "1", "0")) {
+		t.Error("expected maker with rate 1.9 not to cross taker with rate 0.5")
+	}
+}
+
+func TestMatchOrdersRoundsTakenVolumeDown(t *testing.T) {
+	makers := []Order{makerOrder("1", "3", "10", "0")}
+
+	// 1 ETH is 1/3 BTC, volume is rounded down, so taker gets volume times rate and keeps the rest
+	result := MatchOrders(takerOrder("1", "1"), makers)
+	if len(result.Fills) != 1 {
+		t.Fatalf("expected a single fill, got %v", result.Fills)
+	}
+	requireDecimal(t, "fill volume", result.Fills[0].Volume, "0.333333333333333333")
+	requireDecimal(t, "maker rest", result.Fills[0].Order.MaxVolume, "9.666666666666666667")
+	requireDecimal(t, "remainder", result.Remainder.MaxVolume, "0.000000000000000001")
+}
+
+func TestOrderTake(t *testing.T) {
+	order := makerOrder("1", "2", "10", "3")
+
+	fill, err := order.Take(decimal.NewFromInt(6))
+	if err != nil {
+		t.Fatalf("taking volume: %v", err)
+	}
+	requireDecimal(t, "rest", fill.Order.MaxVolume, "4")
+	if fill.Closed {
+		t.Errorf("expected open order, got %v", fill)
+	}
+
+	// rest of 2 is less than min volume 3, so order is closed
+	fill, err = order.Take(decimal.NewFromInt(8))
+	if err != nil {
+		t.Fatalf("taking volume: %v", err)
+	}
+	if !fill.Closed {
+		t.Errorf("expected closed order, got %v", fill)
+	}
+
+	for _, volume := range []string{"0", "2", "11"} {
+		if _, err := order.Take(decimal.RequireFromString(volume)); !errors.Is(err, ErrInvalidFill) {
+			t.Errorf("expected ErrInvalidFill of volume %s, got %v", volume, err)
+		}
+	}
+}
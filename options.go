@@ -0,0 +1,43 @@
+package orderbook
+
+// Options are settings of OrderBook implementations
+type Options struct {
+	// Validator checks orders before they are added or matched
+	Validator *Validator
+	// Clock is used to check order expiry
+	Clock Clock
+}
+
+// Option is a function changing Options
+type Option func(options *Options)
+
+// NewOptions returns default options changed by opts
+func NewOptions(opts ...Option) Options {
+	options := Options{
+		Validator: DefaultValidator,
+		Clock:     SystemClock{},
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return options
+}
+
+// WithValidator setting validator of orders, nil validator is ignored and DefaultValidator is kept
+func WithValidator(validator *Validator) Option {
+	return func(options *Options) {
+		if validator != nil {
+			options.Validator = validator
+		}
+	}
+}
+
+// WithClock setting clock used to check order expiry, nil clock is ignored and SystemClock is kept
+func WithClock(clock Clock) Option {
+	return func(options *Options) {
+		if clock != nil {
+			options.Clock = clock
+		}
+	}
+}
@@ -1,59 +1,131 @@
 package orderbook
 
 import (
-	"github.com/SashaBokov/orderbook/repository/postgres"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/shopspring/decimal"
 )
 
-// Order is representation of P2P order
+// Order is representation of P2P order,
+// maker is ready to give Rate of TokenBid for each unit of TokenAsk, from MinVolume to MaxVolume of TokenAsk.
+// Rate and volumes are exact decimals, they are stored as DECIMAL in postgres and marshaled to JSON as strings.
 type Order struct {
-	Id        string  `json:"id" db:"id"`
-	MakerId   string  `json:"maker_id" db:"maker_id"`
-	TokenBid  string  `json:"token_bid" db:"token_bid"`
-	TokenAsk  string  `json:"token_ask" db:"token_ask"`
-	Rate      float64 `json:"rate" db:"rate"`
-	MaxVolume float64 `json:"max_volume" db:"max_volume"`
-	MinVolume float64 `json:"min_volume" db:"min_volume"`
+	Id        string          `json:"id" db:"id"`
+	MakerId   string          `json:"maker_id" db:"maker_id"`
+	TokenBid  string          `json:"token_bid" db:"token_bid"`
+	TokenAsk  string          `json:"token_ask" db:"token_ask"`
+	Rate      decimal.Decimal `json:"rate" db:"rate"`
+	MaxVolume decimal.Decimal `json:"max_volume" db:"max_volume"`
+	MinVolume decimal.Decimal `json:"min_volume" db:"min_volume"`
+	// Priority is a time priority assigned by orderbook, orders with equal rate are matched in ascending priority
+	Priority int64 `json:"priority" db:"priority"`
+	// ExpiresAt is a time after which order is not listed and matched, nil means order never expires
+	ExpiresAt *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+	// Status is a lifecycle status assigned by orderbook
+	Status OrderStatus `json:"status" db:"status"`
+}
+
+// Expired checks that order is expired at now
+func (o Order) Expired(now time.Time) bool {
+	return o.ExpiresAt != nil && !o.ExpiresAt.After(now)
 }
 
+// OrderBook is a storage of P2P orders,
+// ctx of every method is passed down to the storage, so its cancellation and deadline stop the call.
+// Methods return errors declared in this package wrapped with details, check them with errors.Is.
+// Expired orders are not returned by Get and List methods, they are not matched, filled or amended.
+// Filled, cancelled and expired orders are not returned either, their status changes are kept in order history.
 type OrderBook interface {
-	// AddNewPair adding new pair to orderbook
-	AddNewPair(tokenBid, tokenAsk string) error
-	// AddOrder adding new order to orderbook
-	AddOrder(order Order) error
+	// AddNewPair adding new pair to orderbook, orders of both pair directions may be added then
+	AddNewPair(ctx context.Context, pair Pair) error
+	// AddOrder adding new order to orderbook, order pair must exist and be active
+	AddOrder(ctx context.Context, order Order) error
+	// UpdatePair updating metadata and status of existing pair
+	UpdatePair(ctx context.Context, pair Pair) error
+
+	// GetPair getting pair by tokens of any direction
+	GetPair(ctx context.Context, tokenBid, tokenAsk string) (Pair, error)
+	// PairExists checking that pair with tokens of any direction exists
+	PairExists(ctx context.Context, tokenBid, tokenAsk string) (bool, error)
+	// ListPairs getting all pairs of orderbook
+	ListPairs(ctx context.Context) ([]Pair, error)
+
+	// MatchOrder matching taker order against orders of opposite pair with best rate, taken orders are filled or removed
+	MatchOrder(ctx context.Context, taker Order) (MatchResult, error)
+	// Quote walking orders of opposite pair like MatchOrder to get amount of tokenAsk for tokenBid, the book isn't changed
+	Quote(ctx context.Context, tokenBid, tokenAsk string, amount decimal.Decimal) (Quote, error)
+	// AmendOrder changing rate and volumes of order in place, order loses time priority when rate is changed or max volume is increased
+	AmendOrder(ctx context.Context, orderId string, amendment Amendment) (Order, error)
+	// FillOrder taking volume of order, order is closed and removed when the rest is less than MinVolume
+	FillOrder(ctx context.Context, orderId string, volume decimal.Decimal) (Order, error)
 
 	// GetOrderById getting order from orderbook
-	GetOrderById(orderId string) (Order, error)
+	GetOrderById(ctx context.Context, orderId string) (Order, error)
 	// GetOrderWithMaxRate getting order from orderbook with max rate
-	GetOrderWithMaxRate(tokenBid, tokenAsk string) (Order, error)
+	GetOrderWithMaxRate(ctx context.Context, tokenBid, tokenAsk string) (Order, error)
 	// GetOrderWithMinRate getting order from orderbook with min rate
-	GetOrderWithMinRate(tokenBid, tokenAsk string) (Order, error)
+	GetOrderWithMinRate(ctx context.Context, tokenBid, tokenAsk string) (Order, error)
 	// GetOrderWithMaxVolume getting order from orderbook with max volume
-	GetOrderWithMaxVolume(tokenBid, tokenAsk string) (Order, error)
+	GetOrderWithMaxVolume(ctx context.Context, tokenBid, tokenAsk string) (Order, error)
 	// GetOrderWithMinVolume getting order from orderbook with min volume
-	GetOrderWithMinVolume(tokenBid, tokenAsk string) (Order, error)
-
-	// For using lists without limit and/or offset, set limit -1 and/or offset -1
-
-	// ListOrdersByPair getting orders from orderbook by pair
-	ListOrdersByPair(tokenBid, tokenAsk string, limit, offset int) ([]Order, error)
-	// ListOrdersByMakerId getting order from orderbook
-	ListOrdersByMakerId(makerId string, limit, offset int) ([]Order, error)
-	//	ListMaxRateOrders getting orders from orderbook with max rate
-	ListMaxRateOrders(tokenBid, tokenAsk string, limit, offset int) ([]Order, error)
-	//	ListMinRateOrders getting orders from orderbook with min rate
-	ListMinRateOrders(tokenBid, tokenAsk string, limit, offset int) ([]Order, error)
-	//	ListMaxVolumeOrders getting orders from orderbook with max volume
-	ListMaxVolumeOrders(tokenBid, tokenAsk string, limit, offset int) ([]Order, error)
-	//	ListMinVolumeOrders getting orders from orderbook with min volume
-	ListMinVolumeOrders(tokenBid, tokenAsk string, limit, offset int) ([]Order, error)
-
-	// RemovePair removing pair from orderbook
-	RemovePair(tokenBid, tokenAsk string) error
-	// RemoveOrder removing order from orderbook
-	RemoveOrder(orderId string) error
+	GetOrderWithMinVolume(ctx context.Context, tokenBid, tokenAsk string) (Order, error)
+
+	// GetTopOfBook getting the best orders of both pair directions with spread and mid rate in tokenBid per tokenAsk
+	GetTopOfBook(ctx context.Context, tokenBid, tokenAsk string) (TopOfBook, error)
+
+	// ListOrders getting page of orders matching query q, orders of any pairs and makers are listed if q doesn't select them.
+	// Pass Page.NextCursor as q.Cursor of the same query to get the next page, orders added or removed meanwhile don't shift pages.
+	// Page.Orders is empty when there are no orders, query of pair which doesn't exist returns ErrPairNotFound.
+	ListOrders(ctx context.Context, q Query) (Page, error)
+	// GetDepth getting at most levels best rate levels of both pair directions, orders are grouped by rate rounded down to tick.
+	// Zero tick groups orders with equal rate, non-positive levels returns all levels.
+	GetDepth(ctx context.Context, tokenBid, tokenAsk string, levels int, tick decimal.Decimal) (Depth, error)
+
+	// RemovePair removing pair from orderbook, pair orders are cancelled
+	RemovePair(ctx context.Context, tokenBid, tokenAsk string) error
+	// RemoveOrder removing order from orderbook, order is kept in history with OrderCancelled status
+	RemoveOrder(ctx context.Context, orderId string) error
+	// RemoveExpiredOrders removing orders expired by orderbook clock with OrderExpired status, returns number of removed orders
+	RemoveExpiredOrders(ctx context.Context) (int, error)
+
+	// GetOrderHistory getting status changes of order in chronological order, removed orders have history too
+	GetOrderHistory(ctx context.Context, orderId string) ([]StatusChange, error)
+
+	// Trades are recorded by MatchOrder and FillOrder, they are listed in order of ids and paged like orders.
+	// Trade lists return empty page when there are no trades, even if pair is removed.
+
+	// GetTrade getting trade by id
+	GetTrade(ctx context.Context, tradeId int64) (Trade, error)
+	// ListTradesByPair getting trades of both pair directions in time range
+	ListTradesByPair(ctx context.Context, tokenBid, tokenAsk string, period TimeRange, cursor Cursor, limit int) (TradePage, error)
+	// ListTradesByMaker getting trades of maker orders in time range
+	ListTradesByMaker(ctx context.Context, makerId string, period TimeRange, cursor Cursor, limit int) (TradePage, error)
+
+	// Subscribe getting subscription of book changes selected by filter, events are sent after changes are committed.
+	// Call Subscription.Unsubscribe to stop it.
+	Subscribe(filter EventFilter) *Subscription
 }
 
-// NewOrderBookPostgres OrderBookPostgres constructor returns OrderBook postgres implementation
+// openPostgres is a constructor of postgres implementation registered by repository/postgres,
+// orderbook can't import repository packages, because they import orderbook
+var openPostgres func(databaseURL string) (OrderBook, error)
+
+// RegisterPostgres registering constructor of postgres implementation used by NewOrderBookPostgres,
+// repository/postgres registers postgres.New when it is imported
+func RegisterPostgres(open func(databaseURL string) (OrderBook, error)) {
+	openPostgres = open
+}
+
+// NewOrderBookPostgres OrderBookPostgres constructor returns OrderBook postgres implementation,
+// repository/postgres must be imported to register it.
+//
+// Deprecated: use postgres.New, or memory.New for in-memory implementation.
 func NewOrderBookPostgres(databaseURL string) (OrderBook, error) {
-	return postgres.New(databaseURL)
+	if openPostgres == nil {
+		return nil, errors.New("postgres implementation is not registered, import repository/postgres")
+	}
+
+	return openPostgres(databaseURL)
 }
@@ -0,0 +1,66 @@
+package orderbook
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestOrderJSONKeepsExactDecimals(t *testing.T) {
+	order := makerOrder("1", "0.1", "0.3", "0.000000000000000001")
+
+	data, err := json.Marshal(order)
+	if err != nil {
+		t.Fatalf("marshalling order: %v", err)
+	}
+
+	var decoded Order
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("unmarshalling order: %v", err)
+	}
+	requireDecimal(t, "rate", decoded.Rate, "0.1")
+	requireDecimal(t, "max volume", decoded.MaxVolume, "0.3")
+	requireDecimal(t, "min volume", decoded.MinVolume, "0.000000000000000001")
+}
+
+func TestOrderExpired(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	order := makerOrder("1", "1", "1", "0")
+
+	if order.Expired(now) {
+		t.Error("expected order without ExpiresAt not to expire")
+	}
+
+	order.ExpiresAt = &now
+	if !order.Expired(now) {
+		t.Error("expected order to expire at ExpiresAt")
+	}
+	if order.Expired(now.Add(-time.Second)) {
+		t.Error("expected order not to expire before ExpiresAt")
+	}
+}
+
+func TestWithClockIgnoresNil(t *testing.T) {
+	options := NewOptions(WithClock(nil))
+	if _, ok := options.Clock.(SystemClock); !ok {
+		t.Fatalf("expected SystemClock, got %v", options.Clock)
+	}
+
+	clock := &fixedClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	if options := NewOptions(WithClock(clock)); options.Clock != clock {
+		t.Fatalf("expected custom clock, got %v", options.Clock)
+	}
+}
+
+// fixedClock is a clock always returning now
+type fixedClock struct {
+	now time.Time
+}
+
+func (c *fixedClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fixedClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
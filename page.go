@@ -0,0 +1,74 @@
+package orderbook
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"github.com/shopspring/decimal"
+)
+
+// DefaultPageLimit is a page size used by list methods when limit is not positive
+const DefaultPageLimit = 100
+
+// Cursor is an opaque position in list returned as Page.NextCursor, empty Cursor is the start of list.
+// Cursor is valid only for the list which returned it.
+type Cursor string
+
+// Page is a part of orders list
+type Page struct {
+	Orders []Order `json:"orders"`
+	// NextCursor is a position after the last order of page, it is empty when there are no more orders
+	NextCursor Cursor `json:"next_cursor,omitempty"`
+}
+
+// TradePage is a part of trades list
+type TradePage struct {
+	Trades []Trade `json:"trades"`
+	// NextCursor is a position after the last trade of page, it is empty when there are no more trades
+	NextCursor Cursor `json:"next_cursor,omitempty"`
+}
+
+// Position is a decoded Cursor, it is a sort key of the last item of page.
+// Orders sorted by rate use Value and Seq as order rate and priority, orders sorted by volume use Value and Id,
+// orders sorted by id use Id only and trade lists use Seq as trade id.
+type Position struct {
+	Value decimal.Decimal `json:"v"`
+	Seq   int64           `json:"s,omitempty"`
+	Id    string          `json:"i,omitempty"`
+}
+
+// Cursor encoding position to Cursor
+func (p Position) Cursor() Cursor {
+	data, err := json.Marshal(p)
+	if err != nil {
+		// Position fields are always marshalled
+		panic(err)
+	}
+
+	return Cursor(base64.RawURLEncoding.EncodeToString(data))
+}
+
+// Position decoding cursor, returns ErrInvalidCursor if cursor is not returned by list
+func (c Cursor) Position() (Position, error) {
+	data, err := base64.RawURLEncoding.DecodeString(string(c))
+	if err != nil {
+		return Position{}, fmt.Errorf("%w: %s", ErrInvalidCursor, err)
+	}
+
+	var position Position
+	if err := json.Unmarshal(data, &position); err != nil {
+		return Position{}, fmt.Errorf("%w: %s", ErrInvalidCursor, err)
+	}
+
+	return position, nil
+}
+
+// PageLimit returning limit or DefaultPageLimit if limit is not positive
+func PageLimit(limit int) int {
+	if limit <= 0 {
+		return DefaultPageLimit
+	}
+
+	return limit
+}
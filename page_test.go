@@ -0,0 +1,44 @@
+package orderbook
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestCursorPosition(t *testing.T) {
+	position := Position{Value: decimal.RequireFromString("2.5"), Seq: 7, Id: "1"}
+
+	decoded, err := position.Cursor().Position()
+	if err != nil {
+		t.Fatalf("decoding cursor: %v", err)
+	}
+	if !decoded.Value.Equal(position.Value) || decoded.Seq != position.Seq || decoded.Id != position.Id {
+		t.Fatalf("expected position %v, got %v", position, decoded)
+	}
+}
+
+func TestInvalidCursor(t *testing.T) {
+	for _, cursor := range []Cursor{"not a cursor", Cursor("bm90IGpzb24")} {
+		if _, err := cursor.Position(); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("%s: expected ErrInvalidCursor, got %v", cursor, err)
+		}
+	}
+}
+
+func TestPageLimit(t *testing.T) {
+	tests := []struct {
+		limit, want int
+	}{
+		{-1, DefaultPageLimit},
+		{0, DefaultPageLimit},
+		{5, 5},
+	}
+
+	for _, test := range tests {
+		if got := PageLimit(test.limit); got != test.want {
+			t.Errorf("expected limit %d of %d, got %d", test.want, test.limit, got)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package orderbook
+
+import (
+	"fmt"
+
+	"github.com/shopspring/decimal"
+)
+
+// PairStatus is a trading status of pair
+type PairStatus string
+
+const (
+	// PairActive pair accepts new orders
+	PairActive PairStatus = "active"
+	// PairHalted pair keeps existing orders, but doesn't accept new ones
+	PairHalted PairStatus = "halted"
+)
+
+// Pair is a registered pair of tokens, orders of both directions tokenBid_tokenAsk and tokenAsk_tokenBid belong to it.
+// Zero TickSize, LotSize and MinNotional disable the checks.
+type Pair struct {
+	TokenBid string `json:"token_bid" db:"token_bid"`
+	TokenAsk string `json:"token_ask" db:"token_ask"`
+	// TickSize is a step of order rate
+	TickSize decimal.Decimal `json:"tick_size" db:"tick_size"`
+	// LotSize is a step of order volumes
+	LotSize decimal.Decimal `json:"lot_size" db:"lot_size"`
+	// MinNotional is a minimum of order MaxVolume multiplied by Rate
+	MinNotional decimal.Decimal `json:"min_notional" db:"min_notional"`
+	Status      PairStatus      `json:"status" db:"status"`
+}
+
+// Has checks that tokens are the pair tokens of any direction
+func (p Pair) Has(tokenBid, tokenAsk string) bool {
+	return p.TokenBid == tokenBid && p.TokenAsk == tokenAsk || p.TokenBid == tokenAsk && p.TokenAsk == tokenBid
+}
+
+// Validate checking pair tokens, sizes and status, empty status is valid and means PairActive
+func (p Pair) Validate() error {
+	switch {
+	case p.TokenBid == "" || p.TokenAsk == "":
+		return fmt.Errorf("%w: tokens must not be empty", ErrInvalidPair)
+	case p.TokenBid == p.TokenAsk:
+		return fmt.Errorf("%w: tokens must differ", ErrInvalidPair)
+	case p.TickSize.IsNegative() || p.LotSize.IsNegative() || p.MinNotional.IsNegative():
+		return fmt.Errorf("%w: sizes must not be negative", ErrInvalidPair)
+	case p.Status != "" && p.Status != PairActive && p.Status != PairHalted:
+		return fmt.Errorf("%w: unknown status %q", ErrInvalidPair, p.Status)
+	}
+
+	return nil
+}
+
+// CheckOrder checking order against pair status and sizes, returns ErrPairHalted or *ValidationError
+func (p Pair) CheckOrder(order Order) error {
+	if p.Status == PairHalted {
+		return fmt.Errorf("%w: pair %s_%s", ErrPairHalted, p.TokenBid, p.TokenAsk)
+	}
+
+	fields := make([]FieldError, 0)
+	if !p.TickSize.IsZero() && !order.Rate.Mod(p.TickSize).IsZero() {
+		fields = append(fields, FieldError{Field: "rate", Reason: "must be a multiple of tick size " + p.TickSize.String()})
+	}
+	if !p.LotSize.IsZero() && !order.MaxVolume.Mod(p.LotSize).IsZero() {
+		fields = append(fields, FieldError{Field: "max_volume", Reason: "must be a multiple of lot size " + p.LotSize.String()})
+	}
+	if !p.LotSize.IsZero() && !order.MinVolume.Mod(p.LotSize).IsZero() {
+		fields = append(fields, FieldError{Field: "min_volume", Reason: "must be a multiple of lot size " + p.LotSize.String()})
+	}
+	if order.MaxVolume.Mul(order.Rate).LessThan(p.MinNotional) {
+		fields = append(fields, FieldError{Field: "max_volume", Reason: "must be at least min notional " + p.MinNotional.String() + " by rate"})
+	}
+
+	if len(fields) == 0 {
+		return nil
+	}
+
+	return &ValidationError{Fields: fields}
+}
@@ -0,0 +1,69 @@
+package orderbook
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestPairValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		pair  Pair
+		valid bool
+	}{
+		{"active", Pair{TokenBid: "BTC", TokenAsk: "ETH", Status: PairActive}, true},
+		{"empty status", Pair{TokenBid: "BTC", TokenAsk: "ETH"}, true},
+		{"empty token", Pair{TokenBid: "BTC"}, false},
+		{"same tokens", Pair{TokenBid: "BTC", TokenAsk: "BTC"}, false},
+		{"negative tick size", Pair{TokenBid: "BTC", TokenAsk: "ETH", TickSize: decimal.NewFromInt(-1)}, false},
+		{"unknown status", Pair{TokenBid: "BTC", TokenAsk: "ETH", Status: "closed"}, false},
+	}
+
+	for _, test := range tests {
+		err := test.pair.Validate()
+		if test.valid && err != nil {
+			t.Errorf("%s: expected valid pair, got %v", test.name, err)
+		}
+		if !test.valid && !errors.Is(err, ErrInvalidPair) {
+			t.Errorf("%s: expected ErrInvalidPair, got %v", test.name, err)
+		}
+	}
+}
+
+func TestPairHasTokensOfBothDirections(t *testing.T) {
+	pair := Pair{TokenBid: "BTC", TokenAsk: "ETH"}
+
+	if !pair.Has("BTC", "ETH") || !pair.Has("ETH", "BTC") {
+		t.Error("expected pair to have tokens of both directions")
+	}
+	if pair.Has("BTC", "USDT") {
+		t.Error("expected pair not to have other tokens")
+	}
+}
+
+func TestPairCheckOrder(t *testing.T) {
+	pair := Pair{
+		TokenBid:    "BTC",
+		TokenAsk:    "ETH",
+		TickSize:    decimal.RequireFromString("0.5"),
+		LotSize:     decimal.NewFromInt(2),
+		MinNotional: decimal.NewFromInt(100),
+		Status:      PairActive,
+	}
+
+	order := validOrder()
+	order.Rate = decimal.RequireFromString("0.3")
+	order.MinVolume = decimal.NewFromInt(2)
+
+	var validationErr *ValidationError
+	if err := pair.CheckOrder(order); !errors.As(err, &validationErr) || len(validationErr.Fields) != 2 {
+		t.Fatalf("expected tick size and min notional errors, got %v", err)
+	}
+
+	pair.Status = PairHalted
+	if err := pair.CheckOrder(validOrder()); !errors.Is(err, ErrPairHalted) {
+		t.Fatalf("expected ErrPairHalted, got %v", err)
+	}
+}
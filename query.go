@@ -0,0 +1,89 @@
+package orderbook
+
+import (
+	"fmt"
+
+	"github.com/shopspring/decimal"
+)
+
+// SortKey is an order field which ListOrders sorts by
+type SortKey string
+
+const (
+	// SortById sorts orders by id
+	SortById SortKey = "id"
+	// SortByRate sorts orders by rate, orders with equal rate are sorted by priority in opposite direction,
+	// so descending order is the order of matching
+	SortByRate SortKey = "rate"
+	// SortByMaxVolume sorts orders by max volume and id
+	SortByMaxVolume SortKey = "max_volume"
+	// SortByMinVolume sorts orders by min volume and id
+	SortByMinVolume SortKey = "min_volume"
+)
+
+// Query is a filter, sort and page of ListOrders, zero fields don't filter orders.
+// Ranges are inclusive, volume range is a range of MaxVolume.
+type Query struct {
+	// TokenBid and TokenAsk select orders of pair direction tokenBid_tokenAsk, both or none of them must be set
+	TokenBid string `json:"token_bid,omitempty"`
+	TokenAsk string `json:"token_ask,omitempty"`
+	MakerId  string `json:"maker_id,omitempty"`
+
+	MinRate   *decimal.Decimal `json:"min_rate,omitempty"`
+	MaxRate   *decimal.Decimal `json:"max_rate,omitempty"`
+	MinVolume *decimal.Decimal `json:"min_volume,omitempty"`
+	MaxVolume *decimal.Decimal `json:"max_volume,omitempty"`
+
+	// Sort is SortById if empty
+	Sort       SortKey `json:"sort,omitempty"`
+	Descending bool    `json:"descending,omitempty"`
+
+	// Cursor is Page.NextCursor of the same query, empty Cursor is the first page
+	Cursor Cursor `json:"cursor,omitempty"`
+	// Limit is a maximal number of orders in page, non-positive Limit is DefaultPageLimit
+	Limit int `json:"limit,omitempty"`
+}
+
+// Validate checking query pair, ranges and sort key, returns ErrInvalidQuery
+func (q Query) Validate() error {
+	switch {
+	case (q.TokenBid == "") != (q.TokenAsk == ""):
+		return fmt.Errorf("%w: both or none of pair tokens must be set", ErrInvalidQuery)
+	case q.MinRate != nil && q.MaxRate != nil && q.MinRate.GreaterThan(*q.MaxRate):
+		return fmt.Errorf("%w: min rate is greater than max rate", ErrInvalidQuery)
+	case q.MinVolume != nil && q.MaxVolume != nil && q.MinVolume.GreaterThan(*q.MaxVolume):
+		return fmt.Errorf("%w: min volume is greater than max volume", ErrInvalidQuery)
+	}
+
+	switch q.Sort {
+	case "", SortById, SortByRate, SortByMaxVolume, SortByMinVolume:
+		return nil
+	default:
+		return fmt.Errorf("%w: unknown sort key %q", ErrInvalidQuery, q.Sort)
+	}
+}
+
+// HasPair checks that query selects orders of single pair direction
+func (q Query) HasPair() bool {
+	return q.TokenBid != ""
+}
+
+// Match checks that order passes query filters
+func (q Query) Match(order Order) bool {
+	switch {
+	case q.HasPair() && (order.TokenBid != q.TokenBid || order.TokenAsk != q.TokenAsk):
+		return false
+	case q.MakerId != "" && order.MakerId != q.MakerId:
+		return false
+	case q.MinRate != nil && order.Rate.LessThan(*q.MinRate):
+		return false
+	case q.MaxRate != nil && order.Rate.GreaterThan(*q.MaxRate):
+		return false
+	case q.MinVolume != nil && order.MaxVolume.LessThan(*q.MinVolume):
+		return false
+	case q.MaxVolume != nil && order.MaxVolume.GreaterThan(*q.MaxVolume):
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,60 @@
+package orderbook
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestQueryValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		query Query
+		valid bool
+	}{
+		{"empty", Query{}, true},
+		{"pair", Query{TokenBid: "BTC", TokenAsk: "ETH", Sort: SortByRate}, true},
+		{"single token", Query{TokenBid: "BTC"}, false},
+		{"equal rate bounds", Query{MinRate: decimalPtr("2"), MaxRate: decimalPtr("2")}, true},
+		{"reversed rate range", Query{MinRate: decimalPtr("3"), MaxRate: decimalPtr("2")}, false},
+		{"reversed volume range", Query{MinVolume: decimalPtr("3"), MaxVolume: decimalPtr("2")}, false},
+		{"unknown sort key", Query{Sort: "priority"}, false},
+	}
+
+	for _, test := range tests {
+		err := test.query.Validate()
+		if test.valid && err != nil {
+			t.Errorf("%s: expected valid query, got %v", test.name, err)
+		}
+		if !test.valid && !errors.Is(err, ErrInvalidQuery) {
+			t.Errorf("%s: expected ErrInvalidQuery, got %v", test.name, err)
+		}
+	}
+}
+
+func TestQueryMatch(t *testing.T) {
+	order := makerOrder("1", "2", "10", "1")
+
+	tests := []struct {
+		name  string
+		query Query
+		match bool
+	}{
+		{"empty", Query{}, true},
+		{"pair", Query{TokenBid: "ETH", TokenAsk: "BTC"}, true},
+		{"reversed pair", Query{TokenBid: "BTC", TokenAsk: "ETH"}, false},
+		{"maker", Query{MakerId: "maker1"}, true},
+		{"other maker", Query{MakerId: "maker2"}, false},
+		{"rate at bounds", Query{MinRate: decimalPtr("2"), MaxRate: decimalPtr("2")}, true},
+		{"rate below min", Query{MinRate: decimalPtr("2.5")}, false},
+		{"rate above max", Query{MaxRate: decimalPtr("1.5")}, false},
+		{"volume at bounds", Query{MinVolume: decimalPtr("10"), MaxVolume: decimalPtr("10")}, true},
+		{"volume below min", Query{MinVolume: decimalPtr("11")}, false},
+		{"volume above max", Query{MaxVolume: decimalPtr("9")}, false},
+	}
+
+	for _, test := range tests {
+		if got := test.query.Match(order); got != test.match {
+			t.Errorf("%s: expected match %v, got %v", test.name, test.match, got)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package orderbook
+
+import (
+	"fmt"
+
+	"github.com/shopspring/decimal"
+)
+
+// Quote is a result of matching taker order without changing the book,
+// taker gives TokenBid to get Amount of TokenAsk from maker orders of pair TokenAsk_TokenBid.
+type Quote struct {
+	TokenBid string          `json:"token_bid"`
+	TokenAsk string          `json:"token_ask"`
+	Amount   decimal.Decimal `json:"amount"`
+	// Fillable is an amount of TokenAsk which taker gets, it is less than Amount when the book is not deep enough
+	Fillable decimal.Decimal `json:"fillable"`
+	// Cost is an amount of TokenBid which taker gives
+	Cost decimal.Decimal `json:"cost"`
+	// AverageRate is a volume-weighted average rate of maker orders, Fillable divided by Cost
+	AverageRate decimal.Decimal `json:"average_rate"`
+	// WorstRate is the min rate of touched maker orders
+	WorstRate decimal.Decimal `json:"worst_rate"`
+	// Fills are fills of touched maker orders which MatchOrder would make
+	Fills []Fill `json:"fills"`
+}
+
+// NewQuote returns empty quote of amount of tokenAsk for tokenBid, returns ErrInvalidQuery if amount is not positive
+func NewQuote(tokenBid, tokenAsk string, amount decimal.Decimal) (Quote, error) {
+	if !amount.IsPositive() {
+		return Quote{}, fmt.Errorf("%w: amount must be positive", ErrInvalidQuery)
+	}
+
+	return Quote{TokenBid: tokenBid, TokenAsk: tokenAsk, Amount: amount, Fills: make([]Fill, 0)}, nil
+}
+
+// Take adding maker order of pair TokenAsk_TokenBid to quote, makers must be taken by best (max) rate first.
+// Makers which can't be filled with at least MinVolume are skipped, returns false when Amount is filled.
+func (q *Quote) Take(maker Order) bool {
+	remaining := q.Amount.Sub(q.Fillable)
+	if !remaining.IsPositive() {
+		return false
+	}
+
+	volume, received, ok := takeVolume(maker, remaining)
+	if ok {
+		q.Fills = append(q.Fills, maker.take(volume))
+		q.Fillable = q.Fillable.Add(received)
+		q.Cost = q.Cost.Add(volume)
+		q.AverageRate = q.Fillable.Div(q.Cost)
+		q.WorstRate = maker.Rate
+	}
+
+	return q.Fillable.LessThan(q.Amount)
+}
+
+// Filled checks that quote got Amount, the rest less than one unit of VolumePrecision of the worst maker
+// is left by rounding taken volume down, so it is filled too
+func (q Quote) Filled() bool {
+	rest := q.Amount.Sub(q.Fillable)
+	return !rest.IsPositive() || len(q.Fills) > 0 && rest.LessThan(decimal.New(1, -VolumePrecision).Mul(q.WorstRate))
+}
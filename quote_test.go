@@ -0,0 +1,103 @@
+package orderbook
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func quoteOf(t *testing.T, amount string, makers ...Order) (Quote, bool) {
+	t.Helper()
+
+	quote, err := NewQuote("BTC", "ETH", decimal.RequireFromString(amount))
+	if err != nil {
+		t.Fatalf("creating quote: %v", err)
+	}
+	more := true
+	for _, maker := range makers {
+		if more = quote.Take(maker); !more {
+			break
+		}
+	}
+
+	return quote, more
+}
+
+func TestQuoteTake(t *testing.T) {
+	makers := []Order{makerOrder("1", "3", "5", "1"), makerOrder("2", "2", "10", "8"), makerOrder("3", "2", "10", "1")}
+
+	// the first maker gives 15 ETH for 5 BTC, the rest 5 ETH are 2.5 BTC which is less than min volume of the second maker
+	quote, more := quoteOf(t, "20", makers...)
+	if more {
+		t.Error("expected filled quote")
+	}
+	if len(quote.Fills) != 2 || quote.Fills[0].Order.Id != "1" || quote.Fills[1].Order.Id != "3" {
+		t.Fatalf("expected fills of makers 1 and 3, got %v", quote.Fills)
+	}
+	requireDecimal(t, "fillable", quote.Fillable, "20")
+	requireDecimal(t, "cost", quote.Cost, "7.5")
+	requireDecimal(t, "worst rate", quote.WorstRate, "2")
+	if average := quote.AverageRate.StringFixed(4); average != "2.6667" {
+		t.Errorf("expected average rate 2.6667, got %s", average)
+	}
+
+	// book is not deep enough
+	quote, more = quoteOf(t, "100", makers...)
+	if !more {
+		t.Error("expected quote wanting more makers")
+	}
+	requireDecimal(t, "fillable", quote.Fillable, "55")
+	requireDecimal(t, "cost", quote.Cost, "25")
+	requireDecimal(t, "average rate", quote.AverageRate, "2.2")
+}
+
+func TestQuoteTakeRoundsVolumeDownLikeMatch(t *testing.T) {
+	maker := makerOrder("1", "3", "5", "0")
+
+	// 1 ETH is 1/3 BTC, quote never promises more than amount and takes the same volume as MatchOrders
+	quote, more := quoteOf(t, "1", maker)
+	if !more {
+		t.Error("expected quote wanting more makers for the rounded rest")
+	}
+	if len(quote.Fills) != 1 {
+		t.Fatalf("expected a single fill, got %v", quote.Fills)
+	}
+	requireDecimal(t, "fill volume", quote.Fills[0].Volume, "0.333333333333333333")
+	requireDecimal(t, "fillable", quote.Fillable, "0.999999999999999999")
+	requireDecimal(t, "cost", quote.Cost, "0.333333333333333333")
+	requireDecimal(t, "worst rate", quote.WorstRate, "3")
+
+	result := MatchOrders(takerOrder("1", "1"), []Order{maker})
+	if len(result.Fills) != 1 || !result.Fills[0].Volume.Equal(quote.Fills[0].Volume) {
+		t.Fatalf("expected match fill of quoted volume, got %v", result.Fills)
+	}
+}
+
+func TestNewQuoteOfInvalidAmount(t *testing.T) {
+	for _, amount := range []string{"0", "-1"} {
+		if _, err := NewQuote("BTC", "ETH", decimal.RequireFromString(amount)); !errors.Is(err, ErrInvalidQuery) {
+			t.Errorf("expected ErrInvalidQuery of amount %s, got %v", amount, err)
+		}
+	}
+}
+
+func TestQuoteFilled(t *testing.T) {
+	tests := []struct {
+		name   string
+		amount string
+		makers []Order
+		filled bool
+	}{
+		{"exact", "20", []Order{makerOrder("1", "2", "10", "0")}, true},
+		{"rest rounded down", "1", []Order{makerOrder("1", "3", "5", "0")}, true},
+		{"not deep enough", "30", []Order{makerOrder("1", "2", "10", "0")}, false},
+		{"no makers", "1", nil, false},
+	}
+
+	for _, test := range tests {
+		if quote, _ := quoteOf(t, test.amount, test.makers...); quote.Filled() != test.filled {
+			t.Errorf("%s: expected filled %v, got %v", test.name, test.filled, quote)
+		}
+	}
+}
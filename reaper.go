@@ -0,0 +1,49 @@
+package orderbook
+
+import (
+	"context"
+	"time"
+)
+
+// Reaper periodically removes expired orders from orderbook,
+// orders are expired by the clock of orderbook, reaper clock is only used for waiting.
+type Reaper struct {
+	book     OrderBook
+	interval time.Duration
+	clock    Clock
+	// OnError is called when removing expired orders fails, reaper keeps running after errors
+	OnError func(err error)
+	// OnReap is called after every successful run with number of removed orders
+	OnReap func(removed int)
+}
+
+func NewReaper(book OrderBook, interval time.Duration, clock Clock) *Reaper {
+	if clock == nil {
+		clock = SystemClock{}
+	}
+
+	return &Reaper{book: book, interval: interval, clock: clock}
+}
+
+// Run removing expired orders every interval until ctx is done, returns ctx error
+func (r *Reaper) Run(ctx context.Context) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-r.clock.After(r.interval):
+		}
+
+		removed, err := r.book.RemoveExpiredOrders(ctx)
+		if err != nil {
+			if r.OnError != nil {
+				r.OnError(err)
+			}
+			continue
+		}
+
+		if r.OnReap != nil {
+			r.OnReap(removed)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package orderbook_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/SashaBokov/orderbook"
+	"github.com/SashaBokov/orderbook/internal/booktest"
+	"github.com/SashaBokov/orderbook/repository/memory"
+)
+
+func TestReaperRemovesExpiredOrders(t *testing.T) {
+	clock := booktest.NewClock()
+	book := memory.New(orderbook.WithClock(clock))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	expiresAt := clock.Now().Add(time.Minute)
+	expiring := booktest.Order("1", 2, 10)
+	expiring.ExpiresAt = &expiresAt
+	booktest.AddOrders(t, book, expiring, booktest.Order("2", 2, 10))
+
+	reaper := orderbook.NewReaper(book, time.Second, clock)
+	reaped := make(chan int)
+	reaper.OnReap = func(removed int) { reaped <- removed }
+	done := make(chan error)
+	go func() { done <- reaper.Run(ctx) }()
+
+	clock.Ticks <- clock.Now()
+	if removed := <-reaped; removed != 0 {
+		t.Fatalf("expected nothing removed before expiry, got %d", removed)
+	}
+
+	clock.Advance(2 * time.Minute)
+	clock.Ticks <- clock.Now()
+	if removed := <-reaped; removed != 1 {
+		t.Fatalf("expected expired order removed, got %d", removed)
+	}
+	history, err := book.GetOrderHistory(ctx, "1")
+	if err != nil || history[len(history)-1].Status != orderbook.OrderExpired {
+		t.Fatalf("expected expired status in history, got %v, %v", history, err)
+	}
+	if _, err := book.GetOrderById(ctx, "2"); err != nil {
+		t.Fatalf("expected order without expiry kept, got %v", err)
+	}
+
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
@@ -0,0 +1,806 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/SashaBokov/orderbook"
+	"github.com/google/btree"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+// Check that Book implements orderbook.OrderBook
+var _ = orderbook.OrderBook(&Book{})
+
+// Book is an in-memory orderbook safe for concurrent use, orders are checked with options validator,
+// methods return ctx error without touching the book if ctx is already done.
+// Expired orders are kept in the book and skipped by queries until RemoveExpiredOrders is called.
+type Book struct {
+	options orderbook.Options
+	events  *orderbook.Broker // events are prepared under write lock and published after unlock
+	journal *journal          // journal of Book opened with Open, nil for New
+
+	mu       sync.RWMutex
+	priority int64 // last assigned order priority
+	orders   map[string]orderbook.Order
+	all      *orderIndex                    // index of orders of every pair
+	makers   map[string]*orderIndex         // indexes of orders by maker id
+	byExpiry *btree.BTreeG[orderbook.Order] // orders with ExpiresAt
+	pairs    map[pair]*orderIndex
+	registry map[pair]orderbook.Pair             // registered pairs by tokens of both directions
+	history  map[string][]orderbook.StatusChange // status changes of live and closed orders
+
+	trades        []orderbook.Trade // trade with id n is trades[n-1]
+	tradesByPair  map[pair][]int    // trade positions by tokens of pair sorted with tradePair
+	tradesByMaker map[string][]int  // trade positions by maker id
+}
+
+func New(opts ...orderbook.Option) *Book {
+	return &Book{
+		options:  orderbook.NewOptions(opts...),
+		events:   orderbook.NewBroker(),
+		orders:   make(map[string]orderbook.Order),
+		all:      newOrderIndex(),
+		makers:   make(map[string]*orderIndex),
+		byExpiry: btree.NewG(degree, lessByExpiry),
+		pairs:    make(map[pair]*orderIndex),
+		registry: make(map[pair]orderbook.Pair),
+		history:  make(map[string][]orderbook.StatusChange),
+
+		trades:        make([]orderbook.Trade, 0),
+		tradesByPair:  make(map[pair][]int),
+		tradesByMaker: make(map[string][]int),
+	}
+}
+
+// AddNewPair adding new pair to orderbook, orders of both pair directions may be added then
+func (b *Book) AddNewPair(ctx context.Context, newPair orderbook.Pair) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := newPair.Validate(); err != nil {
+		return err
+	}
+	if newPair.Status == "" {
+		newPair.Status = orderbook.PairActive
+	}
+
+	var batch orderbook.EventBatch
+	defer b.publish(&batch)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.registry[pair{newPair.TokenBid, newPair.TokenAsk}]; ok {
+		return errors.Wrapf(orderbook.ErrPairExists, "pair %s_%s", newPair.TokenBid, newPair.TokenAsk)
+	}
+
+	now := b.options.Clock.Now()
+	if err := b.log(journalEntry{Op: opAddNewPair, Time: now, Pair: &newPair}); err != nil {
+		return err
+	}
+
+	for _, p := range []pair{{newPair.TokenBid, newPair.TokenAsk}, {newPair.TokenAsk, newPair.TokenBid}} {
+		b.registry[p] = newPair
+		b.pairs[p] = newOrderIndex()
+	}
+	batch = b.events.Prepare(orderbook.NewPairEvent(orderbook.EventPairAdded, newPair, now))
+
+	return nil
+}
+
+// AddOrder adding new order to orderbook
+func (b *Book) AddOrder(ctx context.Context, order orderbook.Order) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := b.options.Validator.Validate(order); err != nil {
+		return err
+	}
+
+	var batch orderbook.EventBatch
+	defer b.publish(&batch)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// time is read under lock, so journal replay sees the same expiry
+	now := b.options.Clock.Now()
+	if order.Expired(now) {
+		return &orderbook.ValidationError{Fields: []orderbook.FieldError{{Field: "expires_at", Reason: "must be in the future"}}}
+	}
+
+	registered, ok := b.registry[pair{order.TokenBid, order.TokenAsk}]
+	if !ok {
+		return errors.Wrapf(orderbook.ErrPairNotFound, "pair %s_%s", order.TokenBid, order.TokenAsk)
+	}
+	if err := registered.CheckOrder(order); err != nil {
+		return err
+	}
+
+	if _, ok := b.history[order.Id]; ok {
+		return errors.Wrapf(orderbook.ErrDuplicateOrder, "order %s", order.Id)
+	}
+
+	if err := b.log(journalEntry{Op: opAddOrder, Time: now, Order: &order}); err != nil {
+		return err
+	}
+
+	b.priority++
+	order.Priority = b.priority
+	order.Status = orderbook.OrderOpen
+	b.insert(b.pairs[pair{order.TokenBid, order.TokenAsk}], order)
+	b.record(order, now)
+	batch = b.events.Prepare(orderbook.NewOrderEvent(orderbook.EventOrderAdded, order, nil, now))
+
+	return nil
+}
+
+// UpdatePair updating metadata and status of existing pair
+func (b *Book) UpdatePair(ctx context.Context, updated orderbook.Pair) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := updated.Validate(); err != nil {
+		return err
+	}
+	if updated.Status == "" {
+		updated.Status = orderbook.PairActive
+	}
+
+	var batch orderbook.EventBatch
+	defer b.publish(&batch)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	registered, ok := b.registry[pair{updated.TokenBid, updated.TokenAsk}]
+	if !ok {
+		return errors.Wrapf(orderbook.ErrPairNotFound, "pair %s_%s", updated.TokenBid, updated.TokenAsk)
+	}
+
+	now := b.options.Clock.Now()
+	if err := b.log(journalEntry{Op: opUpdatePair, Time: now, Pair: &updated}); err != nil {
+		return err
+	}
+
+	updated.TokenBid, updated.TokenAsk = registered.TokenBid, registered.TokenAsk
+	b.registry[pair{updated.TokenBid, updated.TokenAsk}] = updated
+	b.registry[pair{updated.TokenAsk, updated.TokenBid}] = updated
+	batch = b.events.Prepare(orderbook.NewPairEvent(orderbook.EventPairUpdated, updated, now))
+
+	return nil
+}
+
+// GetPair getting pair by tokens of any direction
+func (b *Book) GetPair(ctx context.Context, tokenBid, tokenAsk string) (orderbook.Pair, error) {
+	if err := ctx.Err(); err != nil {
+		return orderbook.Pair{}, err
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	registered, ok := b.registry[pair{tokenBid, tokenAsk}]
+	if !ok {
+		return orderbook.Pair{}, errors.Wrapf(orderbook.ErrPairNotFound, "pair %s_%s", tokenBid, tokenAsk)
+	}
+
+	return registered, nil
+}
+
+// PairExists checking that pair with tokens of any direction exists
+func (b *Book) PairExists(ctx context.Context, tokenBid, tokenAsk string) (bool, error) {
+	if _, err := b.GetPair(ctx, tokenBid, tokenAsk); err != nil {
+		if errors.Is(err, orderbook.ErrPairNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+// ListPairs getting all pairs of orderbook ordered by tokens
+func (b *Book) ListPairs(ctx context.Context) ([]orderbook.Pair, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	pairs := make([]orderbook.Pair, 0, len(b.registry)/2)
+	for key, registered := range b.registry {
+		if key.tokenBid == registered.TokenBid {
+			pairs = append(pairs, registered)
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].TokenBid != pairs[j].TokenBid {
+			return pairs[i].TokenBid < pairs[j].TokenBid
+		}
+		return pairs[i].TokenAsk < pairs[j].TokenAsk
+	})
+
+	return pairs, nil
+}
+
+// MatchOrder matching taker order against orders of opposite pair with best rate, taken orders are filled or removed
+func (b *Book) MatchOrder(ctx context.Context, taker orderbook.Order) (orderbook.MatchResult, error) {
+	if err := ctx.Err(); err != nil {
+		return orderbook.MatchResult{}, err
+	}
+
+	if err := b.options.Validator.Validate(taker); err != nil {
+		return orderbook.MatchResult{}, err
+	}
+
+	var batch orderbook.EventBatch
+	defer b.publish(&batch)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	index, ok := b.pairs[pair{taker.TokenAsk, taker.TokenBid}]
+	if !ok {
+		return orderbook.MatchResult{}, errors.Wrapf(orderbook.ErrPairNotFound, "pair %s_%s", taker.TokenAsk, taker.TokenBid)
+	}
+	if registered := b.registry[pair{taker.TokenAsk, taker.TokenBid}]; registered.Status == orderbook.PairHalted {
+		return orderbook.MatchResult{}, errors.Wrapf(orderbook.ErrPairHalted, "pair %s_%s", registered.TokenBid, registered.TokenAsk)
+	}
+
+	now := b.options.Clock.Now()
+	makers := make([]orderbook.Order, 0)
+	index.byRate.Descend(func(maker orderbook.Order) bool {
+		if !orderbook.Crosses(taker, maker) {
+			return false
+		}
+		if maker.Expired(now) {
+			return true
+		}
+
+		makers = append(makers, maker)
+		return true
+	})
+
+	result := orderbook.MatchOrders(taker, makers)
+	if len(result.Fills) == 0 {
+		return result, nil
+	}
+
+	if err := b.log(journalEntry{Op: opMatchOrder, Time: now, Order: &taker}); err != nil {
+		return orderbook.MatchResult{}, err
+	}
+
+	events := make([]orderbook.Event, 0, len(result.Fills))
+	for _, fill := range result.Fills {
+		b.applyFill(fill, now)
+		trade := b.addTrade(orderbook.NewTrade(taker, fill, now))
+		result.Trades = append(result.Trades, trade)
+		events = append(events, orderbook.NewOrderEvent(orderbook.EventOrderFilled, fill.Order, &trade, now))
+	}
+	batch = b.events.Prepare(events...)
+
+	return result, nil
+}
+
+// Quote walking orders of opposite pair like MatchOrder to get amount of tokenAsk for tokenBid, the book isn't changed
+func (b *Book) Quote(ctx context.Context, tokenBid, tokenAsk string, amount decimal.Decimal) (orderbook.Quote, error) {
+	if err := ctx.Err(); err != nil {
+		return orderbook.Quote{}, err
+	}
+
+	quote, err := orderbook.NewQuote(tokenBid, tokenAsk, amount)
+	if err != nil {
+		return orderbook.Quote{}, err
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	index, ok := b.pairs[pair{tokenAsk, tokenBid}]
+	if !ok {
+		return orderbook.Quote{}, errors.Wrapf(orderbook.ErrPairNotFound, "pair %s_%s", tokenAsk, tokenBid)
+	}
+	if registered := b.registry[pair{tokenAsk, tokenBid}]; registered.Status == orderbook.PairHalted {
+		return orderbook.Quote{}, errors.Wrapf(orderbook.ErrPairHalted, "pair %s_%s", registered.TokenBid, registered.TokenAsk)
+	}
+
+	now := b.options.Clock.Now()
+	index.byRate.Descend(func(maker orderbook.Order) bool {
+		return maker.Expired(now) || quote.Take(maker)
+	})
+
+	return quote, nil
+}
+
+// AmendOrder changing rate and volumes of order in place, order loses time priority when rate is changed or max volume is increased
+func (b *Book) AmendOrder(ctx context.Context, orderId string, amendment orderbook.Amendment) (orderbook.Order, error) {
+	if err := ctx.Err(); err != nil {
+		return orderbook.Order{}, err
+	}
+
+	var batch orderbook.EventBatch
+	defer b.publish(&batch)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.options.Clock.Now()
+	order, ok := b.alive(orderId, now)
+	if !ok {
+		return orderbook.Order{}, errors.Wrapf(orderbook.ErrOrderNotFound, "order %s", orderId)
+	}
+
+	amended, losesPriority := amendment.Apply(order)
+	if err := b.options.Validator.Validate(amended); err != nil {
+		return orderbook.Order{}, err
+	}
+	if err := b.registry[pair{order.TokenBid, order.TokenAsk}].CheckOrder(amended); err != nil {
+		return orderbook.Order{}, err
+	}
+
+	if err := b.log(journalEntry{Op: opAmendOrder, Time: now, OrderId: orderId, Amendment: &amendment}); err != nil {
+		return orderbook.Order{}, err
+	}
+
+	if losesPriority {
+		b.priority++
+		amended.Priority = b.priority
+	}
+	b.remove(order)
+	b.insert(b.pairs[pair{order.TokenBid, order.TokenAsk}], amended)
+	batch = b.events.Prepare(orderbook.NewOrderEvent(orderbook.EventOrderUpdated, amended, nil, now))
+
+	return amended, nil
+}
+
+// FillOrder taking volume of order, order is closed and removed when the rest is less than MinVolume
+func (b *Book) FillOrder(ctx context.Context, orderId string, volume decimal.Decimal) (orderbook.Order, error) {
+	if err := ctx.Err(); err != nil {
+		return orderbook.Order{}, err
+	}
+
+	var batch orderbook.EventBatch
+	defer b.publish(&batch)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.options.Clock.Now()
+	order, ok := b.alive(orderId, now)
+	if !ok {
+		return orderbook.Order{}, errors.Wrapf(orderbook.ErrOrderNotFound, "order %s", orderId)
+	}
+
+	fill, err := order.Take(volume)
+	if err != nil {
+		return orderbook.Order{}, err
+	}
+
+	if err := b.log(journalEntry{Op: opFillOrder, Time: now, OrderId: orderId, Volume: &volume}); err != nil {
+		return orderbook.Order{}, err
+	}
+
+	b.applyFill(fill, now)
+	trade := b.addTrade(orderbook.NewTrade(orderbook.Order{}, fill, now))
+	batch = b.events.Prepare(orderbook.NewOrderEvent(orderbook.EventOrderFilled, fill.Order, &trade, now))
+
+	return fill.Order, nil
+}
+
+// GetOrderById getting order from orderbook
+func (b *Book) GetOrderById(ctx context.Context, orderId string) (orderbook.Order, error) {
+	if err := ctx.Err(); err != nil {
+		return orderbook.Order{}, err
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	order, ok := b.alive(orderId, b.options.Clock.Now())
+	if !ok {
+		return orderbook.Order{}, errors.Wrapf(orderbook.ErrOrderNotFound, "order %s", orderId)
+	}
+
+	return order, nil
+}
+
+// GetOrderWithMaxRate getting order from orderbook with max rate
+func (b *Book) GetOrderWithMaxRate(ctx context.Context, tokenBid, tokenAsk string) (orderbook.Order, error) {
+	return b.first(ctx, orderbook.Query{TokenBid: tokenBid, TokenAsk: tokenAsk, Sort: orderbook.SortByRate, Descending: true})
+}
+
+// GetOrderWithMinRate getting order from orderbook with min rate
+func (b *Book) GetOrderWithMinRate(ctx context.Context, tokenBid, tokenAsk string) (orderbook.Order, error) {
+	return b.first(ctx, orderbook.Query{TokenBid: tokenBid, TokenAsk: tokenAsk, Sort: orderbook.SortByRate})
+}
+
+// GetOrderWithMaxVolume getting order from orderbook with max volume
+func (b *Book) GetOrderWithMaxVolume(ctx context.Context, tokenBid, tokenAsk string) (orderbook.Order, error) {
+	return b.first(ctx, orderbook.Query{TokenBid: tokenBid, TokenAsk: tokenAsk, Sort: orderbook.SortByMaxVolume, Descending: true})
+}
+
+// GetOrderWithMinVolume getting order from orderbook with min volume
+func (b *Book) GetOrderWithMinVolume(ctx context.Context, tokenBid, tokenAsk string) (orderbook.Order, error) {
+	return b.first(ctx, orderbook.Query{TokenBid: tokenBid, TokenAsk: tokenAsk, Sort: orderbook.SortByMinVolume})
+}
+
+// GetTopOfBook getting the best orders of both pair directions with spread and mid rate in tokenBid per tokenAsk
+func (b *Book) GetTopOfBook(ctx context.Context, tokenBid, tokenAsk string) (orderbook.TopOfBook, error) {
+	if err := ctx.Err(); err != nil {
+		return orderbook.TopOfBook{}, err
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	index, ok := b.pairs[pair{tokenBid, tokenAsk}]
+	if !ok {
+		return orderbook.TopOfBook{}, errors.Wrapf(orderbook.ErrPairNotFound, "pair %s_%s", tokenBid, tokenAsk)
+	}
+
+	now := b.options.Clock.Now()
+	return orderbook.NewTopOfBook(tokenBid, tokenAsk, best(index, now), best(b.pairs[pair{tokenAsk, tokenBid}], now)), nil
+}
+
+// ListOrders getting page of orders matching query q, orders of any pairs and makers are listed if q doesn't select them.
+// Query is served by tree of sort key of pair index, maker index or index of all orders, so page is found in O(limit log N)
+// when filters other than pair and maker don't skip orders.
+func (b *Book) ListOrders(ctx context.Context, q orderbook.Query) (orderbook.Page, error) {
+	if err := ctx.Err(); err != nil {
+		return orderbook.Page{}, err
+	}
+
+	if err := q.Validate(); err != nil {
+		return orderbook.Page{}, err
+	}
+	key := querySortKey(q)
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	now := b.options.Clock.Now()
+	if q.HasPair() {
+		index, ok := b.pairs[pair{q.TokenBid, q.TokenAsk}]
+		if !ok {
+			return orderbook.Page{}, errors.Wrapf(orderbook.ErrPairNotFound, "pair %s_%s", q.TokenBid, q.TokenAsk)
+		}
+
+		return pageOfTree(key.tree(index), key, q, now)
+	}
+
+	index := b.all
+	if q.MakerId != "" {
+		if index = b.makers[q.MakerId]; index == nil {
+			index = newOrderIndex()
+		}
+	}
+
+	return pageOfTree(key.tree(index), key, q, now)
+}
+
+// GetDepth getting at most levels best rate levels of both pair directions, orders are grouped by rate rounded down to tick
+func (b *Book) GetDepth(ctx context.Context, tokenBid, tokenAsk string, levels int, tick decimal.Decimal) (orderbook.Depth, error) {
+	if err := ctx.Err(); err != nil {
+		return orderbook.Depth{}, err
+	}
+
+	if err := orderbook.CheckDepth(tick); err != nil {
+		return orderbook.Depth{}, err
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if _, ok := b.registry[pair{tokenBid, tokenAsk}]; !ok {
+		return orderbook.Depth{}, errors.Wrapf(orderbook.ErrPairNotFound, "pair %s_%s", tokenBid, tokenAsk)
+	}
+
+	now := b.options.Clock.Now()
+	return orderbook.Depth{
+		TokenBid:      tokenBid,
+		TokenAsk:      tokenAsk,
+		Levels:        depth(b.pairs[pair{tokenBid, tokenAsk}], now, levels, tick),
+		ReverseLevels: depth(b.pairs[pair{tokenAsk, tokenBid}], now, levels, tick),
+	}, nil
+}
+
+// RemovePair removing pair from orderbook
+func (b *Book) RemovePair(ctx context.Context, tokenBid, tokenAsk string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var batch orderbook.EventBatch
+	defer b.publish(&batch)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	registered, ok := b.registry[pair{tokenBid, tokenAsk}]
+	if !ok {
+		return errors.Wrapf(orderbook.ErrPairNotFound, "pair %s_%s", tokenBid, tokenAsk)
+	}
+
+	now := b.options.Clock.Now()
+	if err := b.log(journalEntry{Op: opRemovePair, Time: now, TokenBid: tokenBid, TokenAsk: tokenAsk}); err != nil {
+		return err
+	}
+
+	events := make([]orderbook.Event, 0)
+
+	for _, p := range []pair{{tokenBid, tokenAsk}, {tokenAsk, tokenBid}} {
+		index := b.pairs[p]
+		orders := make([]orderbook.Order, 0, index.byId.Len())
+		index.byId.Ascend(func(order orderbook.Order) bool {
+			orders = append(orders, order)
+			return true
+		})
+		for _, order := range orders {
+			events = append(events, b.close(order, orderbook.OrderCancelled, now))
+		}
+		delete(b.pairs, p)
+		delete(b.registry, p)
+	}
+	events = append(events, orderbook.NewPairEvent(orderbook.EventPairRemoved, registered, now))
+	batch = b.events.Prepare(events...)
+
+	return nil
+}
+
+// RemoveOrder removing order from orderbook, order is kept in history with OrderCancelled status
+func (b *Book) RemoveOrder(ctx context.Context, orderId string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var batch orderbook.EventBatch
+	defer b.publish(&batch)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.options.Clock.Now()
+	order, ok := b.alive(orderId, now)
+	if !ok {
+		return errors.Wrapf(orderbook.ErrOrderNotFound, "order %s", orderId)
+	}
+
+	if err := b.log(journalEntry{Op: opRemoveOrder, Time: now, OrderId: orderId}); err != nil {
+		return err
+	}
+
+	batch = b.events.Prepare(b.close(order, orderbook.OrderCancelled, now))
+
+	return nil
+}
+
+// RemoveExpiredOrders removing orders expired by orderbook clock with OrderExpired status, returns number of removed orders
+func (b *Book) RemoveExpiredOrders(ctx context.Context) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	var batch orderbook.EventBatch
+	defer b.publish(&batch)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.options.Clock.Now()
+	expired := make([]orderbook.Order, 0)
+	b.byExpiry.Ascend(func(order orderbook.Order) bool {
+		if !order.Expired(now) {
+			return false
+		}
+
+		expired = append(expired, order)
+		return true
+	})
+
+	if len(expired) == 0 {
+		return 0, nil
+	}
+
+	if err := b.log(journalEntry{Op: opRemoveExpiredOrders, Time: now}); err != nil {
+		return 0, err
+	}
+
+	events := make([]orderbook.Event, 0, len(expired))
+	for _, order := range expired {
+		events = append(events, b.close(order, orderbook.OrderExpired, now))
+	}
+	batch = b.events.Prepare(events...)
+
+	return len(expired), nil
+}
+
+// GetOrderHistory getting status changes of order in chronological order, removed orders have history too
+func (b *Book) GetOrderHistory(ctx context.Context, orderId string) ([]orderbook.StatusChange, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	history, ok := b.history[orderId]
+	if !ok {
+		return nil, errors.Wrapf(orderbook.ErrOrderNotFound, "order %s", orderId)
+	}
+
+	return append([]orderbook.StatusChange(nil), history...), nil
+}
+
+// GetTrade getting trade by id
+func (b *Book) GetTrade(ctx context.Context, tradeId int64) (orderbook.Trade, error) {
+	if err := ctx.Err(); err != nil {
+		return orderbook.Trade{}, err
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if tradeId < 1 || tradeId > int64(len(b.trades)) {
+		return orderbook.Trade{}, errors.Wrapf(orderbook.ErrTradeNotFound, "trade %d", tradeId)
+	}
+
+	return b.trades[tradeId-1], nil
+}
+
+// ListTradesByPair getting trades of both pair directions in time range
+func (b *Book) ListTradesByPair(ctx context.Context, tokenBid, tokenAsk string, period orderbook.TimeRange, cursor orderbook.Cursor, limit int) (orderbook.TradePage, error) {
+	return b.listTrades(ctx, period, cursor, limit, func() []int {
+		return b.tradesByPair[tradePair(tokenBid, tokenAsk)]
+	})
+}
+
+// ListTradesByMaker getting trades of maker orders in time range
+func (b *Book) ListTradesByMaker(ctx context.Context, makerId string, period orderbook.TimeRange, cursor orderbook.Cursor, limit int) (orderbook.TradePage, error) {
+	return b.listTrades(ctx, period, cursor, limit, func() []int {
+		return b.tradesByMaker[makerId]
+	})
+}
+
+// Subscribe getting subscription of book changes selected by filter
+func (b *Book) Subscribe(filter orderbook.EventFilter) *orderbook.Subscription {
+	return b.events.Subscribe(filter)
+}
+
+// alive getting order which is not expired at now, caller must hold lock
+func (b *Book) alive(orderId string, now time.Time) (orderbook.Order, bool) {
+	order, ok := b.orders[orderId]
+	if !ok || order.Expired(now) {
+		return orderbook.Order{}, false
+	}
+
+	return order, true
+}
+
+// insert adding order to orders and indexes, caller must hold write lock
+func (b *Book) insert(index *orderIndex, order orderbook.Order) {
+	b.orders[order.Id] = order
+	if order.ExpiresAt != nil {
+		b.byExpiry.ReplaceOrInsert(order)
+	}
+	index.insert(order)
+	b.all.insert(order)
+	maker, ok := b.makers[order.MakerId]
+	if !ok {
+		maker = newOrderIndex()
+		b.makers[order.MakerId] = maker
+	}
+	maker.insert(order)
+}
+
+// applyFill updating remaining volume and status of filled order, closed order is removed, caller must hold write lock
+func (b *Book) applyFill(fill orderbook.Fill, now time.Time) {
+	b.remove(b.orders[fill.Order.Id])
+	if !fill.Closed {
+		b.insert(b.pairs[pair{fill.Order.TokenBid, fill.Order.TokenAsk}], fill.Order)
+	}
+	b.record(fill.Order, now)
+}
+
+// addTrade assigning id to trade and adding it to trades, caller must hold write lock
+func (b *Book) addTrade(trade orderbook.Trade) orderbook.Trade {
+	position := len(b.trades)
+	trade.Id = int64(position + 1)
+	b.trades = append(b.trades, trade)
+
+	key := tradePair(trade.TokenBid, trade.TokenAsk)
+	b.tradesByPair[key] = append(b.tradesByPair[key], position)
+	b.tradesByMaker[trade.MakerId] = append(b.tradesByMaker[trade.MakerId], position)
+
+	return trade
+}
+
+// listTrades returning page of trades at positions selected under read lock in time range after cursor
+func (b *Book) listTrades(ctx context.Context, period orderbook.TimeRange, cursor orderbook.Cursor, limit int, selectPositions func() []int) (orderbook.TradePage, error) {
+	if err := ctx.Err(); err != nil {
+		return orderbook.TradePage{}, err
+	}
+
+	var after int64
+	if cursor != "" {
+		position, err := cursor.Position()
+		if err != nil {
+			return orderbook.TradePage{}, err
+		}
+		after = position.Seq
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	limit = orderbook.PageLimit(limit)
+	result := orderbook.TradePage{Trades: make([]orderbook.Trade, 0)}
+	positions := selectPositions()
+	// trade with id n is at position n-1, so trades after cursor start from position of id after
+	start := sort.SearchInts(positions, int(after))
+	for _, position := range positions[start:] {
+		trade := b.trades[position]
+		if !period.Contains(trade.Time) {
+			continue
+		}
+		if len(result.Trades) == limit {
+			result.NextCursor = orderbook.Position{Seq: result.Trades[limit-1].Id}.Cursor()
+			break
+		}
+
+		result.Trades = append(result.Trades, trade)
+	}
+
+	return result, nil
+}
+
+// close removing order from book with status and returning its removal event, caller must hold write lock
+func (b *Book) close(order orderbook.Order, status orderbook.OrderStatus, now time.Time) orderbook.Event {
+	b.remove(order)
+	order.Status = status
+	b.record(order, now)
+
+	return orderbook.NewOrderEvent(orderbook.EventOrderRemoved, order, nil, now)
+}
+
+// publish publishing batch prepared under write lock, it is deferred before locking to run after unlock
+func (b *Book) publish(batch *orderbook.EventBatch) {
+	b.events.Publish(*batch)
+}
+
+// record adding current order status at now to order history, caller must hold write lock
+func (b *Book) record(order orderbook.Order, now time.Time) {
+	b.history[order.Id] = append(b.history[order.Id], orderbook.NewStatusChange(order, now))
+}
+
+// remove removing order from orders and indexes, caller must hold write lock
+func (b *Book) remove(order orderbook.Order) {
+	delete(b.orders, order.Id)
+	if order.ExpiresAt != nil {
+		b.byExpiry.Delete(order)
+	}
+	if index, ok := b.pairs[pair{order.TokenBid, order.TokenAsk}]; ok {
+		index.delete(order)
+	}
+	b.all.delete(order)
+	if maker, ok := b.makers[order.MakerId]; ok {
+		maker.delete(order)
+		if maker.empty() {
+			delete(b.makers, order.MakerId)
+		}
+	}
+}
+
+// first returning the first order of pair listed by query
+func (b *Book) first(ctx context.Context, q orderbook.Query) (orderbook.Order, error) {
+	q.Limit = 1
+	result, err := b.ListOrders(ctx, q)
+	if err != nil {
+		return orderbook.Order{}, err
+	}
+
+	if len(result.Orders) == 0 {
+		return orderbook.Order{}, errors.Wrap(orderbook.ErrOrderNotFound, "no orders with this pair")
+	}
+
+	return result.Orders[0], nil
+}
@@ -0,0 +1,73 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/SashaBokov/orderbook"
+	"github.com/SashaBokov/orderbook/internal/booktest"
+)
+
+func TestBook(t *testing.T) {
+	booktest.Run(t, func(t *testing.T, opts ...orderbook.Option) orderbook.OrderBook {
+		return New(opts...)
+	})
+}
+
+func TestConcurrentAddAndRead(t *testing.T) {
+	b := New()
+	ctx := context.Background()
+	booktest.AddOrders(t, b)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				if err := b.AddOrder(ctx, booktest.Order(fmt.Sprintf("%d-%d", i, j), int64(1+j), 10)); err != nil {
+					t.Errorf("adding order: %v", err)
+					return
+				}
+				if _, err := b.GetOrderWithMaxRate(ctx, "BTC", "ETH"); err != nil {
+					t.Errorf("getting order: %v", err)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	page, err := b.ListOrders(ctx, orderbook.Query{TokenBid: "BTC", TokenAsk: "ETH", Limit: 1000})
+	if err != nil || len(page.Orders) != 400 {
+		t.Fatalf("expected 400 orders, got %d, %v", len(page.Orders), err)
+	}
+}
+
+func TestIndexesFollowOrders(t *testing.T) {
+	b := New()
+	ctx := context.Background()
+	other := booktest.Order("2", 2, 10)
+	other.MakerId = "other"
+	booktest.AddOrders(t, b, booktest.Order("1", 2, 10), other, booktest.MakerOrder("3", 2, 10))
+
+	if b.all.byId.Len() != 3 || b.makers["maker"].byId.Len() != 2 || b.makers["other"].byId.Len() != 1 {
+		t.Fatalf("expected 3 orders in book index, 2 of maker and 1 of other maker")
+	}
+
+	// index of maker is dropped with the last maker order, orders of removed pair leave every index
+	if err := b.RemoveOrder(ctx, "2"); err != nil {
+		t.Fatalf("removing order: %v", err)
+	}
+	if _, ok := b.makers["other"]; ok {
+		t.Fatalf("expected index of other maker dropped")
+	}
+	if err := b.RemovePair(ctx, "BTC", "ETH"); err != nil {
+		t.Fatalf("removing pair: %v", err)
+	}
+	if b.all.byId.Len() != 0 || len(b.makers) != 0 {
+		t.Fatalf("expected empty indexes, got %d orders and %d makers", b.all.byId.Len(), len(b.makers))
+	}
+}
@@ -0,0 +1,144 @@
+package memory
+
+import (
+	"time"
+
+	"github.com/SashaBokov/orderbook"
+	"github.com/google/btree"
+	"github.com/shopspring/decimal"
+)
+
+// degree is a degree of btrees used by order indexes
+const degree = 32
+
+// pair is a key of pair index, pair a_b and pair b_a are different keys
+type pair struct {
+	tokenBid string
+	tokenAsk string
+}
+
+// tradePair returning key of trades of both pair directions
+func tradePair(tokenBid, tokenAsk string) pair {
+	if tokenAsk < tokenBid {
+		tokenBid, tokenAsk = tokenAsk, tokenBid
+	}
+
+	return pair{tokenBid, tokenAsk}
+}
+
+// orderIndex is a set of ordered trees of orders, book keeps index of every pair direction,
+// the same as composite pair indexes of orders table in postgres, index of every maker and index of all orders.
+type orderIndex struct {
+	byId        *btree.BTreeG[orderbook.Order]
+	byRate      *btree.BTreeG[orderbook.Order]
+	byMaxVolume *btree.BTreeG[orderbook.Order]
+	byMinVolume *btree.BTreeG[orderbook.Order]
+}
+
+func newOrderIndex() *orderIndex {
+	return &orderIndex{
+		byId:        btree.NewG(degree, lessById),
+		byRate:      btree.NewG(degree, lessByRate),
+		byMaxVolume: btree.NewG(degree, lessByMaxVolume),
+		byMinVolume: btree.NewG(degree, lessByMinVolume),
+	}
+}
+
+// insert adding order to every tree of index
+func (index *orderIndex) insert(order orderbook.Order) {
+	index.byId.ReplaceOrInsert(order)
+	index.byRate.ReplaceOrInsert(order)
+	index.byMaxVolume.ReplaceOrInsert(order)
+	index.byMinVolume.ReplaceOrInsert(order)
+}
+
+// delete removing order from every tree of index
+func (index *orderIndex) delete(order orderbook.Order) {
+	index.byId.Delete(order)
+	index.byRate.Delete(order)
+	index.byMaxVolume.Delete(order)
+	index.byMinVolume.Delete(order)
+}
+
+// empty checks that index has no orders
+func (index *orderIndex) empty() bool {
+	return index.byId.Len() == 0
+}
+
+// best returning order not expired at now with max rate, nil if there are no orders
+func best(index *orderIndex, now time.Time) *orderbook.Order {
+	var result *orderbook.Order
+	index.byRate.Descend(func(order orderbook.Order) bool {
+		if order.Expired(now) {
+			return true
+		}
+
+		result = &order
+		return false
+	})
+
+	return result
+}
+
+// depth grouping orders not expired at now into at most levels rate levels from the best rate, non-positive levels means all levels
+func depth(index *orderIndex, now time.Time, levels int, tick decimal.Decimal) []orderbook.DepthLevel {
+	result := make([]orderbook.DepthLevel, 0)
+	index.byRate.Descend(func(order orderbook.Order) bool {
+		if order.Expired(now) {
+			return true
+		}
+
+		rate := orderbook.LevelRate(order.Rate, tick)
+		if len(result) == 0 || !result[len(result)-1].Rate.Equal(rate) {
+			if levels > 0 && len(result) == levels {
+				return false
+			}
+			result = append(result, orderbook.DepthLevel{Rate: rate})
+		}
+
+		level := &result[len(result)-1]
+		level.Volume = level.Volume.Add(order.MaxVolume)
+		level.Orders++
+		return true
+	})
+
+	return result
+}
+
+// Orders with equal sort keys are ordered by id, so every order has a unique position in a tree.
+// Orders with equal rate are ordered by descending priority, so descending by rate gives matching order.
+
+func lessById(a, b orderbook.Order) bool {
+	return a.Id < b.Id
+}
+
+func lessByExpiry(a, b orderbook.Order) bool {
+	if !a.ExpiresAt.Equal(*b.ExpiresAt) {
+		return a.ExpiresAt.Before(*b.ExpiresAt)
+	}
+	return a.Id < b.Id
+}
+
+func lessByRate(a, b orderbook.Order) bool {
+	if c := a.Rate.Cmp(b.Rate); c != 0 {
+		return c < 0
+	}
+	if a.Priority != b.Priority {
+		return a.Priority > b.Priority
+	}
+	return a.Id < b.Id
+}
+
+func lessByMaxVolume(a, b orderbook.Order) bool {
+	if c := a.MaxVolume.Cmp(b.MaxVolume); c != 0 {
+		return c < 0
+	}
+	return a.Id < b.Id
+}
+
+func lessByMinVolume(a, b orderbook.Order) bool {
+	if c := a.MinVolume.Cmp(b.MinVolume); c != 0 {
+		return c < 0
+	}
+	return a.Id < b.Id
+}
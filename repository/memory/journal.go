@@ -0,0 +1,282 @@
+package memory
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/SashaBokov/orderbook"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+// SyncPolicy is a policy of flushing journal to disk
+type SyncPolicy string
+
+const (
+	// SyncAlways syncs journal after every entry, so changes survive power loss once methods return
+	SyncAlways SyncPolicy = "always"
+	// SyncInterval syncs journal every JournalOptions.SyncInterval, changes of the last interval may be lost on power loss
+	SyncInterval SyncPolicy = "interval"
+	// SyncNever leaves flushing to operating system, changes survive crash of process but may be lost on power loss
+	SyncNever SyncPolicy = "never"
+)
+
+// DefaultSyncInterval is a sync interval of SyncInterval policy used when JournalOptions.SyncInterval is not positive
+const DefaultSyncInterval = 100 * time.Millisecond
+
+// JournalOptions are options of book journal
+type JournalOptions struct {
+	// Sync is SyncAlways if empty
+	Sync         SyncPolicy
+	SyncInterval time.Duration
+	// SnapshotEvery is a number of journal entries after which snapshot is written and journal is truncated,
+	// zero disables automatic snapshots
+	SnapshotEvery int
+}
+
+// ErrCorruptSnapshot is returned by Open when snapshot can't be read, book can't be restored without it
+var ErrCorruptSnapshot = errors.New("corrupt snapshot")
+
+const (
+	journalFile  = "journal"
+	snapshotFile = "snapshot"
+	// frameHeader is a size of frame length and CRC-32C checksum of frame payload
+	frameHeader = 8
+	// maxFrame is a maximal size of frame payload, larger length means corrupt frame
+	maxFrame = 1 << 30
+)
+
+// errCorruptFrame is returned by readFrame when frame is truncated or its checksum doesn't match
+var errCorruptFrame = errors.New("corrupt frame")
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// Journal entry operations, every mutating Book method is an operation
+const (
+	opAddNewPair          = "add_new_pair"
+	opAddOrder            = "add_order"
+	opUpdatePair          = "update_pair"
+	opMatchOrder          = "match_order"
+	opAmendOrder          = "amend_order"
+	opFillOrder           = "fill_order"
+	opRemovePair          = "remove_pair"
+	opRemoveOrder         = "remove_order"
+	opRemoveExpiredOrders = "remove_expired_orders"
+)
+
+// journalEntry is a call of mutating Book method with its arguments and time of book clock
+type journalEntry struct {
+	Seq       uint64               `json:"seq"`
+	Op        string               `json:"op"`
+	Time      time.Time            `json:"time"`
+	Pair      *orderbook.Pair      `json:"pair,omitempty"`
+	Order     *orderbook.Order     `json:"order,omitempty"`
+	OrderId   string               `json:"order_id,omitempty"`
+	TokenBid  string               `json:"token_bid,omitempty"`
+	TokenAsk  string               `json:"token_ask,omitempty"`
+	Amendment *orderbook.Amendment `json:"amendment,omitempty"`
+	Volume    *decimal.Decimal     `json:"volume,omitempty"`
+}
+
+// journal is an append-only file of entries, every entry is a frame of length, CRC-32C checksum and JSON payload
+type journal struct {
+	mu      sync.Mutex
+	dir     string
+	file    *os.File
+	options JournalOptions
+	seq     uint64 // last appended entry
+	entries int    // entries appended after the last snapshot
+	dirty   bool   // entries are written but not synced
+	err     error  // failed write or sync, journal refuses entries after it
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// openJournal opening journal file of dir for appending, entries are read with readFrame before appending
+func openJournal(dir string, options JournalOptions) (*journal, error) {
+	if options.Sync == "" {
+		options.Sync = SyncAlways
+	}
+	if options.SyncInterval <= 0 {
+		options.SyncInterval = DefaultSyncInterval
+	}
+
+	file, err := os.OpenFile(filepath.Join(dir, journalFile), os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, errors.Wrap(err, "opening journal")
+	}
+
+	return &journal{dir: dir, file: file, options: options, done: make(chan struct{}), stopped: make(chan struct{})}, nil
+}
+
+// start starting background sync of SyncInterval policy
+func (j *journal) start() {
+	if j.options.Sync != SyncInterval {
+		close(j.stopped)
+		return
+	}
+
+	go func() {
+		defer close(j.stopped)
+
+		ticker := time.NewTicker(j.options.SyncInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-j.done:
+				return
+			case <-ticker.C:
+				// failed sync is kept in err and returned by the next append
+				j.mu.Lock()
+				_ = j.sync()
+				j.mu.Unlock()
+			}
+		}
+	}()
+}
+
+// append writing entry with the next sequence to journal and syncing it by policy
+func (j *journal) append(entry journalEntry) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.err != nil {
+		return errors.Wrap(j.err, "journal failed")
+	}
+
+	entry.Seq = j.seq + 1
+	payload, err := json.Marshal(entry)
+	if err != nil {
+		return errors.Wrap(err, "marshalling journal entry")
+	}
+
+	info, err := j.file.Stat()
+	if err != nil {
+		return errors.Wrap(err, "getting journal size")
+	}
+
+	if err := writeFrame(j.file, payload); err != nil {
+		// partially written frame is cut as corrupt tail by Open
+		j.err = err
+		return errors.Wrap(err, "writing journal entry")
+	}
+	j.dirty = true
+
+	if j.options.Sync == SyncAlways {
+		if err := j.sync(); err != nil {
+			// entry is refused, so it is cut for Open not to replay change which caller saw failed
+			if truncateErr := j.file.Truncate(info.Size()); truncateErr != nil {
+				return errors.Wrapf(err, "truncating unsynced entry: %v", truncateErr)
+			}
+			return err
+		}
+	}
+	j.seq = entry.Seq
+	j.entries++
+
+	return nil
+}
+
+// snapshotDue checks that SnapshotEvery entries are appended after the last snapshot
+func (j *journal) snapshotDue() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.options.SnapshotEvery > 0 && j.entries >= j.options.SnapshotEvery
+}
+
+// truncate removing entries covered by snapshot, sequences continue from the last entry
+func (j *journal) truncate() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if err := j.file.Truncate(0); err != nil {
+		return errors.Wrap(err, "truncating journal")
+	}
+	j.entries = 0
+	j.dirty = true
+
+	return j.sync()
+}
+
+// sync syncing written entries to disk, caller must hold mu
+func (j *journal) sync() error {
+	if !j.dirty || j.err != nil {
+		return j.err
+	}
+
+	if err := j.file.Sync(); err != nil {
+		j.err = err
+		return errors.Wrap(err, "syncing journal")
+	}
+	j.dirty = false
+
+	return nil
+}
+
+// close stopping background sync, syncing and closing journal file
+func (j *journal) close() error {
+	close(j.done)
+	<-j.stopped
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	syncErr := j.sync()
+	if err := j.file.Close(); err != nil {
+		return errors.Wrap(err, "closing journal")
+	}
+
+	return syncErr
+}
+
+// writeFrame writing payload with its length and checksum in a single write
+func writeFrame(w io.Writer, payload []byte) error {
+	frame := make([]byte, frameHeader+len(payload))
+	binary.BigEndian.PutUint32(frame[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(frame[4:8], crc32.Checksum(payload, crcTable))
+	copy(frame[frameHeader:], payload)
+
+	_, err := w.Write(frame)
+	return err
+}
+
+// readFrame reading payload of the next frame, returns io.EOF at the end of frames
+// and errCorruptFrame when frame is truncated or its checksum doesn't match
+func readFrame(r *bufio.Reader) ([]byte, error) {
+	header := make([]byte, frameHeader)
+	if _, err := io.ReadFull(r, header); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.EOF
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, errCorruptFrame
+		}
+		return nil, err
+	}
+
+	length := binary.BigEndian.Uint32(header[0:4])
+	if length > maxFrame {
+		return nil, errCorruptFrame
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, errCorruptFrame
+		}
+		return nil, err
+	}
+	if crc32.Checksum(payload, crcTable) != binary.BigEndian.Uint32(header[4:8]) {
+		return nil, errCorruptFrame
+	}
+
+	return payload, nil
+}
@@ -0,0 +1,154 @@
+package memory
+
+import (
+	"math"
+	"time"
+
+	"github.com/SashaBokov/orderbook"
+	"github.com/google/btree"
+	"github.com/shopspring/decimal"
+)
+
+// sortKey is an order of index tree with conversion of orders to cursor positions and back
+type sortKey struct {
+	tree func(index *orderIndex) *btree.BTreeG[orderbook.Order]
+	less func(a, b orderbook.Order) bool
+	// position returning cursor position of order
+	position func(order orderbook.Order) orderbook.Position
+	// pivot returning order with sort key of position
+	pivot func(position orderbook.Position) orderbook.Order
+
+	// value returning sorted field of order, it is nil for sort by id
+	value func(order orderbook.Order) decimal.Decimal
+	// bounds returning range of sorted field selected by query, nil bounds are open
+	bounds func(q orderbook.Query) (min, max *decimal.Decimal)
+	// floor and ceil returning pivots sorted before and after every order with sorted field equal to value,
+	// nil floor or ceil means tree is iterated from the start
+	floor, ceil func(value decimal.Decimal) orderbook.Order
+}
+
+var sortKeys = map[orderbook.SortKey]sortKey{
+	orderbook.SortById: {
+		tree:     func(index *orderIndex) *btree.BTreeG[orderbook.Order] { return index.byId },
+		less:     lessById,
+		position: func(order orderbook.Order) orderbook.Position { return orderbook.Position{Id: order.Id} },
+		pivot:    func(position orderbook.Position) orderbook.Order { return orderbook.Order{Id: position.Id} },
+	},
+	orderbook.SortByRate: {
+		tree: func(index *orderIndex) *btree.BTreeG[orderbook.Order] { return index.byRate },
+		less: lessByRate,
+		position: func(order orderbook.Order) orderbook.Position {
+			return orderbook.Position{Value: order.Rate, Seq: order.Priority, Id: order.Id}
+		},
+		pivot: func(position orderbook.Position) orderbook.Order {
+			return orderbook.Order{Rate: position.Value, Priority: position.Seq, Id: position.Id}
+		},
+		value:  func(order orderbook.Order) decimal.Decimal { return order.Rate },
+		bounds: func(q orderbook.Query) (*decimal.Decimal, *decimal.Decimal) { return q.MinRate, q.MaxRate },
+		// orders with equal rate are sorted by descending priority
+		floor: func(value decimal.Decimal) orderbook.Order {
+			return orderbook.Order{Rate: value, Priority: math.MaxInt64}
+		},
+		ceil: func(value decimal.Decimal) orderbook.Order {
+			return orderbook.Order{Rate: value, Priority: math.MinInt64}
+		},
+	},
+	orderbook.SortByMaxVolume: {
+		tree: func(index *orderIndex) *btree.BTreeG[orderbook.Order] { return index.byMaxVolume },
+		less: lessByMaxVolume,
+		position: func(order orderbook.Order) orderbook.Position {
+			return orderbook.Position{Value: order.MaxVolume, Id: order.Id}
+		},
+		pivot: func(position orderbook.Position) orderbook.Order {
+			return orderbook.Order{MaxVolume: position.Value, Id: position.Id}
+		},
+		value:  func(order orderbook.Order) decimal.Decimal { return order.MaxVolume },
+		bounds: func(q orderbook.Query) (*decimal.Decimal, *decimal.Decimal) { return q.MinVolume, q.MaxVolume },
+		floor:  func(value decimal.Decimal) orderbook.Order { return orderbook.Order{MaxVolume: value} },
+	},
+	orderbook.SortByMinVolume: {
+		tree: func(index *orderIndex) *btree.BTreeG[orderbook.Order] { return index.byMinVolume },
+		less: lessByMinVolume,
+		position: func(order orderbook.Order) orderbook.Position {
+			return orderbook.Position{Value: order.MinVolume, Id: order.Id}
+		},
+		pivot: func(position orderbook.Position) orderbook.Order {
+			return orderbook.Order{MinVolume: position.Value, Id: position.Id}
+		},
+	},
+}
+
+// querySortKey returning sort key of query, empty sort is SortById
+func querySortKey(q orderbook.Query) sortKey {
+	if q.Sort == "" {
+		return sortKeys[orderbook.SortById]
+	}
+
+	return sortKeys[q.Sort]
+}
+
+// pageOfTree collecting page of orders not expired at now and matching query from index tree.
+// Tree is iterated from cursor or bound of sorted field and stops after the other bound.
+func pageOfTree(tree *btree.BTreeG[orderbook.Order], key sortKey, q orderbook.Query, now time.Time) (orderbook.Page, error) {
+	var min, max *decimal.Decimal
+	if key.bounds != nil {
+		min, max = key.bounds(q)
+	}
+
+	limit := orderbook.PageLimit(q.Limit)
+	result := orderbook.Page{Orders: make([]orderbook.Order, 0)}
+	more := false
+	iterator := func(order orderbook.Order) bool {
+		if key.value != nil {
+			value := key.value(order)
+			if !q.Descending && max != nil && value.GreaterThan(*max) || q.Descending && min != nil && value.LessThan(*min) {
+				return false
+			}
+		}
+		if order.Expired(now) || !q.Match(order) {
+			return true
+		}
+		if len(result.Orders) == limit {
+			more = true
+			return false
+		}
+
+		result.Orders = append(result.Orders, order)
+		return true
+	}
+
+	switch {
+	case q.Cursor != "":
+		position, err := q.Cursor.Position()
+		if err != nil {
+			return orderbook.Page{}, err
+		}
+
+		pivot := key.pivot(position)
+		after := func(order orderbook.Order) bool {
+			if !key.less(pivot, order) && !key.less(order, pivot) {
+				return true
+			}
+			return iterator(order)
+		}
+		if q.Descending {
+			tree.DescendLessOrEqual(pivot, after)
+		} else {
+			tree.AscendGreaterOrEqual(pivot, after)
+		}
+	case !q.Descending && min != nil && key.floor != nil:
+		tree.AscendGreaterOrEqual(key.floor(*min), iterator)
+	case q.Descending && max != nil && key.ceil != nil:
+		tree.DescendLessOrEqual(key.ceil(*max), iterator)
+	case q.Descending:
+		tree.Descend(iterator)
+	default:
+		tree.Ascend(iterator)
+	}
+
+	if more {
+		result.NextCursor = key.position(result.Orders[len(result.Orders)-1]).Cursor()
+	}
+
+	return result, nil
+}
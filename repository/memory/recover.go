@@ -0,0 +1,343 @@
+package memory
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/SashaBokov/orderbook"
+	"github.com/pkg/errors"
+)
+
+// Recovery is a result of restoring journaled book
+type Recovery struct {
+	// SnapshotSeq is a sequence of the last journal entry covered by snapshot, zero without snapshot
+	SnapshotSeq uint64 `json:"snapshot_seq"`
+	// Replayed is a number of journal entries replayed after snapshot
+	Replayed int `json:"replayed"`
+	// TruncatedBytes is a size of truncated or corrupt journal tail which is cut
+	TruncatedBytes int64 `json:"truncated_bytes"`
+}
+
+// bookState is a snapshot of book, indexes are rebuilt from orders
+type bookState struct {
+	Seq      uint64                              `json:"seq"`
+	Priority int64                               `json:"priority"`
+	Pairs    []orderbook.Pair                    `json:"pairs"`
+	Orders   []orderbook.Order                   `json:"orders"`
+	History  map[string][]orderbook.StatusChange `json:"history"`
+	Trades   []orderbook.Trade                   `json:"trades"`
+}
+
+// Open returns Book journaled in dir, its state is restored from snapshot and journal entries written after it.
+// Every mutating method writes journal entry before changing the book, entries are replayed with the book clock
+// set to their time, so options must be the same as ones the journal is written with.
+// Truncated or corrupt journal tail is cut and reported in Recovery, corrupt snapshot returns ErrCorruptSnapshot.
+func Open(dir string, journalOptions JournalOptions, opts ...orderbook.Option) (*Book, Recovery, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, Recovery{}, errors.Wrap(err, "creating journal dir")
+	}
+
+	b := New(opts...)
+	seq, err := b.loadSnapshot(filepath.Join(dir, snapshotFile))
+	if err != nil {
+		return nil, Recovery{}, err
+	}
+
+	j, err := openJournal(dir, journalOptions)
+	if err != nil {
+		return nil, Recovery{}, err
+	}
+
+	recovery := Recovery{SnapshotSeq: seq}
+	j.seq = seq
+	if err := b.replay(j, &recovery); err != nil {
+		_ = j.file.Close()
+		return nil, Recovery{}, err
+	}
+
+	b.journal = j
+	j.start()
+
+	return b, recovery, nil
+}
+
+// Snapshot writing state of book to snapshot and truncating journal, it is done automatically every SnapshotEvery entries
+func (b *Book) Snapshot() error {
+	if b.journal == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.snapshot()
+}
+
+// Close syncing and closing journal, book mustn't be changed after it
+func (b *Book) Close() error {
+	if b.journal == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.journal.close()
+}
+
+// log appending entry to journal before the change is applied, snapshot is written first when it is due.
+// Caller must hold write lock.
+func (b *Book) log(entry journalEntry) error {
+	if b.journal == nil {
+		return nil
+	}
+
+	if b.journal.snapshotDue() {
+		if err := b.snapshot(); err != nil {
+			return err
+		}
+	}
+
+	return b.journal.append(entry)
+}
+
+// snapshot writing state to temporary file and renaming it to snapshot, so snapshot is replaced atomically,
+// then journal is truncated. Entries of journal left by crash before truncation are skipped by their sequences.
+// Caller must hold write lock.
+func (b *Book) snapshot() error {
+	payload, err := json.Marshal(b.state())
+	if err != nil {
+		return errors.Wrap(err, "marshalling snapshot")
+	}
+
+	path := filepath.Join(b.journal.dir, snapshotFile)
+	file, err := os.OpenFile(path+".tmp", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return errors.Wrap(err, "creating snapshot")
+	}
+	if err := writeFrame(file, payload); err != nil {
+		_ = file.Close()
+		return errors.Wrap(err, "writing snapshot")
+	}
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+		return errors.Wrap(err, "syncing snapshot")
+	}
+	if err := file.Close(); err != nil {
+		return errors.Wrap(err, "closing snapshot")
+	}
+
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return errors.Wrap(err, "renaming snapshot")
+	}
+	if err := syncDir(b.journal.dir); err != nil {
+		return err
+	}
+
+	return b.journal.truncate()
+}
+
+// state returning snapshot of book with sequence of the last journal entry, caller must hold lock
+func (b *Book) state() bookState {
+	state := bookState{
+		Seq:      b.journal.seq,
+		Priority: b.priority,
+		Pairs:    make([]orderbook.Pair, 0, len(b.registry)/2),
+		Orders:   make([]orderbook.Order, 0, len(b.orders)),
+		History:  b.history,
+		Trades:   b.trades,
+	}
+
+	for p, registered := range b.registry {
+		// registry has both directions of pair
+		if p.tokenBid == registered.TokenBid {
+			state.Pairs = append(state.Pairs, registered)
+		}
+	}
+	for _, order := range b.orders {
+		state.Orders = append(state.Orders, order)
+	}
+
+	sort.Slice(state.Pairs, func(i, j int) bool {
+		return state.Pairs[i].TokenBid < state.Pairs[j].TokenBid ||
+			state.Pairs[i].TokenBid == state.Pairs[j].TokenBid && state.Pairs[i].TokenAsk < state.Pairs[j].TokenAsk
+	})
+	sort.Slice(state.Orders, func(i, j int) bool {
+		return state.Orders[i].Priority < state.Orders[j].Priority
+	})
+
+	return state
+}
+
+// loadSnapshot restoring book from snapshot file if it exists, returns sequence of the last entry covered by it
+func (b *Book) loadSnapshot(path string) (uint64, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, errors.Wrap(err, "opening snapshot")
+	}
+	defer file.Close()
+
+	payload, err := readFrame(bufio.NewReader(file))
+	if errors.Is(err, errCorruptFrame) || errors.Is(err, io.EOF) {
+		return 0, errors.Wrapf(ErrCorruptSnapshot, "snapshot %s", path)
+	}
+	if err != nil {
+		return 0, errors.Wrap(err, "reading snapshot")
+	}
+
+	var state bookState
+	if err := json.Unmarshal(payload, &state); err != nil {
+		return 0, errors.Wrapf(ErrCorruptSnapshot, "snapshot %s: %s", path, err)
+	}
+
+	b.priority = state.Priority
+	for _, registered := range state.Pairs {
+		for _, p := range []pair{{registered.TokenBid, registered.TokenAsk}, {registered.TokenAsk, registered.TokenBid}} {
+			b.registry[p] = registered
+			b.pairs[p] = newOrderIndex()
+		}
+	}
+	for _, order := range state.Orders {
+		b.insert(b.pairs[pair{order.TokenBid, order.TokenAsk}], order)
+	}
+	if state.History != nil {
+		b.history = state.History
+	}
+	for _, trade := range state.Trades {
+		b.addTrade(trade)
+	}
+
+	return state.Seq, nil
+}
+
+// replay calling methods of journal entries written after snapshot with book clock set to entry time.
+// Journal is cut at the first truncated, corrupt or out of sequence entry.
+func (b *Book) replay(j *journal, recovery *Recovery) error {
+	info, err := j.file.Stat()
+	if err != nil {
+		return errors.Wrap(err, "getting journal size")
+	}
+	if _, err := j.file.Seek(0, io.SeekStart); err != nil {
+		return errors.Wrap(err, "seeking journal")
+	}
+
+	clock := b.options.Clock
+	defer func() { b.options.Clock = clock }()
+	replayed := &replayClock{}
+	b.options.Clock = replayed
+
+	reader := bufio.NewReader(j.file)
+	var offset int64
+	for {
+		payload, err := readFrame(reader)
+		if errors.Is(err, io.EOF) || errors.Is(err, errCorruptFrame) {
+			break
+		}
+		if err != nil {
+			return errors.Wrap(err, "reading journal")
+		}
+
+		var entry journalEntry
+		if err := json.Unmarshal(payload, &entry); err != nil {
+			break
+		}
+		if entry.Seq > j.seq+1 {
+			break
+		}
+		offset += int64(frameHeader + len(payload))
+		if entry.Seq <= j.seq {
+			// entry is covered by snapshot written before journal truncation
+			continue
+		}
+
+		replayed.now = entry.Time
+		if err := b.apply(entry); err != nil {
+			return errors.Wrapf(err, "replaying journal entry %d", entry.Seq)
+		}
+		j.seq = entry.Seq
+		j.entries++
+		recovery.Replayed++
+	}
+
+	if offset < info.Size() {
+		recovery.TruncatedBytes = info.Size() - offset
+		if err := j.file.Truncate(offset); err != nil {
+			return errors.Wrap(err, "truncating journal tail")
+		}
+		if err := j.file.Sync(); err != nil {
+			return errors.Wrap(err, "syncing journal")
+		}
+	}
+
+	return nil
+}
+
+// apply calling method of journal entry, entries are written only for successful calls, so replay doesn't fail
+func (b *Book) apply(entry journalEntry) error {
+	ctx := context.Background()
+
+	var err error
+	switch entry.Op {
+	case opAddNewPair:
+		err = b.AddNewPair(ctx, *entry.Pair)
+	case opAddOrder:
+		err = b.AddOrder(ctx, *entry.Order)
+	case opUpdatePair:
+		err = b.UpdatePair(ctx, *entry.Pair)
+	case opMatchOrder:
+		_, err = b.MatchOrder(ctx, *entry.Order)
+	case opAmendOrder:
+		_, err = b.AmendOrder(ctx, entry.OrderId, *entry.Amendment)
+	case opFillOrder:
+		_, err = b.FillOrder(ctx, entry.OrderId, *entry.Volume)
+	case opRemovePair:
+		err = b.RemovePair(ctx, entry.TokenBid, entry.TokenAsk)
+	case opRemoveOrder:
+		err = b.RemoveOrder(ctx, entry.OrderId)
+	case opRemoveExpiredOrders:
+		_, err = b.RemoveExpiredOrders(ctx)
+	default:
+		err = errors.Errorf("unknown operation %q", entry.Op)
+	}
+
+	return err
+}
+
+// syncDir syncing directory, so renamed file survives power loss
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return errors.Wrap(err, "opening journal dir")
+	}
+	defer d.Close()
+
+	if err := d.Sync(); err != nil {
+		return errors.Wrap(err, "syncing journal dir")
+	}
+
+	return nil
+}
+
+// replayClock is a clock of replay returning time of replayed entry
+type replayClock struct {
+	now time.Time
+}
+
+func (c *replayClock) Now() time.Time {
+	return c.now
+}
+
+func (c *replayClock) After(d time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	ch <- c.now.Add(d)
+	return ch
+}
@@ -0,0 +1,268 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/SashaBokov/orderbook"
+	"github.com/shopspring/decimal"
+)
+
+func testPair() orderbook.Pair {
+	return orderbook.Pair{TokenBid: "BTC", TokenAsk: "ETH"}
+}
+
+func testOrder(id string, rate, volume int64) orderbook.Order {
+	return orderbook.Order{
+		Id:        id,
+		MakerId:   "maker",
+		TokenBid:  "BTC",
+		TokenAsk:  "ETH",
+		Rate:      decimal.NewFromInt(rate),
+		MaxVolume: decimal.NewFromInt(volume),
+		MinVolume: decimal.NewFromInt(1),
+	}
+}
+
+func openBook(t *testing.T, dir string, options JournalOptions) (*Book, Recovery) {
+	t.Helper()
+
+	b, recovery, err := Open(dir, options)
+	if err != nil {
+		t.Fatalf("opening book: %v", err)
+	}
+
+	return b, recovery
+}
+
+// fillBook adding pair and orders 1, 2, 3 and removing order 2, so journal has five entries
+func fillBook(t *testing.T, b *Book) {
+	t.Helper()
+
+	ctx := context.Background()
+	if err := b.AddNewPair(ctx, testPair()); err != nil {
+		t.Fatalf("adding pair: %v", err)
+	}
+	for i, id := range []string{"1", "2", "3"} {
+		if err := b.AddOrder(ctx, testOrder(id, int64(i+1), 10)); err != nil {
+			t.Fatalf("adding order %s: %v", id, err)
+		}
+	}
+	if err := b.RemoveOrder(ctx, "2"); err != nil {
+		t.Fatalf("removing order: %v", err)
+	}
+}
+
+func listAll(t *testing.T, b *Book) []orderbook.Order {
+	t.Helper()
+
+	page, err := b.ListOrders(context.Background(), orderbook.Query{})
+	if err != nil {
+		t.Fatalf("listing orders: %v", err)
+	}
+
+	return page.Orders
+}
+
+func closeBook(t *testing.T, b *Book) {
+	t.Helper()
+
+	if err := b.Close(); err != nil {
+		t.Fatalf("closing book: %v", err)
+	}
+}
+
+func journalSize(t *testing.T, dir string) int64 {
+	t.Helper()
+
+	info, err := os.Stat(filepath.Join(dir, journalFile))
+	if err != nil {
+		t.Fatalf("getting journal size: %v", err)
+	}
+
+	return info.Size()
+}
+
+func TestOpenReplaysJournal(t *testing.T) {
+	dir := t.TempDir()
+	b, _ := openBook(t, dir, JournalOptions{})
+	fillBook(t, b)
+	want := listAll(t, b)
+	closeBook(t, b)
+
+	b, recovery := openBook(t, dir, JournalOptions{})
+	defer closeBook(t, b)
+
+	if recovery.Replayed != 5 || recovery.TruncatedBytes != 0 || recovery.SnapshotSeq != 0 {
+		t.Fatalf("unexpected recovery %+v", recovery)
+	}
+	if got := listAll(t, b); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected orders %v, got %v", want, got)
+	}
+
+	history, err := b.GetOrderHistory(context.Background(), "2")
+	if err != nil || len(history) != 2 || history[1].Status != orderbook.OrderCancelled {
+		t.Fatalf("expected open and cancelled history of removed order, got %v, %v", history, err)
+	}
+}
+
+func TestOpenCutsTruncatedTail(t *testing.T) {
+	dir := t.TempDir()
+	b, _ := openBook(t, dir, JournalOptions{})
+	fillBook(t, b)
+	want := listAll(t, b)
+	closeBook(t, b)
+
+	// header of frame which is cut by crash before its payload is written
+	tail := []byte{0, 0, 0, 100, 1, 2}
+	file, err := os.OpenFile(filepath.Join(dir, journalFile), os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatalf("opening journal: %v", err)
+	}
+	if _, err := file.Write(tail); err != nil {
+		t.Fatalf("writing tail: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatalf("closing journal: %v", err)
+	}
+	size := journalSize(t, dir)
+
+	b, recovery := openBook(t, dir, JournalOptions{})
+	if recovery.Replayed != 5 || recovery.TruncatedBytes != int64(len(tail)) {
+		t.Fatalf("unexpected recovery %+v", recovery)
+	}
+	if got := journalSize(t, dir); got != size-int64(len(tail)) {
+		t.Fatalf("expected journal of %d bytes, got %d", size-int64(len(tail)), got)
+	}
+	if got := listAll(t, b); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected orders %v, got %v", want, got)
+	}
+
+	// entries appended after the cut are replayed after the previous ones
+	if err := b.AddOrder(context.Background(), testOrder("4", 4, 10)); err != nil {
+		t.Fatalf("adding order: %v", err)
+	}
+	closeBook(t, b)
+
+	b, recovery = openBook(t, dir, JournalOptions{})
+	defer closeBook(t, b)
+	if recovery.Replayed != 6 || recovery.TruncatedBytes != 0 {
+		t.Fatalf("unexpected recovery %+v", recovery)
+	}
+	if _, err := b.GetOrderById(context.Background(), "4"); err != nil {
+		t.Fatalf("expected order appended after cut, got %v", err)
+	}
+}
+
+func TestOpenCutsCorruptTail(t *testing.T) {
+	dir := t.TempDir()
+	b, _ := openBook(t, dir, JournalOptions{})
+	fillBook(t, b)
+	closeBook(t, b)
+
+	// flip the last byte of the last frame, so checksum of removal of order 2 doesn't match
+	path := filepath.Join(dir, journalFile)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading journal: %v", err)
+	}
+	data[len(data)-1] ^= 0xff
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("writing journal: %v", err)
+	}
+
+	b, recovery := openBook(t, dir, JournalOptions{})
+	defer closeBook(t, b)
+
+	if recovery.Replayed != 4 || recovery.TruncatedBytes == 0 {
+		t.Fatalf("unexpected recovery %+v", recovery)
+	}
+	if got := journalSize(t, dir); got != int64(len(data))-recovery.TruncatedBytes {
+		t.Fatalf("expected journal of %d bytes, got %d", int64(len(data))-recovery.TruncatedBytes, got)
+	}
+	if _, err := b.GetOrderById(context.Background(), "2"); err != nil {
+		t.Fatalf("expected order of corrupt removal, got %v", err)
+	}
+}
+
+func TestOpenRestoresSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	b, _ := openBook(t, dir, JournalOptions{SnapshotEvery: 3})
+	fillBook(t, b)
+	want := listAll(t, b)
+	closeBook(t, b)
+
+	b, recovery := openBook(t, dir, JournalOptions{SnapshotEvery: 3})
+	defer closeBook(t, b)
+
+	// snapshot is written before the fourth entry, entries after it are replayed
+	if recovery.SnapshotSeq != 3 || recovery.Replayed != 2 {
+		t.Fatalf("unexpected recovery %+v", recovery)
+	}
+	if got := listAll(t, b); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected orders %v, got %v", want, got)
+	}
+}
+
+func TestOpenSkipsEntriesCoveredBySnapshot(t *testing.T) {
+	dir := t.TempDir()
+	b, _ := openBook(t, dir, JournalOptions{})
+	fillBook(t, b)
+	want := listAll(t, b)
+
+	// journal is kept as if crash happened between snapshot rename and journal truncation
+	path := filepath.Join(dir, journalFile)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading journal: %v", err)
+	}
+	if err := b.Snapshot(); err != nil {
+		t.Fatalf("writing snapshot: %v", err)
+	}
+	closeBook(t, b)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("writing journal: %v", err)
+	}
+
+	b, recovery := openBook(t, dir, JournalOptions{})
+	defer closeBook(t, b)
+
+	if recovery.SnapshotSeq != 5 || recovery.Replayed != 0 || recovery.TruncatedBytes != 0 {
+		t.Fatalf("unexpected recovery %+v", recovery)
+	}
+	if got := listAll(t, b); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected orders %v, got %v", want, got)
+	}
+}
+
+func TestOpenCorruptSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, snapshotFile), []byte{0, 0, 0, 4, 0, 0, 0, 0, 1}, 0o644); err != nil {
+		t.Fatalf("writing snapshot: %v", err)
+	}
+
+	if _, _, err := Open(dir, JournalOptions{}); !errors.Is(err, ErrCorruptSnapshot) {
+		t.Fatalf("expected ErrCorruptSnapshot, got %v", err)
+	}
+}
+
+func TestJournalRefusesEntriesAfterFailure(t *testing.T) {
+	dir := t.TempDir()
+	b, _ := openBook(t, dir, JournalOptions{})
+	defer b.journal.file.Close()
+
+	b.journal.err = errors.New("disk failed")
+	if err := b.AddNewPair(context.Background(), testPair()); err == nil {
+		t.Fatal("expected journal error")
+	}
+	if _, err := b.GetPair(context.Background(), "BTC", "ETH"); !errors.Is(err, orderbook.ErrPairNotFound) {
+		t.Fatalf("expected pair not added, got %v", err)
+	}
+	if b.journal.seq != 0 || journalSize(t, dir) != 0 {
+		t.Fatalf("expected empty journal, got seq %d", b.journal.seq)
+	}
+}
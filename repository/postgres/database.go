@@ -3,392 +3,935 @@ package postgres
 import (
 	"context"
 	"database/sql"
-	"fmt"
+	"encoding/json"
+	"time"
+
 	"github.com/SashaBokov/orderbook"
 	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
 )
 
 // Check that Database implements orderbook.OrderBook
 var _ = orderbook.OrderBook(&Database{})
 
+func init() {
+	orderbook.RegisterPostgres(func(databaseURL string) (orderbook.OrderBook, error) {
+		return New(context.Background(), databaseURL)
+	})
+}
+
+// queryer is implemented by both *sql.DB and *sql.Tx
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
 // Database is a wrapper around sql.DB with orderbook methods.
 type Database struct {
-	conn *sql.DB
+	conn    *sql.DB
+	options orderbook.Options
+	events  *orderbook.Broker // events are prepared inside transactions and published after they end
 }
 
-func New(databaseURL string) (*Database, error) {
+// New connecting to database and applying pending migrations,
+// database migrated by newer version of package returns ErrUnknownSchemaVersion
+func New(ctx context.Context, databaseURL string, opts ...orderbook.Option) (*Database, error) {
 	conn, err := sql.Open("postgres", databaseURL)
 	if err != nil {
 		return nil, errors.Wrap(err, "connecting to database")
 	}
 
-	if err := conn.Ping(); err != nil {
+	if err := conn.PingContext(ctx); err != nil {
 		return nil, errors.Wrap(err, "pinging database")
 	}
 
-	db := &Database{conn: conn}
-	if err := db.initOrdersTable(); err != nil {
-		return nil, errors.Wrap(err, "initializing orders table")
+	db := &Database{conn: conn, options: orderbook.NewOptions(opts...), events: orderbook.NewBroker()}
+	if err := db.Migrate(ctx, LatestSchemaVersion()); err != nil {
+		return nil, errors.Wrap(err, "migrating database")
 	}
 
 	return db, nil
 }
 
-// initOrdersTable creating orders table
-func (db *Database) initOrdersTable() error {
-	if _, err := db.conn.Exec(newOrdersTableQuery); err != nil {
-		return errors.Wrap(err, "creating orders table")
+// AddNewPair adding new pair to orderbook, orders of both pair directions may be added then
+func (db *Database) AddNewPair(ctx context.Context, pair orderbook.Pair) error {
+	if err := pair.Validate(); err != nil {
+		return err
+	}
+	if pair.Status == "" {
+		pair.Status = orderbook.PairActive
 	}
 
-	return nil
+	var batch orderbook.EventBatch
+	err := db.withTx(ctx, func(tx *sql.Tx) error {
+		if _, err := db.getPair(ctx, tx, getPairForShareQuery, pair.TokenBid, pair.TokenAsk); err == nil {
+			return errors.Wrapf(orderbook.ErrPairExists, "pair %s_%s", pair.TokenBid, pair.TokenAsk)
+		} else if !errors.Is(err, orderbook.ErrPairNotFound) {
+			return err
+		}
+
+		// pair of reversed tokens inserted concurrently violates unique index of tokens in any order
+		if _, err := tx.ExecContext(ctx, addPairQuery, pair.TokenBid, pair.TokenAsk, pair.TickSize, pair.LotSize, pair.MinNotional, pair.Status); err != nil {
+			if isUniqueViolation(err) {
+				return errors.Wrapf(orderbook.ErrPairExists, "pair %s_%s", pair.TokenBid, pair.TokenAsk)
+			}
+			return errors.Wrap(err, "inserting pair")
+		}
+
+		return db.emit(ctx, tx, &batch, orderbook.NewPairEvent(orderbook.EventPairAdded, pair, db.options.Clock.Now()))
+	})
+	db.publish(batch, err)
+
+	return err
 }
 
-// AddNewPair adding new pair to orderbook
-func (db *Database) AddNewPair(tokenBid, tokenAsk string) error {
-	tx, err := db.conn.BeginTx(context.Background(), nil)
-	if err != nil {
-		return errors.Wrap(err, "beginning transaction")
+// AddOrder adding new order to orderbook, order pair must exist and be active
+func (db *Database) AddOrder(ctx context.Context, order orderbook.Order) error {
+	if err := db.options.Validator.Validate(order); err != nil {
+		return err
+	}
+	if order.Expired(db.options.Clock.Now()) {
+		return &orderbook.ValidationError{Fields: []orderbook.FieldError{{Field: "expires_at", Reason: "must be in the future"}}}
 	}
 
-	if _, err := tx.Exec(addPairTablesQuery, tokenBid, tokenAsk); err != nil {
-		err = errors.Wrap(err, "creating pair tables")
-		if errR := tx.Rollback(); err != nil {
-			return errors.Wrap(errors.Wrap(errR, "rolling back transaction"), err.Error())
+	var batch orderbook.EventBatch
+	err := db.withTx(ctx, func(tx *sql.Tx) error {
+		pair, err := db.getPair(ctx, tx, getPairForShareQuery, order.TokenBid, order.TokenAsk)
+		if err != nil {
+			return err
+		}
+		if err := pair.CheckOrder(order); err != nil {
+			return err
+		}
+
+		err = tx.QueryRowContext(ctx, addOrderQuery, order.Id, order.MakerId, order.TokenBid, order.TokenAsk, order.Rate, order.MaxVolume, order.MinVolume, order.ExpiresAt).Scan(&order.Priority)
+		if err != nil {
+			if isUniqueViolation(err) {
+				return errors.Wrapf(orderbook.ErrDuplicateOrder, "order %s", order.Id)
+			}
+			return errors.Wrap(err, "inserting order")
+		}
+
+		order.Status = orderbook.OrderOpen
+		if err := db.addStatusChange(ctx, tx, order); err != nil {
+			return err
 		}
 
+		return db.emit(ctx, tx, &batch, orderbook.NewOrderEvent(orderbook.EventOrderAdded, order, nil, db.options.Clock.Now()))
+	})
+	db.publish(batch, err)
+
+	return err
+}
+
+// UpdatePair updating metadata and status of existing pair
+func (db *Database) UpdatePair(ctx context.Context, pair orderbook.Pair) error {
+	if err := pair.Validate(); err != nil {
 		return err
 	}
+	if pair.Status == "" {
+		pair.Status = orderbook.PairActive
+	}
 
-	return nil
+	var batch orderbook.EventBatch
+	err := db.withTx(ctx, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, updatePairQuery, pair.TokenBid, pair.TokenAsk, pair.TickSize, pair.LotSize, pair.MinNotional, pair.Status).Scan(&pair.TokenBid, &pair.TokenAsk)
+		if errors.Is(err, sql.ErrNoRows) {
+			return errors.Wrapf(orderbook.ErrPairNotFound, "pair %s_%s", pair.TokenBid, pair.TokenAsk)
+		}
+		if err != nil {
+			return errors.Wrap(err, "updating pair")
+		}
+
+		return db.emit(ctx, tx, &batch, orderbook.NewPairEvent(orderbook.EventPairUpdated, pair, db.options.Clock.Now()))
+	})
+	db.publish(batch, err)
+
+	return err
+}
+
+// GetPair getting pair by tokens of any direction
+func (db *Database) GetPair(ctx context.Context, tokenBid, tokenAsk string) (orderbook.Pair, error) {
+	return db.getPair(ctx, db.conn, getPairQuery, tokenBid, tokenAsk)
 }
 
-// AddOrder adding new order to orderbook
-func (db *Database) AddOrder(order orderbook.Order) error {
-	tx, err := db.conn.BeginTx(context.Background(), nil)
+// PairExists checking that pair with tokens of any direction exists
+func (db *Database) PairExists(ctx context.Context, tokenBid, tokenAsk string) (bool, error) {
+	if _, err := db.GetPair(ctx, tokenBid, tokenAsk); err != nil {
+		if errors.Is(err, orderbook.ErrPairNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+// ListPairs getting all pairs of orderbook ordered by tokens
+func (db *Database) ListPairs(ctx context.Context) ([]orderbook.Pair, error) {
+	rows, err := db.conn.QueryContext(ctx, listPairsQuery)
 	if err != nil {
-		return errors.Wrap(err, "beginning transaction")
+		return nil, errors.Wrap(err, "getting pairs")
 	}
+	defer rows.Close()
 
-	if _, err := tx.Exec(addOrderQuery, order.Id, order.MakerId, order.TokenBid, order.TokenAsk, order.Rate, order.MaxVolume, order.MaxVolume); err != nil {
-		err = errors.Wrap(err, "inserting order")
-		if errR := tx.Rollback(); err != nil {
-			return errors.Wrap(errors.Wrap(errR, "rolling back transaction"), err.Error())
+	pairs := make([]orderbook.Pair, 0)
+	for rows.Next() {
+		var pair orderbook.Pair
+		if err := rows.Scan(&pair.TokenBid, &pair.TokenAsk, &pair.TickSize, &pair.LotSize, &pair.MinNotional, &pair.Status); err != nil {
+			return nil, errors.Wrap(err, "scanning rows")
 		}
+		pairs = append(pairs, pair)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterating rows")
+	}
 
-		return err
+	return pairs, nil
+}
+
+// MatchOrder matching taker order against orders of opposite pair with best rate, taken orders are filled or removed
+func (db *Database) MatchOrder(ctx context.Context, taker orderbook.Order) (orderbook.MatchResult, error) {
+	if err := db.options.Validator.Validate(taker); err != nil {
+		return orderbook.MatchResult{}, err
 	}
 
-	if err := tx.Commit(); err != nil {
-		return errors.Wrap(err, "committing transaction")
+	var (
+		result orderbook.MatchResult
+		batch  orderbook.EventBatch
+	)
+	err := db.withTx(ctx, func(tx *sql.Tx) (err error) {
+		result, err = db.matchOrder(ctx, tx, taker)
+		if err != nil {
+			return err
+		}
+
+		events := make([]orderbook.Event, 0, len(result.Fills))
+		for i, fill := range result.Fills {
+			events = append(events, orderbook.NewOrderEvent(orderbook.EventOrderFilled, fill.Order, &result.Trades[i], result.Trades[i].Time))
+		}
+		return db.emit(ctx, tx, &batch, events...)
+	})
+	db.publish(batch, err)
+	if err != nil {
+		return orderbook.MatchResult{}, err
 	}
 
-	return nil
+	return result, nil
 }
 
-// GetOrderById getting order from orderbook
-func (db *Database) GetOrderById(orderId string) (orderbook.Order, error) {
-	rows, err := db.conn.Query(getOrderFromOrdersTableQuery, orderId)
+// matchOrder matching taker order inside transaction, maker orders are locked until transaction ends
+func (db *Database) matchOrder(ctx context.Context, tx *sql.Tx, taker orderbook.Order) (orderbook.MatchResult, error) {
+	pair, err := db.getPair(ctx, tx, getPairForShareQuery, taker.TokenBid, taker.TokenAsk)
 	if err != nil {
-		return orderbook.Order{}, errors.Wrap(err, "getting order by id")
+		return orderbook.MatchResult{}, err
+	}
+	if pair.Status == orderbook.PairHalted {
+		return orderbook.MatchResult{}, errors.Wrapf(orderbook.ErrPairHalted, "pair %s_%s", pair.TokenBid, pair.TokenAsk)
 	}
 
-	ordersFromOrdersTable, err := db.parseSQLRowsFromOrdersTable(rows)
+	rows, err := tx.QueryContext(ctx, listMatchingOrdersQuery, taker.TokenAsk, taker.TokenBid, taker.Rate, db.options.Clock.Now())
 	if err != nil {
-		return orderbook.Order{}, errors.Wrap(err, "parsing sql rows from orders table")
+		return orderbook.MatchResult{}, errors.Wrap(err, "getting matching orders")
 	}
 
-	if len(ordersFromOrdersTable) == 0 {
-		return orderbook.Order{}, errors.Wrap(err, "no order with this id")
+	makers, err := db.parseSQLRowsToOrders(rows)
+	if err != nil {
+		return orderbook.MatchResult{}, errors.Wrap(err, "parsing sql rows to orders")
 	}
 
-	order, err := db.getOrderByPairAndId(orderId, ordersFromOrdersTable[0].TokenBid, ordersFromOrdersTable[0].TokenAsk)
-	if err != nil {
-		return orderbook.Order{}, errors.Wrap(err, "getting order by pair and id")
+	now := db.options.Clock.Now()
+	result := orderbook.MatchOrders(taker, makers)
+	for _, fill := range result.Fills {
+		if err := db.applyFill(ctx, tx, fill); err != nil {
+			return orderbook.MatchResult{}, err
+		}
+
+		trade, err := db.addTrade(ctx, tx, orderbook.NewTrade(taker, fill, now))
+		if err != nil {
+			return orderbook.MatchResult{}, err
+		}
+		result.Trades = append(result.Trades, trade)
 	}
 
-	return order, nil
+	return result, nil
 }
 
-// GetOrderWithMaxRate getting order from orderbook with max rate
-func (db *Database) GetOrderWithMaxRate(tokenBid, tokenAsk string) (orderbook.Order, error) {
-	rows, err := db.conn.Query(getOrderWithMaxRateQuery, tokenBid, tokenAsk)
+// Quote walking orders of opposite pair like MatchOrder to get amount of tokenAsk for tokenBid, the book isn't changed.
+// Orders are read by best rate until amount is filled, they are not locked.
+func (db *Database) Quote(ctx context.Context, tokenBid, tokenAsk string, amount decimal.Decimal) (orderbook.Quote, error) {
+	quote, err := orderbook.NewQuote(tokenBid, tokenAsk, amount)
 	if err != nil {
-		return orderbook.Order{}, errors.Wrap(err, "getting order with max rate")
+		return orderbook.Quote{}, err
 	}
 
-	orders, err := db.parseSQLRowsToOrders(rows)
+	pair, err := db.GetPair(ctx, tokenAsk, tokenBid)
 	if err != nil {
-		return orderbook.Order{}, errors.Wrap(err, "parsing sql rows to orders")
+		return orderbook.Quote{}, err
+	}
+	if pair.Status == orderbook.PairHalted {
+		return orderbook.Quote{}, errors.Wrapf(orderbook.ErrPairHalted, "pair %s_%s", pair.TokenBid, pair.TokenAsk)
 	}
 
-	if len(orders) == 0 {
-		return orderbook.Order{}, fmt.Errorf("no orders with this pair")
+	rows, err := db.conn.QueryContext(ctx, listQuoteOrdersQuery, tokenAsk, tokenBid, db.options.Clock.Now())
+	if err != nil {
+		return orderbook.Quote{}, errors.Wrap(err, "getting quote orders")
 	}
+	defer rows.Close()
 
-	return orders[0], nil
+	for rows.Next() {
+		maker, err := scanOrder(rows)
+		if err != nil {
+			return orderbook.Quote{}, err
+		}
+		if !quote.Take(maker) {
+			break
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return orderbook.Quote{}, errors.Wrap(err, "iterating rows")
+	}
+
+	return quote, nil
 }
 
-// GetOrderWithMinRate getting order from orderbook with min rate
-func (db *Database) GetOrderWithMinRate(tokenBid, tokenAsk string) (orderbook.Order, error) {
-	rows, err := db.conn.Query(getOrderWithMinRateQuery, tokenBid, tokenAsk)
+// AmendOrder changing rate and volumes of order in place, order loses time priority when rate is changed or max volume is increased
+func (db *Database) AmendOrder(ctx context.Context, orderId string, amendment orderbook.Amendment) (orderbook.Order, error) {
+	var (
+		amended orderbook.Order
+		batch   orderbook.EventBatch
+	)
+	err := db.withTx(ctx, func(tx *sql.Tx) error {
+		now := db.options.Clock.Now()
+		order, err := db.getOrder(ctx, tx, getOrderByIdForUpdateQuery, orderId, &now)
+		if err != nil {
+			return err
+		}
+
+		var losesPriority bool
+		amended, losesPriority = amendment.Apply(order)
+		if err := db.options.Validator.Validate(amended); err != nil {
+			return err
+		}
+
+		pair, err := db.getPair(ctx, tx, getPairForShareQuery, order.TokenBid, order.TokenAsk)
+		if err != nil {
+			return err
+		}
+		if err := pair.CheckOrder(amended); err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, updateOrderQuery, amended.Id, amended.Rate, amended.MaxVolume, amended.MinVolume); err != nil {
+			return errors.Wrap(err, "updating order")
+		}
+
+		if losesPriority {
+			if err := tx.QueryRowContext(ctx, resetOrderPriorityQuery, amended.Id).Scan(&amended.Priority); err != nil {
+				return errors.Wrap(err, "resetting order priority")
+			}
+		}
+
+		return db.emit(ctx, tx, &batch, orderbook.NewOrderEvent(orderbook.EventOrderUpdated, amended, nil, now))
+	})
+	db.publish(batch, err)
 	if err != nil {
-		return orderbook.Order{}, errors.Wrap(err, "getting order with min rate")
+		return orderbook.Order{}, err
 	}
 
-	orders, err := db.parseSQLRowsToOrders(rows)
+	return amended, nil
+}
+
+// FillOrder taking volume of order, order is closed and removed when the rest is less than MinVolume
+func (db *Database) FillOrder(ctx context.Context, orderId string, volume decimal.Decimal) (orderbook.Order, error) {
+	var (
+		filled orderbook.Order
+		batch  orderbook.EventBatch
+	)
+	err := db.withTx(ctx, func(tx *sql.Tx) error {
+		now := db.options.Clock.Now()
+		order, err := db.getOrder(ctx, tx, getOrderByIdForUpdateQuery, orderId, &now)
+		if err != nil {
+			return err
+		}
+
+		fill, err := order.Take(volume)
+		if err != nil {
+			return err
+		}
+
+		if err := db.applyFill(ctx, tx, fill); err != nil {
+			return err
+		}
+		trade, err := db.addTrade(ctx, tx, orderbook.NewTrade(orderbook.Order{}, fill, now))
+		if err != nil {
+			return err
+		}
+
+		filled = fill.Order
+		return db.emit(ctx, tx, &batch, orderbook.NewOrderEvent(orderbook.EventOrderFilled, filled, &trade, now))
+	})
+	db.publish(batch, err)
 	if err != nil {
-		return orderbook.Order{}, errors.Wrap(err, "parsing sql rows to orders")
+		return orderbook.Order{}, err
 	}
 
-	if len(orders) == 0 {
-		return orderbook.Order{}, fmt.Errorf("no orders with this pair")
+	return filled, nil
+}
+
+// applyFill updating remaining volume and status of filled order or closing it
+func (db *Database) applyFill(ctx context.Context, tx *sql.Tx, fill orderbook.Fill) error {
+	if fill.Closed {
+		return db.closeOrder(ctx, tx, fill.Order, fill.Order.Status)
 	}
 
-	return orders[0], nil
+	if _, err := tx.ExecContext(ctx, updateOrderMaxVolumeQuery, fill.Order.Id, fill.Order.MaxVolume, fill.Order.Status); err != nil {
+		return errors.Wrap(err, "updating order max volume")
+	}
+
+	return db.addStatusChange(ctx, tx, fill.Order)
 }
 
-// GetOrderWithMaxVolume getting order from orderbook with max volume
-func (db *Database) GetOrderWithMaxVolume(tokenBid, tokenAsk string) (orderbook.Order, error) {
-	rows, err := db.conn.Query(getOrderWithMaxVolumeQuery, tokenBid, tokenAsk)
+// addTrade inserting trade, returns trade with assigned id
+func (db *Database) addTrade(ctx context.Context, tx *sql.Tx, trade orderbook.Trade) (orderbook.Trade, error) {
+	err := tx.QueryRowContext(ctx, addTradeQuery, trade.MakerOrderId, trade.MakerId, trade.TakerOrderId, trade.TakerId, trade.TokenBid, trade.TokenAsk, trade.Rate, trade.Volume, trade.Time).Scan(&trade.Id)
 	if err != nil {
-		return orderbook.Order{}, errors.Wrap(err, "getting order with max volume")
+		return orderbook.Trade{}, errors.Wrap(err, "inserting trade")
 	}
 
-	orders, err := db.parseSQLRowsToOrders(rows)
-	if err != nil {
-		return orderbook.Order{}, errors.Wrap(err, "parsing sql rows to orders")
+	return trade, nil
+}
+
+// closeOrder setting final status of order, closed order is left out of order queries and stays in orders table with history
+func (db *Database) closeOrder(ctx context.Context, tx *sql.Tx, order orderbook.Order, status orderbook.OrderStatus) error {
+	order.Status = status
+	if _, err := tx.ExecContext(ctx, closeOrderQuery, order.Id, order.Status); err != nil {
+		return errors.Wrap(err, "closing order")
 	}
 
-	if len(orders) == 0 {
-		return orderbook.Order{}, fmt.Errorf("no orders with this pair")
+	return db.addStatusChange(ctx, tx, order)
+}
+
+// addStatusChange adding current status of order to order history
+func (db *Database) addStatusChange(ctx context.Context, tx *sql.Tx, order orderbook.Order) error {
+	change := orderbook.NewStatusChange(order, db.options.Clock.Now())
+	if _, err := tx.ExecContext(ctx, addStatusChangeQuery, change.OrderId, change.Status, change.Rate, change.MaxVolume, change.MinVolume, change.Time); err != nil {
+		return errors.Wrap(err, "adding order status change")
 	}
 
-	return orders[0], nil
+	return nil
+}
+
+// GetOrderById getting order from orderbook
+func (db *Database) GetOrderById(ctx context.Context, orderId string) (orderbook.Order, error) {
+	now := db.options.Clock.Now()
+	return db.getOrder(ctx, db.conn, getOrderByIdQuery, orderId, &now)
+}
+
+// GetOrderWithMaxRate getting order from orderbook with max rate
+func (db *Database) GetOrderWithMaxRate(ctx context.Context, tokenBid, tokenAsk string) (orderbook.Order, error) {
+	return db.getFirstOrder(ctx, getOrderWithMaxRateQuery, tokenBid, tokenAsk)
+}
+
+// GetOrderWithMinRate getting order from orderbook with min rate
+func (db *Database) GetOrderWithMinRate(ctx context.Context, tokenBid, tokenAsk string) (orderbook.Order, error) {
+	return db.getFirstOrder(ctx, getOrderWithMinRateQuery, tokenBid, tokenAsk)
+}
+
+// GetOrderWithMaxVolume getting order from orderbook with max volume
+func (db *Database) GetOrderWithMaxVolume(ctx context.Context, tokenBid, tokenAsk string) (orderbook.Order, error) {
+	return db.getFirstOrder(ctx, getOrderWithMaxVolumeQuery, tokenBid, tokenAsk)
 }
 
 // GetOrderWithMinVolume getting order from orderbook with min volume
-func (db *Database) GetOrderWithMinVolume(tokenBid, tokenAsk string) (orderbook.Order, error) {
-	rows, err := db.conn.Query(getOrderWithMinVolumeQuery, tokenBid, tokenAsk)
+func (db *Database) GetOrderWithMinVolume(ctx context.Context, tokenBid, tokenAsk string) (orderbook.Order, error) {
+	return db.getFirstOrder(ctx, getOrderWithMinVolumeQuery, tokenBid, tokenAsk)
+}
+
+// GetTopOfBook getting the best orders of both pair directions with spread and mid rate in tokenBid per tokenAsk.
+// Both orders are selected by single query, so they are taken from the same snapshot.
+func (db *Database) GetTopOfBook(ctx context.Context, tokenBid, tokenAsk string) (orderbook.TopOfBook, error) {
+	rows, err := db.conn.QueryContext(ctx, getTopOfBookQuery, tokenBid, tokenAsk, db.options.Clock.Now())
 	if err != nil {
-		return orderbook.Order{}, errors.Wrap(err, "getting order with min volume")
+		return orderbook.TopOfBook{}, errors.Wrap(err, "getting top of book")
 	}
 
 	orders, err := db.parseSQLRowsToOrders(rows)
 	if err != nil {
-		return orderbook.Order{}, errors.Wrap(err, "parsing sql rows to orders")
+		return orderbook.TopOfBook{}, errors.Wrap(err, "parsing sql rows to orders")
 	}
-
 	if len(orders) == 0 {
-		return orderbook.Order{}, fmt.Errorf("no orders with this pair")
+		if _, err := db.GetPair(ctx, tokenBid, tokenAsk); err != nil {
+			return orderbook.TopOfBook{}, err
+		}
 	}
 
-	return orders[0], nil
+	var bid, ask *orderbook.Order
+	for i := range orders {
+		if orders[i].TokenBid == tokenBid {
+			bid = &orders[i]
+		} else {
+			ask = &orders[i]
+		}
+	}
+
+	return orderbook.NewTopOfBook(tokenBid, tokenAsk, bid, ask), nil
 }
 
-// ListOrdersByPair getting orders from orderbook by pair
-func (db *Database) ListOrdersByPair(tokenBid, tokenAsk string, limit, offset int) ([]orderbook.Order, error) {
-	rows, err := db.conn.Query(listOrdersByPairQuery, tokenBid, tokenAsk, db.convertLimitOffset(limit, offset))
+// ListOrders getting page of orders matching query q, orders of any pairs and makers are listed if q doesn't select them.
+// Query of pair is served by composite pair index of sort column, other queries read live orders of every pair.
+func (db *Database) ListOrders(ctx context.Context, q orderbook.Query) (orderbook.Page, error) {
+	if err := q.Validate(); err != nil {
+		return orderbook.Page{}, err
+	}
+
+	key := querySortColumns(q)
+	query, args, err := buildListOrdersQuery(q, key, db.options.Clock.Now())
+	if err != nil {
+		return orderbook.Page{}, err
+	}
+
+	rows, err := db.conn.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, errors.Wrap(err, "getting orders by pair")
+		return orderbook.Page{}, errors.Wrap(err, "getting orders")
 	}
 
 	orders, err := db.parseSQLRowsToOrders(rows)
 	if err != nil {
-		return nil, errors.Wrap(err, "parsing sql rows to orders")
+		return orderbook.Page{}, errors.Wrap(err, "parsing sql rows to orders")
+	}
+	if len(orders) == 0 && q.HasPair() {
+		if _, err := db.GetPair(ctx, q.TokenBid, q.TokenAsk); err != nil {
+			return orderbook.Page{}, err
+		}
 	}
 
-	if len(orders) == 0 {
-		return nil, fmt.Errorf("no orders with this pair")
+	return key.page(orders, orderbook.PageLimit(q.Limit)), nil
+}
+
+// GetDepth getting at most levels best rate levels of both pair directions, orders are grouped by rate rounded down to tick.
+// Levels are aggregated by postgres from composite pair index of rate.
+func (db *Database) GetDepth(ctx context.Context, tokenBid, tokenAsk string, levels int, tick decimal.Decimal) (orderbook.Depth, error) {
+	if err := orderbook.CheckDepth(tick); err != nil {
+		return orderbook.Depth{}, err
+	}
+
+	if _, err := db.GetPair(ctx, tokenBid, tokenAsk); err != nil {
+		return orderbook.Depth{}, err
 	}
 
-	return orders, nil
+	depth := orderbook.Depth{TokenBid: tokenBid, TokenAsk: tokenAsk}
+	var err error
+	if depth.Levels, err = db.getDepthLevels(ctx, tokenBid, tokenAsk, levels, tick); err != nil {
+		return orderbook.Depth{}, err
+	}
+	if depth.ReverseLevels, err = db.getDepthLevels(ctx, tokenAsk, tokenBid, levels, tick); err != nil {
+		return orderbook.Depth{}, err
+	}
+
+	return depth, nil
 }
 
-// ListOrdersByMakerId getting order from orderbook
-func (db *Database) ListOrdersByMakerId(makerId string, limit, offset int) ([]orderbook.Order, error) {
-	rows, err := db.conn.Query(listOrdersByMakerIdQuery, makerId, db.convertLimitOffset(limit, offset))
-	if err != nil {
-		return nil, errors.Wrap(err, "getting order by maker id")
+// getDepthLevels getting rate levels of pair direction, non-positive levels means all levels
+func (db *Database) getDepthLevels(ctx context.Context, tokenBid, tokenAsk string, levels int, tick decimal.Decimal) ([]orderbook.DepthLevel, error) {
+	var limit interface{} // NULL limit is LIMIT ALL
+	if levels > 0 {
+		limit = levels
 	}
 
-	orders, err := db.parseSQLRowsToOrders(rows)
+	rows, err := db.conn.QueryContext(ctx, getDepthQuery, tokenBid, tokenAsk, tick, db.options.Clock.Now(), limit)
 	if err != nil {
-		return nil, errors.Wrap(err, "parsing sql rows to orders")
+		return nil, errors.Wrap(err, "getting depth")
 	}
+	defer rows.Close()
 
-	if len(orders) == 0 {
-		return nil, fmt.Errorf("no orders with this maker id")
+	result := make([]orderbook.DepthLevel, 0)
+	for rows.Next() {
+		var level orderbook.DepthLevel
+		if err := rows.Scan(&level.Rate, &level.Volume, &level.Orders); err != nil {
+			return nil, errors.Wrap(err, "scanning rows")
+		}
+		result = append(result, level)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterating rows")
+	}
+
+	return result, nil
+}
+
+// RemovePair removing pair from orderbook, live orders of both pair directions are cancelled
+func (db *Database) RemovePair(ctx context.Context, tokenBid, tokenAsk string) error {
+	var batch orderbook.EventBatch
+	err := db.withTx(ctx, func(tx *sql.Tx) error {
+		pair, err := db.getPair(ctx, tx, getPairForShareQuery, tokenBid, tokenAsk)
+		if err != nil {
+			return err
+		}
+
+		result, err := tx.ExecContext(ctx, removePairFromPairsTableQuery, tokenBid, tokenAsk)
+		if err != nil {
+			return errors.Wrap(err, "exec remove pair from pairs table query")
+		}
+
+		removed, err := result.RowsAffected()
+		if err != nil {
+			return errors.Wrap(err, "getting removed rows")
+		}
+		if removed == 0 {
+			return errors.Wrapf(orderbook.ErrPairNotFound, "pair %s_%s", tokenBid, tokenAsk)
+		}
+
+		now := db.options.Clock.Now()
+		events := make([]orderbook.Event, 0)
+		for _, direction := range [][2]string{{tokenBid, tokenAsk}, {tokenAsk, tokenBid}} {
+			rows, err := tx.QueryContext(ctx, listPairOrdersForUpdateQuery, direction[0], direction[1])
+			if err != nil {
+				return errors.Wrap(err, "getting pair orders")
+			}
+			orders, err := db.parseSQLRowsToOrders(rows)
+			if err != nil {
+				return errors.Wrap(err, "parsing sql rows to orders")
+			}
+			for _, order := range orders {
+				order.Status = orderbook.OrderCancelled
+				events = append(events, orderbook.NewOrderEvent(orderbook.EventOrderRemoved, order, nil, now))
+			}
+
+			if _, err := tx.ExecContext(ctx, addPairOrdersCancelledStatusQuery, direction[0], direction[1], now); err != nil {
+				return errors.Wrap(err, "exec add pair orders cancelled status query")
+			}
+		}
+		if _, err := tx.ExecContext(ctx, cancelPairOrdersQuery, tokenBid, tokenAsk); err != nil {
+			return errors.Wrap(err, "exec cancel pair orders query")
+		}
+
+		events = append(events, orderbook.NewPairEvent(orderbook.EventPairRemoved, pair, now))
+		return db.emit(ctx, tx, &batch, events...)
+	})
+	db.publish(batch, err)
 
-	return orders, nil
+	return err
 }
 
-// ListMaxRateOrders getting orders from orderbook with max rate
-func (db *Database) ListMaxRateOrders(tokenBid, tokenAsk string, limit, offset int) ([]orderbook.Order, error) {
-	rows, err := db.conn.Query(listMaxRateOrdersQuery, tokenBid, tokenAsk, db.convertLimitOffset(limit, offset))
+// RemoveOrder removing order from orderbook, order is kept in history with OrderCancelled status
+func (db *Database) RemoveOrder(ctx context.Context, orderId string) error {
+	var batch orderbook.EventBatch
+	err := db.withTx(ctx, func(tx *sql.Tx) error {
+		now := db.options.Clock.Now()
+		order, err := db.getOrder(ctx, tx, getOrderByIdForUpdateQuery, orderId, &now)
+		if err != nil {
+			return err
+		}
+		if err := db.closeOrder(ctx, tx, order, orderbook.OrderCancelled); err != nil {
+			return err
+		}
+
+		order.Status = orderbook.OrderCancelled
+		return db.emit(ctx, tx, &batch, orderbook.NewOrderEvent(orderbook.EventOrderRemoved, order, nil, now))
+	})
+	db.publish(batch, err)
+
+	return err
+}
+
+// getPair getting pair by tokens of any direction with query, returns orderbook.ErrPairNotFound if there is no pair
+func (db *Database) getPair(ctx context.Context, q queryer, query, tokenBid, tokenAsk string) (orderbook.Pair, error) {
+	var pair orderbook.Pair
+	err := q.QueryRowContext(ctx, query, tokenBid, tokenAsk).Scan(&pair.TokenBid, &pair.TokenAsk, &pair.TickSize, &pair.LotSize, &pair.MinNotional, &pair.Status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return orderbook.Pair{}, errors.Wrapf(orderbook.ErrPairNotFound, "pair %s_%s", tokenBid, tokenAsk)
+	}
 	if err != nil {
-		return nil, errors.Wrap(err, "getting orders with max rate")
+		return orderbook.Pair{}, errors.Wrap(err, "getting pair")
+	}
+
+	return pair, nil
+}
+
+// getOrder getting live order by id with query, orders expired at aliveAt are not returned, nil aliveAt includes expired orders
+func (db *Database) getOrder(ctx context.Context, q queryer, query, orderId string, aliveAt *time.Time) (orderbook.Order, error) {
+	rows, err := q.QueryContext(ctx, query, orderId, aliveAt)
+	if err != nil {
+		return orderbook.Order{}, errors.Wrap(err, "getting order by id")
 	}
 
 	orders, err := db.parseSQLRowsToOrders(rows)
 	if err != nil {
-		return nil, errors.Wrap(err, "parsing sql rows to orders")
+		return orderbook.Order{}, errors.Wrap(err, "parsing sql rows to orders")
 	}
 
 	if len(orders) == 0 {
-		return nil, fmt.Errorf("no orders with this pair")
+		return orderbook.Order{}, errors.Wrapf(orderbook.ErrOrderNotFound, "order %s", orderId)
 	}
 
-	return orders, nil
+	return orders[0], nil
 }
 
-// ListMinRateOrders getting orders from orderbook with min rate
-func (db *Database) ListMinRateOrders(tokenBid, tokenAsk string, limit, offset int) ([]orderbook.Order, error) {
-	rows, err := db.conn.Query(listMinRateOrdersQuery, tokenBid, tokenAsk, db.convertLimitOffset(limit, offset))
+// getFirstOrder getting the first live order of pair direction selected by query,
+// returns orderbook.ErrPairNotFound if there is no pair and orderbook.ErrOrderNotFound if pair has no orders
+func (db *Database) getFirstOrder(ctx context.Context, query, tokenBid, tokenAsk string) (orderbook.Order, error) {
+	rows, err := db.conn.QueryContext(ctx, query, tokenBid, tokenAsk, db.options.Clock.Now())
 	if err != nil {
-		return nil, errors.Wrap(err, "getting orders with min rate")
+		return orderbook.Order{}, errors.Wrap(err, "getting order")
 	}
 
 	orders, err := db.parseSQLRowsToOrders(rows)
 	if err != nil {
-		return nil, errors.Wrap(err, "parsing sql rows to orders")
+		return orderbook.Order{}, errors.Wrap(err, "parsing sql rows to orders")
 	}
 
 	if len(orders) == 0 {
-		return nil, fmt.Errorf("no orders with this pair")
+		if _, err := db.GetPair(ctx, tokenBid, tokenAsk); err != nil {
+			return orderbook.Order{}, err
+		}
+		return orderbook.Order{}, errors.Wrap(orderbook.ErrOrderNotFound, "no orders with this pair")
 	}
 
-	return orders, nil
+	return orders[0], nil
 }
 
-// ListMaxVolumeOrders getting orders from orderbook with max volume
-func (db *Database) ListMaxVolumeOrders(tokenBid, tokenAsk string, limit, offset int) ([]orderbook.Order, error) {
-	rows, err := db.conn.Query(listMaxVolumeOrdersQuery, tokenBid, tokenAsk, db.convertLimitOffset(limit, offset))
-	if err != nil {
-		return nil, errors.Wrap(err, "getting orders with max volume")
+// parseSQLRowsToOrders parsing sql.Rows to []orderbook.Order, rows are closed
+func (db *Database) parseSQLRowsToOrders(rows *sql.Rows) ([]orderbook.Order, error) {
+	defer rows.Close()
+
+	orders := make([]orderbook.Order, 0)
+	for rows.Next() {
+		order, err := scanOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, order)
 	}
 
-	orders, err := db.parseSQLRowsToOrders(rows)
-	if err != nil {
-		return nil, errors.Wrap(err, "parsing sql rows to orders")
+	return orders, errors.Wrap(rows.Err(), "iterating rows")
+}
+
+// scanOrder scanning current row of order query
+func scanOrder(rows *sql.Rows) (orderbook.Order, error) {
+	var order orderbook.Order
+	if err := rows.Scan(&order.Id, &order.MakerId, &order.TokenBid, &order.TokenAsk, &order.Rate, &order.MaxVolume, &order.MinVolume, &order.Priority, &order.ExpiresAt, &order.Status); err != nil {
+		return orderbook.Order{}, errors.Wrap(err, "scanning rows")
 	}
 
-	if len(orders) == 0 {
-		return nil, fmt.Errorf("no orders with this pair")
+	return order, nil
+}
+
+// parseSQLRowsToTrades parsing sql.Rows to []orderbook.Trade, rows are closed
+func (db *Database) parseSQLRowsToTrades(rows *sql.Rows) ([]orderbook.Trade, error) {
+	defer rows.Close()
+
+	trades := make([]orderbook.Trade, 0)
+	for rows.Next() {
+		var trade orderbook.Trade
+		if err := rows.Scan(&trade.Id, &trade.MakerOrderId, &trade.MakerId, &trade.TakerOrderId, &trade.TakerId, &trade.TokenBid, &trade.TokenAsk, &trade.Rate, &trade.Volume, &trade.Time); err != nil {
+			return nil, errors.Wrap(err, "scanning rows")
+		}
+		trades = append(trades, trade)
 	}
 
-	return orders, nil
+	return trades, errors.Wrap(rows.Err(), "iterating rows")
 }
 
-// ListMinVolumeOrders getting orders from orderbook with min volume
-func (db *Database) ListMinVolumeOrders(tokenBid, tokenAsk string, limit, offset int) ([]orderbook.Order, error) {
-	rows, err := db.conn.Query(listMinVolumeOrdersQuery, tokenBid, tokenAsk, db.convertLimitOffset(limit, offset))
+// nullTime converting zero time to nil, so open bound of time range is null in query
+func nullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+
+	return &t
+}
+
+// RemoveExpiredOrders removing orders expired by orderbook clock with OrderExpired status, returns number of removed orders
+func (db *Database) RemoveExpiredOrders(ctx context.Context) (int, error) {
+	var (
+		removed int
+		batch   orderbook.EventBatch
+	)
+	err := db.withTx(ctx, func(tx *sql.Tx) error {
+		now := db.options.Clock.Now()
+		rows, err := tx.QueryContext(ctx, listExpiredOrdersQuery, now)
+		if err != nil {
+			return errors.Wrap(err, "getting expired orders")
+		}
+
+		expired, err := db.parseSQLRowsToOrders(rows)
+		if err != nil {
+			return errors.Wrap(err, "parsing sql rows to orders")
+		}
+
+		events := make([]orderbook.Event, 0, len(expired))
+		for _, order := range expired {
+			if err := db.closeOrder(ctx, tx, order, orderbook.OrderExpired); err != nil {
+				return err
+			}
+
+			order.Status = orderbook.OrderExpired
+			events = append(events, orderbook.NewOrderEvent(orderbook.EventOrderRemoved, order, nil, now))
+		}
+
+		removed = len(expired)
+		return db.emit(ctx, tx, &batch, events...)
+	})
+	db.publish(batch, err)
 	if err != nil {
-		return nil, errors.Wrap(err, "getting orders with min volume")
+		return 0, err
 	}
 
-	orders, err := db.parseSQLRowsToOrders(rows)
+	return removed, nil
+}
+
+// GetOrderHistory getting status changes of order in chronological order, removed orders have history too
+func (db *Database) GetOrderHistory(ctx context.Context, orderId string) ([]orderbook.StatusChange, error) {
+	rows, err := db.conn.QueryContext(ctx, getOrderHistoryQuery, orderId)
 	if err != nil {
-		return nil, errors.Wrap(err, "parsing sql rows to orders")
+		return nil, errors.Wrap(err, "getting order history")
 	}
+	defer rows.Close()
 
-	if len(orders) == 0 {
-		return nil, fmt.Errorf("no orders with this pair")
+	history := make([]orderbook.StatusChange, 0)
+	for rows.Next() {
+		var change orderbook.StatusChange
+		if err := rows.Scan(&change.OrderId, &change.Status, &change.Rate, &change.MaxVolume, &change.MinVolume, &change.Time); err != nil {
+			return nil, errors.Wrap(err, "scanning rows")
+		}
+		history = append(history, change)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterating rows")
 	}
 
-	return orders, nil
+	if len(history) == 0 {
+		return nil, errors.Wrapf(orderbook.ErrOrderNotFound, "order %s", orderId)
+	}
+
+	return history, nil
 }
 
-// RemovePair removing pair from orderbook
-func (db *Database) RemovePair(tokenBid, tokenAsk string) error {
-	tx, err := db.conn.BeginTx(context.Background(), nil)
+// GetTrade getting trade by id
+func (db *Database) GetTrade(ctx context.Context, tradeId int64) (orderbook.Trade, error) {
+	rows, err := db.conn.QueryContext(ctx, getTradeQuery, tradeId)
 	if err != nil {
-		return errors.Wrap(err, "beginning transaction")
+		return orderbook.Trade{}, errors.Wrap(err, "getting trade")
 	}
 
-	_, err = tx.Exec(removePairQuery, tokenBid, tokenAsk)
+	trades, err := db.parseSQLRowsToTrades(rows)
 	if err != nil {
-		err = errors.Wrap(err, "exec remove pair query")
-		if errR := tx.Rollback(); err != nil {
-			return errors.Wrap(errors.Wrap(errR, "rolling back transaction"), err.Error())
-		}
-		return err
+		return orderbook.Trade{}, errors.Wrap(err, "parsing sql rows to trades")
 	}
 
-	err = tx.Commit()
-	if err != nil {
-		return errors.Wrap(err, "committing transaction")
+	if len(trades) == 0 {
+		return orderbook.Trade{}, errors.Wrapf(orderbook.ErrTradeNotFound, "trade %d", tradeId)
 	}
 
-	return nil
+	return trades[0], nil
 }
 
-// RemoveOrder removing order from orderbook
-func (db *Database) RemoveOrder(orderId string) error {
-	_, err := db.conn.Exec(removeOrderQuery, orderId)
+// ListTradesByPair getting trades of both pair directions in time range
+func (db *Database) ListTradesByPair(ctx context.Context, tokenBid, tokenAsk string, period orderbook.TimeRange, cursor orderbook.Cursor, limit int) (orderbook.TradePage, error) {
+	position, after, err := decodeCursor(cursor)
 	if err != nil {
-		return errors.Wrap(err, "exec remove order query")
+		return orderbook.TradePage{}, err
 	}
 
-	return nil
+	limit = orderbook.PageLimit(limit)
+	rows, err := db.conn.QueryContext(ctx, listTradesByPairQuery, tokenBid, tokenAsk, nullTime(period.From), nullTime(period.To), after, position.Seq, limit+1)
+	if err != nil {
+		return orderbook.TradePage{}, errors.Wrap(err, "getting trades by pair")
+	}
+
+	trades, err := db.parseSQLRowsToTrades(rows)
+	if err != nil {
+		return orderbook.TradePage{}, errors.Wrap(err, "parsing sql rows to trades")
+	}
+
+	return tradePage(trades, limit), nil
 }
 
-// getOrderByPairAndId getting order from orderbook by pair and id
-func (db *Database) getOrderByPairAndId(orderId, tokenBid, tokenAsk string) (orderbook.Order, error) {
-	rows, err := db.conn.Query(getOrderByIdAndPairQuery, orderId, tokenBid, tokenAsk)
+// ListTradesByMaker getting trades of maker orders in time range
+func (db *Database) ListTradesByMaker(ctx context.Context, makerId string, period orderbook.TimeRange, cursor orderbook.Cursor, limit int) (orderbook.TradePage, error) {
+	position, after, err := decodeCursor(cursor)
 	if err != nil {
-		return orderbook.Order{}, errors.Wrap(err, "getting order by pair and id")
+		return orderbook.TradePage{}, err
 	}
 
-	orderIdWithPair, err := db.parseSQLRowsFromOrdersTable(rows)
+	limit = orderbook.PageLimit(limit)
+	rows, err := db.conn.QueryContext(ctx, listTradesByMakerQuery, makerId, nullTime(period.From), nullTime(period.To), after, position.Seq, limit+1)
 	if err != nil {
-		return orderbook.Order{}, errors.Wrap(err, "parsing sql rows from orders table")
+		return orderbook.TradePage{}, errors.Wrap(err, "getting trades by maker")
 	}
 
-	if len(orderIdWithPair) == 0 {
-		return orderbook.Order{}, errors.Wrap(err, "no order with this id")
+	trades, err := db.parseSQLRowsToTrades(rows)
+	if err != nil {
+		return orderbook.TradePage{}, errors.Wrap(err, "parsing sql rows to trades")
 	}
 
-	return orderbook.Order{}, nil
+	return tradePage(trades, limit), nil
 }
 
-// parseSQLRowsToOrders parsing sql.Rows to []orderbook.Order
-func (db *Database) parseSQLRowsToOrders(rows *sql.Rows) ([]orderbook.Order, error) {
-	orders := make([]orderbook.Order, 0)
-	for rows.Next() {
-		var order orderbook.Order
-		if err := rows.Scan(&order.Id, &order.MakerId, &order.TokenBid, &order.TokenAsk, &order.Rate, &order.MaxVolume, &order.MinVolume); err != nil {
-			return nil, errors.Wrap(err, "scanning rows")
-		}
-		orders = append(orders, order)
+// Subscribe getting subscription of book changes selected by filter, only changes made by this Database are sent.
+// Events have sequences shared with Listener, so changes made by other instances leave gaps in them.
+func (db *Database) Subscribe(filter orderbook.EventFilter) *orderbook.Subscription {
+	return db.events.Subscribe(filter)
+}
+
+// publish publishing batch prepared inside transaction after it ends, batch of failed transaction is discarded,
+// so batches of the next transactions don't wait for it
+func (db *Database) publish(batch orderbook.EventBatch, err error) {
+	if err != nil {
+		db.events.Discard(batch)
+		return
 	}
 
-	return orders, nil
+	db.events.Publish(batch)
 }
 
-// parseSQLRowsFromOrdersTable parsing sql.Rows form orders table to []orderbook.Order
-func (db *Database) parseSQLRowsFromOrdersTable(rows *sql.Rows) ([]orderbook.Order, error) {
-	orders := make([]orderbook.Order, 0)
-	for rows.Next() {
-		var order orderbook.Order
-		if err := rows.Scan(&order.Id, &order.MakerId, &order.TokenBid, &order.TokenAsk); err != nil {
-			return nil, errors.Wrap(err, "scanning rows")
+// emit numbering events of change with sequences of their pairs shared by all instances, adding them to outbox
+// and sending them to EventsChannel. Outbox rows and notifications are committed with the change.
+// The sequence row of pair is locked until commit, so outbox ids of pair events grow in order of sequences,
+// and batch published to subscriptions of this Database is prepared while it is locked, so batches go in order of sequences.
+func (db *Database) emit(ctx context.Context, tx *sql.Tx, batch *orderbook.EventBatch, events ...orderbook.Event) error {
+	for i := range events {
+		tokenA, tokenB := eventPair(events[i].TokenBid, events[i].TokenAsk)
+		if err := tx.QueryRowContext(ctx, nextEventSeqQuery, tokenA, tokenB).Scan(&events[i].Seq); err != nil {
+			return errors.Wrap(err, "getting next event seq")
+		}
+
+		payload, err := json.Marshal(events[i])
+		if err != nil {
+			return errors.Wrap(err, "marshalling event")
+		}
+		if _, err := tx.ExecContext(ctx, addOutboxEventQuery, payload, events[i].Time); err != nil {
+			return errors.Wrap(err, "adding outbox event")
+		}
+		if _, err := tx.ExecContext(ctx, notifyEventQuery, EventsChannel, string(payload)); err != nil {
+			return errors.Wrap(err, "notifying event")
 		}
-		orders = append(orders, order)
 	}
 
-	return orders, nil
+	*batch = db.events.PrepareSequenced(events...)
+	return nil
 }
 
-// convertLimitOffset converting limit and offset to part of query
-func (db *Database) convertLimitOffset(limit, offset int) string {
-	result := ""
-	if limit != -1 {
-		result += fmt.Sprintf(" LIMIT %d", limit)
+// withTx running fn inside transaction, transaction is rolled back if fn returns error and committed otherwise
+func (db *Database) withTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "beginning transaction")
 	}
-	if offset != -1 {
-		result += fmt.Sprintf(" OFFSET %d", offset)
+
+	if err := fn(tx); err != nil {
+		if errR := tx.Rollback(); errR != nil {
+			return errors.Wrap(errors.Wrap(errR, "rolling back transaction"), err.Error())
+		}
+
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "committing transaction")
 	}
 
-	return result
+	return nil
 }
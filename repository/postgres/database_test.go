@@ -0,0 +1,78 @@
+package postgres
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/SashaBokov/orderbook"
+	"github.com/SashaBokov/orderbook/internal/booktest"
+)
+
+// testDatabaseURLEnv is an environment variable with URL of database used by tests, tests are skipped without it.
+// Tables of orderbook in the database are emptied and pair tables left by migration tests are dropped by every test.
+const testDatabaseURLEnv = "ORDERBOOK_TEST_DATABASE_URL"
+
+var truncateTablesQuery = `
+TRUNCATE orderbook_trades, orderbook_order_history, orderbook_orders, orderbook_pairs, orderbook_event_seqs, orderbook_outbox CASCADE;
+`
+
+// dropPairTablesQuery dropping pair tables left by previous tests, they are found by suffixes of their names
+var dropPairTablesQuery = `
+DO $$
+DECLARE
+    t RECORD;
+BEGIN
+    FOR t IN SELECT tablename FROM pg_tables
+        WHERE schemaname = current_schema() AND tablename NOT LIKE 'orderbook\_%'
+            AND (tablename LIKE '%\_rate' OR tablename LIKE '%\_max\_volume' OR tablename LIKE '%\_min\_volume') LOOP
+        EXECUTE format('DROP TABLE %I', t.tablename);
+    END LOOP;
+END
+$$;
+`
+
+// openTestDatabase returning database with empty tables, test is skipped if testDatabaseURLEnv is not set
+func openTestDatabase(tb testing.TB, opts ...orderbook.Option) *Database {
+	tb.Helper()
+
+	databaseURL := os.Getenv(testDatabaseURLEnv)
+	if databaseURL == "" {
+		tb.Skipf("%s is not set", testDatabaseURLEnv)
+	}
+
+	db, err := New(context.Background(), databaseURL, opts...)
+	if err != nil {
+		tb.Fatalf("opening database: %v", err)
+	}
+	tb.Cleanup(func() { _ = db.conn.Close() })
+
+	for _, query := range []string{dropPairTablesQuery, truncateTablesQuery} {
+		if _, err := db.conn.Exec(query); err != nil {
+			tb.Fatalf("emptying tables: %v", err)
+		}
+	}
+
+	return db
+}
+
+func TestDatabase(t *testing.T) {
+	booktest.Run(t, func(t *testing.T, opts ...orderbook.Option) orderbook.OrderBook {
+		return openTestDatabase(t, opts...)
+	})
+}
+
+func TestNewOrderBookPostgres(t *testing.T) {
+	databaseURL := os.Getenv(testDatabaseURLEnv)
+	if databaseURL == "" {
+		t.Skipf("%s is not set", testDatabaseURLEnv)
+	}
+
+	book, err := orderbook.NewOrderBookPostgres(databaseURL)
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	if _, ok := book.(*Database); !ok {
+		t.Fatalf("expected postgres implementation, got %T", book)
+	}
+}
@@ -0,0 +1,31 @@
+package postgres
+
+import (
+	"github.com/pkg/errors"
+)
+
+// Postgres error codes, see https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	uniqueViolation = "23505"
+	undefinedTable  = "42P01"
+)
+
+// sqlState getting SQLSTATE code of postgres error, works with lib/pq and pgx drivers
+func sqlState(err error) string {
+	var pgErr interface{ SQLState() string }
+	if errors.As(err, &pgErr) {
+		return pgErr.SQLState()
+	}
+
+	return ""
+}
+
+// isUniqueViolation checks that err is caused by unique constraint violation
+func isUniqueViolation(err error) bool {
+	return sqlState(err) == uniqueViolation
+}
+
+// isUndefinedTable checks that err is caused by missing table, migrations table is missing before the first migration
+func isUndefinedTable(err error) bool {
+	return sqlState(err) == undefinedTable
+}
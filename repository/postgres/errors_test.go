@@ -0,0 +1,33 @@
+package postgres
+
+import (
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+func TestSQLState(t *testing.T) {
+	tests := []struct {
+		name            string
+		err             error
+		uniqueViolation bool
+		undefinedTable  bool
+	}{
+		{"unique violation", &pq.Error{Code: uniqueViolation}, true, false},
+		{"wrapped unique violation", errors.Wrap(&pq.Error{Code: uniqueViolation}, "inserting order"), true, false},
+		{"undefined table", errors.Wrap(&pq.Error{Code: undefinedTable}, "getting schema version"), false, true},
+		{"other postgres error", &pq.Error{Code: "40001"}, false, false},
+		{"not postgres error", errors.New("connection refused"), false, false},
+		{"nil", nil, false, false},
+	}
+
+	for _, test := range tests {
+		if got := isUniqueViolation(test.err); got != test.uniqueViolation {
+			t.Errorf("%s: expected unique violation %v, got %v", test.name, test.uniqueViolation, got)
+		}
+		if got := isUndefinedTable(test.err); got != test.undefinedTable {
+			t.Errorf("%s: expected undefined table %v, got %v", test.name, test.undefinedTable, got)
+		}
+	}
+}
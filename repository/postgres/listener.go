@@ -0,0 +1,187 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/SashaBokov/orderbook"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+// EventsChannel is a postgres notification channel of events of book changes made by Database instances
+const EventsChannel = "orderbook_events"
+
+const (
+	minReconnectInterval = 100 * time.Millisecond
+	maxReconnectInterval = 10 * time.Second
+	// pingInterval is an interval of checking idle listener connection, lost connection is noticed and restored by ping
+	pingInterval = 30 * time.Second
+)
+
+// Gap is a range of event sequences of pair missed by Listener, changes of gap may be read from the book
+type Gap struct {
+	// TokenBid and TokenAsk are pair tokens in any direction
+	TokenBid string `json:"token_bid"`
+	TokenAsk string `json:"token_ask"`
+	// From and To are the first and the last missed sequences
+	From uint64 `json:"from"`
+	To   uint64 `json:"to"`
+}
+
+// Listener receiving events notified by Database instances sharing the database.
+// Event.Seq of notified events is a sequence of pair shared by all instances, so missed notifications are detected by gaps in it.
+type Listener struct {
+	conn        *sql.DB
+	databaseURL string
+	seqs        map[[2]string]uint64 // last received sequences by pair tokens sorted with eventPair
+	// OnGap is called when notifications are missed, the next event of pair has Missed set as well.
+	// Events of gap are not handled even if their notifications arrive later.
+	OnGap func(gap Gap)
+	// OnError is called on connection and notification errors, listener keeps reconnecting after them.
+	// It may be called from another goroutine.
+	OnError func(err error)
+}
+
+func NewListener(ctx context.Context, databaseURL string) (*Listener, error) {
+	conn, err := sql.Open("postgres", databaseURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "connecting to database")
+	}
+
+	if err := conn.PingContext(ctx); err != nil {
+		return nil, errors.Wrap(err, "pinging database")
+	}
+
+	return &Listener{conn: conn, databaseURL: databaseURL, seqs: make(map[[2]string]uint64)}, nil
+}
+
+// Run calling handle with notified events in order of pair sequences until ctx is done, returns ctx error.
+// Events committed before Run are not received, duplicated events are skipped.
+func (l *Listener) Run(ctx context.Context, handle func(event orderbook.Event)) error {
+	listener := pq.NewListener(l.databaseURL, minReconnectInterval, maxReconnectInterval, func(_ pq.ListenerEventType, err error) {
+		if err != nil {
+			l.error(errors.Wrap(err, "listening notifications"))
+		}
+	})
+	defer listener.Close()
+
+	if err := listener.Listen(EventsChannel); err != nil {
+		return errors.Wrap(err, "listening events channel")
+	}
+
+	// sequences are read after LISTEN, so events committed after reading are received
+	if err := l.sync(ctx, false); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			go func() {
+				if err := listener.Ping(); err != nil {
+					l.error(errors.Wrap(err, "pinging listener"))
+				}
+			}()
+		case notification := <-listener.Notify:
+			// nil notification is sent after reconnect, notifications might be lost while connection was down
+			if notification == nil {
+				if err := l.sync(ctx, true); err != nil {
+					l.error(err)
+				}
+				continue
+			}
+
+			var event orderbook.Event
+			if err := json.Unmarshal([]byte(notification.Extra), &event); err != nil {
+				l.error(errors.Wrap(err, "unmarshalling event"))
+				continue
+			}
+			if event, ok := l.receive(event); ok {
+				handle(event)
+			}
+		}
+	}
+}
+
+// Close closing database connection
+func (l *Listener) Close() error {
+	return l.conn.Close()
+}
+
+// receive checking event sequence, returns event with Missed set or false if event is already received
+func (l *Listener) receive(event orderbook.Event) (orderbook.Event, bool) {
+	tokenA, tokenB := eventPair(event.TokenBid, event.TokenAsk)
+	last := l.seqs[[2]string{tokenA, tokenB}]
+	if event.Seq <= last {
+		return orderbook.Event{}, false
+	}
+
+	if event.Seq > last+1 {
+		event.Missed = event.Seq - last - 1
+		l.gap(Gap{TokenBid: tokenA, TokenAsk: tokenB, From: last + 1, To: event.Seq - 1})
+	}
+	l.seqs[[2]string{tokenA, tokenB}] = event.Seq
+
+	return event, true
+}
+
+// sync reading current sequences of pairs, sequences greater than received ones are reported as gaps if reportGaps is set
+func (l *Listener) sync(ctx context.Context, reportGaps bool) error {
+	rows, err := l.conn.QueryContext(ctx, listEventSeqsQuery)
+	if err != nil {
+		return errors.Wrap(err, "getting event seqs")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			key [2]string
+			seq uint64
+		)
+		if err := rows.Scan(&key[0], &key[1], &seq); err != nil {
+			return errors.Wrap(err, "scanning rows")
+		}
+
+		if last := l.seqs[key]; reportGaps && seq > last {
+			l.gap(Gap{TokenBid: key[0], TokenAsk: key[1], From: last + 1, To: seq})
+		}
+		if seq > l.seqs[key] {
+			l.seqs[key] = seq
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return errors.Wrap(err, "iterating rows")
+	}
+
+	return nil
+}
+
+// gap calling OnGap if it is set
+func (l *Listener) gap(gap Gap) {
+	if l.OnGap != nil {
+		l.OnGap(gap)
+	}
+}
+
+// error calling OnError if it is set
+func (l *Listener) error(err error) {
+	if l.OnError != nil {
+		l.OnError(err)
+	}
+}
+
+// eventPair returning pair tokens sorted, so both pair directions have the same event sequence
+func eventPair(tokenBid, tokenAsk string) (string, string) {
+	if tokenAsk < tokenBid {
+		return tokenAsk, tokenBid
+	}
+
+	return tokenBid, tokenAsk
+}
@@ -0,0 +1,147 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/SashaBokov/orderbook"
+	"github.com/SashaBokov/orderbook/internal/booktest"
+)
+
+func TestListenerReceive(t *testing.T) {
+	var gaps []Gap
+	l := &Listener{seqs: make(map[[2]string]uint64), OnGap: func(gap Gap) { gaps = append(gaps, gap) }}
+	event := func(tokenBid, tokenAsk string, seq uint64) orderbook.Event {
+		return orderbook.Event{TokenBid: tokenBid, TokenAsk: tokenAsk, Seq: seq}
+	}
+
+	if received, ok := l.receive(event("BTC", "ETH", 1)); !ok || received.Missed != 0 {
+		t.Fatalf("expected the first event received, got %v, %v", received, ok)
+	}
+	// both pair directions share seqs, so event 1 of reversed tokens is a duplicate
+	if _, ok := l.receive(event("ETH", "BTC", 1)); ok {
+		t.Fatal("expected duplicated event skipped")
+	}
+
+	received, ok := l.receive(event("ETH", "BTC", 4))
+	if !ok || received.Missed != 2 {
+		t.Fatalf("expected event 4 after 2 missed events, got %v, %v", received, ok)
+	}
+	if len(gaps) != 1 || gaps[0] != (Gap{TokenBid: "BTC", TokenAsk: "ETH", From: 2, To: 3}) {
+		t.Fatalf("expected gap of events 2 and 3, got %v", gaps)
+	}
+
+	// events of gap arriving later are skipped
+	if _, ok := l.receive(event("BTC", "ETH", 3)); ok {
+		t.Fatal("expected event of gap skipped")
+	}
+	if received, ok := l.receive(event("BTC", "USDT", 1)); !ok || received.Missed != 0 {
+		t.Fatalf("expected event of other pair received, got %v, %v", received, ok)
+	}
+}
+
+// openTestInstance returning another Database instance sharing database of openTestDatabase
+func openTestInstance(t *testing.T) *Database {
+	t.Helper()
+
+	db, err := New(context.Background(), os.Getenv(testDatabaseURLEnv))
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	t.Cleanup(func() { _ = db.conn.Close() })
+
+	return db
+}
+
+func TestSubscriptionsShareSeqsOfInstances(t *testing.T) {
+	db := openTestDatabase(t)
+	other := openTestInstance(t)
+	ctx := context.Background()
+	s := db.Subscribe(orderbook.EventFilter{})
+	defer s.Unsubscribe()
+
+	booktest.AddOrders(t, db, booktest.Order("1", 2, 10))
+	if err := other.AddOrder(ctx, booktest.Order("2", 2, 10)); err != nil {
+		t.Fatalf("adding order: %v", err)
+	}
+	if err := db.RemoveOrder(ctx, "1"); err != nil {
+		t.Fatalf("removing order: %v", err)
+	}
+	// failed change emits nothing
+	if err := db.RemoveOrder(ctx, "1"); !errors.Is(err, orderbook.ErrOrderNotFound) {
+		t.Fatalf("expected ErrOrderNotFound, got %v", err)
+	}
+
+	// subscription gets changes of its instance only and sees gap of the other instance change
+	seqs := make([]uint64, 0)
+	for len(seqs) < 3 {
+		select {
+		case event := <-s.Events():
+			seqs = append(seqs, event.Seq)
+		case <-time.After(time.Second):
+			t.Fatalf("expected 3 events, got seqs %v", seqs)
+		}
+	}
+	if fmt.Sprint(seqs) != "[1 2 4]" {
+		t.Fatalf("expected seqs 1, 2, 4, got %v", seqs)
+	}
+}
+
+func TestListenerReceivesEventsOfInstances(t *testing.T) {
+	db := openTestDatabase(t)
+	other := openTestInstance(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	booktest.AddOrders(t, db)
+
+	l, err := NewListener(ctx, os.Getenv(testDatabaseURLEnv))
+	if err != nil {
+		t.Fatalf("creating listener: %v", err)
+	}
+	defer l.Close()
+	events := make(chan orderbook.Event, 16)
+	done := make(chan error)
+	go func() { done <- l.Run(ctx, func(event orderbook.Event) { events <- event }) }()
+
+	// changes made before listener is listening are not received, so orders are added until one is
+	var last orderbook.Event
+	for i := 0; last.Seq == 0; i++ {
+		if i == 50 {
+			t.Fatal("expected listener to receive events")
+		}
+		if err := db.AddOrder(ctx, booktest.Order(fmt.Sprint("warmup", i), 2, 10)); err != nil {
+			t.Fatalf("adding order: %v", err)
+		}
+		select {
+		case last = <-events:
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	if err := other.AddOrder(ctx, booktest.Order("1", 2, 10)); err != nil {
+		t.Fatalf("adding order: %v", err)
+	}
+	if err := db.RemoveOrder(ctx, "1"); err != nil {
+		t.Fatalf("removing order: %v", err)
+	}
+	for _, eventType := range []orderbook.EventType{orderbook.EventOrderAdded, orderbook.EventOrderRemoved} {
+		select {
+		case event := <-events:
+			if event.Type != eventType || event.Seq != last.Seq+1 || event.Missed != 0 {
+				t.Fatalf("expected event %s with seq %d, got %v", eventType, last.Seq+1, event)
+			}
+			last = event
+		case <-time.After(time.Second):
+			t.Fatalf("expected event %s", eventType)
+		}
+	}
+
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
@@ -0,0 +1,370 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+// ErrUnknownSchemaVersion is returned when schema version of database is not known by this package,
+// database is migrated by newer version of package and mustn't be used by this one
+var ErrUnknownSchemaVersion = errors.New("unknown schema version")
+
+// migrationsLockKey is a key of advisory lock taken while migrating, so concurrent starters migrate one by one
+const migrationsLockKey int64 = 0x6f72646572626f6f
+
+// migration is a versioned schema change with statements applying and reverting it
+type migration struct {
+	version int
+	name    string
+	up      string
+	down    string
+}
+
+// migrations are schema changes in order of versions, version of migration is its position starting from 1.
+// Applied migrations mustn't be changed, schema is changed by adding migrations.
+// Steps of the first migrations don't fail on existing objects, so databases created before migrations are adopted:
+// missing columns are added to their tables and pairs are registered by orders and pair tables.
+var migrations = []migration{
+	{
+		version: 1,
+		name:    "create_pairs_and_orders",
+		up: `
+CREATE TABLE IF NOT EXISTS orderbook_pairs (
+    token_bid VARCHAR(255) NOT NULL,
+    token_ask VARCHAR(255) NOT NULL,
+    tick_size DECIMAL NOT NULL DEFAULT 0,
+    lot_size DECIMAL NOT NULL DEFAULT 0,
+    min_notional DECIMAL NOT NULL DEFAULT 0,
+    status VARCHAR(32) NOT NULL DEFAULT 'active',
+    PRIMARY KEY (token_bid, token_ask)
+);
+
+-- pair of reversed tokens is the same pair, so tokens are unique in any order
+CREATE UNIQUE INDEX IF NOT EXISTS orderbook_pairs_tokens ON orderbook_pairs ((LEAST(token_bid, token_ask)), (GREATEST(token_bid, token_ask)));
+
+CREATE TABLE IF NOT EXISTS orderbook_orders (
+    id BYTEA PRIMARY KEY NOT NULL,
+    maker_id BYTEA NOT NULL,
+    token_bid VARCHAR(255) NOT NULL,
+    token_ask VARCHAR(255) NOT NULL
+);
+
+-- lifecycle columns are added to orders table created before migrations too,
+-- its orders are live and get priorities in table order
+ALTER TABLE orderbook_orders
+    ADD COLUMN IF NOT EXISTS priority BIGSERIAL NOT NULL,
+    ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS status VARCHAR(32) NOT NULL DEFAULT 'open';
+
+CREATE INDEX IF NOT EXISTS orderbook_orders_maker_id ON orderbook_orders USING hash (maker_id);
+
+CREATE INDEX IF NOT EXISTS orderbook_orders_expires_at ON orderbook_orders USING btree (expires_at);
+
+-- pairs created before migrations are known only by pair tables, so pairs of their orders are registered
+INSERT INTO orderbook_pairs (token_bid, token_ask)
+SELECT DISTINCT LEAST(token_bid, token_ask), GREATEST(token_bid, token_ask)
+FROM orderbook_orders
+ON CONFLICT DO NOTHING;
+`,
+		// pair tables are created with pairs, so they are dropped by names of both pair directions
+		down: `
+DO $$
+DECLARE
+    p RECORD;
+    suffix TEXT;
+BEGIN
+    FOR p IN SELECT token_bid, token_ask FROM orderbook_pairs LOOP
+        FOREACH suffix IN ARRAY ARRAY['rate', 'max_volume', 'min_volume'] LOOP
+            EXECUTE format('DROP TABLE IF EXISTS %I', p.token_bid || '_' || p.token_ask || '_' || suffix);
+            EXECUTE format('DROP TABLE IF EXISTS %I', p.token_ask || '_' || p.token_bid || '_' || suffix);
+        END LOOP;
+    END LOOP;
+END
+$$;
+
+DROP TABLE IF EXISTS orderbook_orders;
+DROP TABLE IF EXISTS orderbook_pairs;
+`,
+	},
+	{
+		version: 2,
+		name:    "create_order_history",
+		up: `
+CREATE TABLE IF NOT EXISTS orderbook_order_history (
+    id BIGSERIAL PRIMARY KEY,
+    order_id BYTEA NOT NULL REFERENCES orderbook_orders (id) ON DELETE CASCADE,
+    status VARCHAR(32) NOT NULL,
+    rate DECIMAL NOT NULL,
+    max_volume DECIMAL NOT NULL,
+    min_volume DECIMAL NOT NULL,
+    time TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS orderbook_order_history_order_id ON orderbook_order_history USING btree (order_id, id);
+`,
+		down: `
+DROP TABLE IF EXISTS orderbook_order_history;
+`,
+	},
+	{
+		version: 3,
+		name:    "create_trades",
+		up: `
+CREATE TABLE IF NOT EXISTS orderbook_trades (
+    id BIGSERIAL PRIMARY KEY,
+    maker_order_id BYTEA NOT NULL REFERENCES orderbook_orders (id),
+    maker_id BYTEA NOT NULL,
+    taker_order_id BYTEA NOT NULL DEFAULT '',
+    taker_id BYTEA NOT NULL DEFAULT '',
+    token_bid VARCHAR(255) NOT NULL,
+    token_ask VARCHAR(255) NOT NULL,
+    rate DECIMAL NOT NULL,
+    volume DECIMAL NOT NULL,
+    time TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS orderbook_trades_pair ON orderbook_trades USING btree (token_bid, token_ask, id);
+CREATE INDEX IF NOT EXISTS orderbook_trades_maker_id ON orderbook_trades USING btree (maker_id, id);
+`,
+		down: `
+DROP TABLE IF EXISTS orderbook_trades;
+`,
+	},
+	{
+		version: 4,
+		name:    "create_event_seqs_and_outbox",
+		up: `
+CREATE TABLE IF NOT EXISTS orderbook_event_seqs (
+    token_a VARCHAR(255) NOT NULL,
+    token_b VARCHAR(255) NOT NULL,
+    seq BIGINT NOT NULL,
+    PRIMARY KEY (token_a, token_b)
+);
+
+CREATE TABLE IF NOT EXISTS orderbook_outbox (
+    id BIGSERIAL PRIMARY KEY,
+    event JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    claimed_until TIMESTAMPTZ,
+    delivered_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS orderbook_outbox_pending ON orderbook_outbox USING btree (id) WHERE delivered_at IS NULL;
+`,
+		down: `
+DROP TABLE IF EXISTS orderbook_outbox;
+DROP TABLE IF EXISTS orderbook_event_seqs;
+`,
+	},
+	{
+		version: 5,
+		name:    "register_pairs_of_pair_tables",
+		// pairs without orders created before migrations are known only by pair tables, so they are registered
+		// by rate tables of both directions. Table name is split at every underscore, tokens are a split
+		// for which rate table of reversed tokens exists, so tokens with underscores are found too.
+		up: `
+INSERT INTO orderbook_pairs (token_bid, token_ask)
+SELECT DISTINCT LEAST(tokens.token_bid, tokens.token_ask), GREATEST(tokens.token_bid, tokens.token_ask)
+FROM (
+    SELECT left(rates.name, split.position - 1) AS token_bid, substr(rates.name, split.position + 1) AS token_ask
+    FROM (
+        SELECT left(tablename, -length('_rate')) AS name
+        FROM pg_tables
+        WHERE schemaname = current_schema() AND tablename NOT LIKE 'orderbook\_%' AND tablename LIKE '%\_rate'
+    ) AS rates
+        CROSS JOIN LATERAL generate_series(2, length(rates.name) - 1) AS split (position)
+    WHERE substr(rates.name, split.position, 1) = '_'
+) AS tokens
+WHERE EXISTS (
+    SELECT 1
+    FROM pg_tables
+    WHERE schemaname = current_schema() AND tablename = tokens.token_ask || '_' || tokens.token_bid || '_rate'
+)
+ON CONFLICT DO NOTHING;
+`,
+		// registered pairs are kept, as pairs registered by orders of the first migration are kept too
+		down: `
+SELECT 1;
+`,
+	},
+	{
+		version: 6,
+		name:    "move_pair_tables_to_orders",
+		// rate and volumes of live orders are copied from pair tables, closed orders are removed from pair tables,
+		// so they get rate and volumes of their last status change. Live orders found in neither are cancelled.
+		up: `
+ALTER TABLE orderbook_orders
+    ADD COLUMN rate DECIMAL,
+    ADD COLUMN max_volume DECIMAL,
+    ADD COLUMN min_volume DECIMAL;
+
+UPDATE orderbook_orders SET rate = history.rate, max_volume = history.max_volume, min_volume = history.min_volume
+FROM (
+    SELECT DISTINCT ON (order_id) order_id, rate, max_volume, min_volume
+    FROM orderbook_order_history
+    ORDER BY order_id, id DESC
+) AS history
+WHERE history.order_id = orderbook_orders.id;
+
+DO $$
+DECLARE
+    p RECORD;
+    direction TEXT[];
+    suffix TEXT;
+    pair_table TEXT;
+BEGIN
+    FOR p IN SELECT token_bid, token_ask FROM orderbook_pairs LOOP
+        FOREACH direction SLICE 1 IN ARRAY ARRAY[[p.token_bid, p.token_ask], [p.token_ask, p.token_bid]]::TEXT[] LOOP
+            FOREACH suffix IN ARRAY ARRAY['rate', 'max_volume', 'min_volume'] LOOP
+                pair_table := direction[1] || '_' || direction[2] || '_' || suffix;
+                IF to_regclass(format('%I', pair_table)) IS NOT NULL THEN
+                    EXECUTE format('UPDATE orderbook_orders SET %2$I = t.%2$I FROM %1$I AS t WHERE t.id = orderbook_orders.id AND t.%2$I IS NOT NULL', pair_table, suffix);
+                    EXECUTE format('DROP TABLE %I', pair_table);
+                END IF;
+            END LOOP;
+        END LOOP;
+    END LOOP;
+END
+$$;
+
+-- live orders without rate or max volume in pair tables and history can't be matched, so they are cancelled
+INSERT INTO orderbook_order_history (order_id, status, rate, max_volume, min_volume, time)
+SELECT id, 'cancelled', COALESCE(rate, 0), COALESCE(max_volume, 0), COALESCE(min_volume, 0), now()
+FROM orderbook_orders
+WHERE status IN ('open', 'partially_filled') AND (rate IS NULL OR max_volume IS NULL)
+ORDER BY priority;
+
+UPDATE orderbook_orders SET status = 'cancelled'
+WHERE status IN ('open', 'partially_filled') AND (rate IS NULL OR max_volume IS NULL);
+
+-- values of closed orders are kept in history only and min volume of pair tables may be null, which is no minimum
+UPDATE orderbook_orders SET rate = COALESCE(rate, 0), max_volume = COALESCE(max_volume, 0), min_volume = COALESCE(min_volume, 0)
+WHERE rate IS NULL OR max_volume IS NULL OR min_volume IS NULL;
+
+ALTER TABLE orderbook_orders
+    ALTER COLUMN rate SET NOT NULL,
+    ALTER COLUMN max_volume SET NOT NULL,
+    ALTER COLUMN min_volume SET NOT NULL;
+
+CREATE INDEX orderbook_orders_pair_rate ON orderbook_orders USING btree (token_bid, token_ask, rate DESC, priority)
+    WHERE status IN ('open', 'partially_filled');
+CREATE INDEX orderbook_orders_pair_max_volume ON orderbook_orders USING btree (token_bid, token_ask, max_volume, id)
+    WHERE status IN ('open', 'partially_filled');
+CREATE INDEX orderbook_orders_pair_min_volume ON orderbook_orders USING btree (token_bid, token_ask, min_volume, id)
+    WHERE status IN ('open', 'partially_filled');
+
+-- closed orders stay in orders table, so index of expiry time is limited to live orders
+DROP INDEX orderbook_orders_expires_at;
+CREATE INDEX orderbook_orders_expires_at ON orderbook_orders USING btree (expires_at)
+    WHERE status IN ('open', 'partially_filled') AND expires_at IS NOT NULL;
+`,
+		// pair tables are filled with live orders, as closed orders are removed from them
+		down: `
+DROP INDEX orderbook_orders_pair_rate;
+DROP INDEX orderbook_orders_pair_max_volume;
+DROP INDEX orderbook_orders_pair_min_volume;
+
+DROP INDEX orderbook_orders_expires_at;
+CREATE INDEX orderbook_orders_expires_at ON orderbook_orders USING btree (expires_at);
+
+DO $$
+DECLARE
+    p RECORD;
+    direction TEXT[];
+    suffix TEXT;
+    pair_table TEXT;
+BEGIN
+    FOR p IN SELECT token_bid, token_ask FROM orderbook_pairs LOOP
+        FOREACH direction SLICE 1 IN ARRAY ARRAY[[p.token_bid, p.token_ask], [p.token_ask, p.token_bid]]::TEXT[] LOOP
+            FOREACH suffix IN ARRAY ARRAY['rate', 'max_volume', 'min_volume'] LOOP
+                pair_table := direction[1] || '_' || direction[2] || '_' || suffix;
+                EXECUTE format('CREATE TABLE %1$I (id BYTEA PRIMARY KEY NOT NULL, %2$I DECIMAL NOT NULL, FOREIGN KEY (id) REFERENCES orderbook_orders (id) ON DELETE CASCADE)', pair_table, suffix);
+                EXECUTE format('CREATE INDEX %I ON %I USING btree (%I)', 'orderbook_orders_tree_' || pair_table, pair_table, suffix);
+                EXECUTE format('INSERT INTO %1$I SELECT id, %2$I FROM orderbook_orders WHERE token_bid = $1 AND token_ask = $2 AND status IN (''open'', ''partially_filled'')', pair_table, suffix)
+                    USING direction[1], direction[2];
+            END LOOP;
+        END LOOP;
+    END LOOP;
+END
+$$;
+
+ALTER TABLE orderbook_orders
+    DROP COLUMN rate,
+    DROP COLUMN max_volume,
+    DROP COLUMN min_volume;
+`,
+	},
+}
+
+// LatestSchemaVersion returning version of the last migration, New migrates database to it
+func LatestSchemaVersion() int {
+	return len(migrations)
+}
+
+// SchemaVersion getting version of the last migration applied to database, zero if there are no migrations
+func (db *Database) SchemaVersion(ctx context.Context) (int, error) {
+	return schemaVersion(ctx, db.conn)
+}
+
+// Migrate applying up steps of pending migrations or down steps of applied ones until database has version.
+// Migrations run in a single transaction under advisory lock, so failed migration leaves schema unchanged
+// and concurrent calls wait for each other. Returns ErrUnknownSchemaVersion if version or version of database
+// is greater than LatestSchemaVersion.
+func (db *Database) Migrate(ctx context.Context, version int) error {
+	if version < 0 || version > LatestSchemaVersion() {
+		return errors.Wrapf(ErrUnknownSchemaVersion, "version %d", version)
+	}
+
+	return db.withTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, lockMigrationsQuery, migrationsLockKey); err != nil {
+			return errors.Wrap(err, "locking migrations")
+		}
+		if _, err := tx.ExecContext(ctx, newMigrationsTableQuery); err != nil {
+			return errors.Wrap(err, "creating migrations table")
+		}
+
+		current, err := schemaVersion(ctx, tx)
+		if err != nil {
+			return err
+		}
+		if current > LatestSchemaVersion() {
+			return errors.Wrapf(ErrUnknownSchemaVersion, "database version %d is newer than %d", current, LatestSchemaVersion())
+		}
+
+		for ; current < version; current++ {
+			m := migrations[current]
+			if _, err := tx.ExecContext(ctx, m.up); err != nil {
+				return errors.Wrapf(err, "applying migration %d_%s", m.version, m.name)
+			}
+			if _, err := tx.ExecContext(ctx, addMigrationQuery, m.version, m.name, db.options.Clock.Now()); err != nil {
+				return errors.Wrapf(err, "recording migration %d_%s", m.version, m.name)
+			}
+		}
+		for ; current > version; current-- {
+			m := migrations[current-1]
+			if _, err := tx.ExecContext(ctx, m.down); err != nil {
+				return errors.Wrapf(err, "reverting migration %d_%s", m.version, m.name)
+			}
+			if _, err := tx.ExecContext(ctx, removeMigrationQuery, m.version); err != nil {
+				return errors.Wrapf(err, "removing migration %d_%s", m.version, m.name)
+			}
+		}
+
+		return nil
+	})
+}
+
+// schemaVersion getting version of the last applied migration, database without migrations table has zero version
+func schemaVersion(ctx context.Context, q queryer) (int, error) {
+	var version int
+	if err := q.QueryRowContext(ctx, getSchemaVersionQuery).Scan(&version); err != nil {
+		if isUndefinedTable(err) {
+			return 0, nil
+		}
+		return 0, errors.Wrap(err, "getting schema version")
+	}
+
+	return version, nil
+}
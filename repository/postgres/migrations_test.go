@@ -0,0 +1,227 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/SashaBokov/orderbook"
+	"github.com/SashaBokov/orderbook/internal/booktest"
+	"github.com/shopspring/decimal"
+)
+
+// baselineSchemaQuery creates orders table of databases created before migrations with pair tables of BTC_ETH.
+// Order 1 is in pair tables, order 2 is in orders table only and order 3 has no min volume.
+// Pair BTC_USD_C with underscore in token has pair tables only.
+var baselineSchemaQuery = `
+CREATE TABLE orderbook_orders (
+    id BYTEA PRIMARY KEY NOT NULL,
+    maker_id BYTEA NOT NULL,
+    token_bid VARCHAR(255) NOT NULL,
+    token_ask VARCHAR(255) NOT NULL
+);
+CREATE INDEX orderbook_orders_maker_id ON orderbook_orders USING hash (maker_id);
+
+CREATE TABLE "BTC_ETH_rate" (id BYTEA PRIMARY KEY NOT NULL REFERENCES orderbook_orders (id) ON DELETE CASCADE, rate DECIMAL NOT NULL);
+CREATE TABLE "BTC_ETH_max_volume" (id BYTEA PRIMARY KEY NOT NULL REFERENCES orderbook_orders (id) ON DELETE CASCADE, max_volume DECIMAL NOT NULL);
+CREATE TABLE "BTC_ETH_min_volume" (id BYTEA PRIMARY KEY NOT NULL REFERENCES orderbook_orders (id) ON DELETE CASCADE, min_volume DECIMAL);
+CREATE TABLE "ETH_BTC_rate" (id BYTEA PRIMARY KEY NOT NULL REFERENCES orderbook_orders (id) ON DELETE CASCADE, rate DECIMAL NOT NULL);
+CREATE TABLE "ETH_BTC_max_volume" (id BYTEA PRIMARY KEY NOT NULL REFERENCES orderbook_orders (id) ON DELETE CASCADE, max_volume DECIMAL NOT NULL);
+CREATE TABLE "ETH_BTC_min_volume" (id BYTEA PRIMARY KEY NOT NULL REFERENCES orderbook_orders (id) ON DELETE CASCADE, min_volume DECIMAL);
+
+CREATE TABLE "BTC_USD_C_rate" (id BYTEA PRIMARY KEY NOT NULL REFERENCES orderbook_orders (id) ON DELETE CASCADE, rate DECIMAL NOT NULL);
+CREATE TABLE "BTC_USD_C_max_volume" (id BYTEA PRIMARY KEY NOT NULL REFERENCES orderbook_orders (id) ON DELETE CASCADE, max_volume DECIMAL NOT NULL);
+CREATE TABLE "BTC_USD_C_min_volume" (id BYTEA PRIMARY KEY NOT NULL REFERENCES orderbook_orders (id) ON DELETE CASCADE, min_volume DECIMAL);
+CREATE TABLE "USD_C_BTC_rate" (id BYTEA PRIMARY KEY NOT NULL REFERENCES orderbook_orders (id) ON DELETE CASCADE, rate DECIMAL NOT NULL);
+CREATE TABLE "USD_C_BTC_max_volume" (id BYTEA PRIMARY KEY NOT NULL REFERENCES orderbook_orders (id) ON DELETE CASCADE, max_volume DECIMAL NOT NULL);
+CREATE TABLE "USD_C_BTC_min_volume" (id BYTEA PRIMARY KEY NOT NULL REFERENCES orderbook_orders (id) ON DELETE CASCADE, min_volume DECIMAL);
+
+INSERT INTO orderbook_orders VALUES ('1', 'maker', 'BTC', 'ETH'), ('2', 'maker', 'BTC', 'ETH'), ('3', 'maker', 'ETH', 'BTC');
+INSERT INTO "BTC_ETH_rate" VALUES ('1', 2);
+INSERT INTO "BTC_ETH_max_volume" VALUES ('1', 10);
+INSERT INTO "BTC_ETH_min_volume" VALUES ('1', 1);
+INSERT INTO "ETH_BTC_rate" VALUES ('3', 0.5);
+INSERT INTO "ETH_BTC_max_volume" VALUES ('3', 4);
+INSERT INTO "ETH_BTC_min_volume" VALUES ('3', NULL);
+`
+
+var dropBaselineSchemaQuery = `
+DROP TABLE IF EXISTS "BTC_ETH_rate", "BTC_ETH_max_volume", "BTC_ETH_min_volume", "ETH_BTC_rate", "ETH_BTC_max_volume", "ETH_BTC_min_volume",
+    "BTC_USD_C_rate", "BTC_USD_C_max_volume", "BTC_USD_C_min_volume", "USD_C_BTC_rate", "USD_C_BTC_max_volume", "USD_C_BTC_min_volume";
+`
+
+var tableExistsQuery = `SELECT to_regclass($1) IS NOT NULL;`
+
+func tableExists(t *testing.T, db *Database, table string) bool {
+	t.Helper()
+
+	var exists bool
+	if err := db.conn.QueryRowContext(context.Background(), tableExistsQuery, table).Scan(&exists); err != nil {
+		t.Fatalf("checking table %s: %v", table, err)
+	}
+
+	return exists
+}
+
+// restoreSchema migrating database back to the latest version after test which changes schema
+func restoreSchema(t *testing.T, db *Database) {
+	t.Cleanup(func() {
+		ctx := context.Background()
+		_, _ = db.conn.ExecContext(ctx, dropBaselineSchemaQuery)
+		if err := db.Migrate(ctx, 0); err != nil {
+			t.Errorf("reverting migrations: %v", err)
+		}
+		if err := db.Migrate(ctx, LatestSchemaVersion()); err != nil {
+			t.Errorf("applying migrations: %v", err)
+		}
+	})
+}
+
+func TestMigrationsAreVersioned(t *testing.T) {
+	names := make(map[string]bool)
+	for i, m := range migrations {
+		if m.version != i+1 {
+			t.Errorf("expected version %d of migration %s, got %d", i+1, m.name, m.version)
+		}
+		if names[m.name] {
+			t.Errorf("expected unique name of migration %d, got %s", m.version, m.name)
+		}
+		names[m.name] = true
+		if strings.TrimSpace(m.up) == "" || strings.TrimSpace(m.down) == "" {
+			t.Errorf("expected up and down steps of migration %d_%s", m.version, m.name)
+		}
+	}
+	if LatestSchemaVersion() != len(migrations) {
+		t.Fatalf("expected latest version %d, got %d", len(migrations), LatestSchemaVersion())
+	}
+}
+
+func TestMigrateDownAndUp(t *testing.T) {
+	db := openTestDatabase(t)
+	ctx := context.Background()
+	restoreSchema(t, db)
+	booktest.AddOrders(t, db, booktest.Order("1", 2, 10))
+
+	if version, err := db.SchemaVersion(ctx); err != nil || version != LatestSchemaVersion() {
+		t.Fatalf("expected latest version after New, got %d, %v", version, err)
+	}
+
+	// reverting move to orders table fills pair tables with live orders
+	if err := db.Migrate(ctx, 5); err != nil {
+		t.Fatalf("reverting migration: %v", err)
+	}
+	if version, err := db.SchemaVersion(ctx); err != nil || version != 5 {
+		t.Fatalf("expected version 5, got %d, %v", version, err)
+	}
+	if !tableExists(t, db, `"BTC_ETH_rate"`) || !tableExists(t, db, `"ETH_BTC_rate"`) {
+		t.Fatal("expected pair tables of both directions")
+	}
+
+	if err := db.Migrate(ctx, LatestSchemaVersion()); err != nil {
+		t.Fatalf("applying migration: %v", err)
+	}
+	if tableExists(t, db, `"BTC_ETH_rate"`) {
+		t.Fatal("expected pair tables dropped")
+	}
+	if order, err := db.GetOrderById(ctx, "1"); err != nil || !order.Rate.Equal(decimal.NewFromInt(2)) {
+		t.Fatalf("expected order 1 with rate 2 kept, got %v, %v", order, err)
+	}
+
+	if err := db.Migrate(ctx, 0); err != nil {
+		t.Fatalf("reverting migrations: %v", err)
+	}
+	if version, err := db.SchemaVersion(ctx); err != nil || version != 0 {
+		t.Fatalf("expected version 0, got %d, %v", version, err)
+	}
+	if tableExists(t, db, "orderbook_orders") || tableExists(t, db, "orderbook_pairs") || tableExists(t, db, `"BTC_ETH_rate"`) {
+		t.Fatal("expected tables dropped")
+	}
+
+	if err := db.Migrate(ctx, LatestSchemaVersion()); err != nil {
+		t.Fatalf("applying migrations: %v", err)
+	}
+	booktest.AddOrders(t, db, booktest.Order("1", 2, 10))
+	if order, err := db.GetOrderById(ctx, "1"); err != nil || !order.Rate.Equal(decimal.NewFromInt(2)) {
+		t.Fatalf("expected order 1 with rate 2 in migrated database, got %v, %v", order, err)
+	}
+}
+
+func TestMigrateUnknownVersion(t *testing.T) {
+	db := openTestDatabase(t)
+	ctx := context.Background()
+
+	for _, version := range []int{-1, LatestSchemaVersion() + 1} {
+		if err := db.Migrate(ctx, version); !errors.Is(err, ErrUnknownSchemaVersion) {
+			t.Errorf("expected ErrUnknownSchemaVersion of version %d, got %v", version, err)
+		}
+	}
+
+	// database migrated by newer package isn't used
+	if _, err := db.conn.ExecContext(ctx, addMigrationQuery, LatestSchemaVersion()+1, "newer", db.options.Clock.Now()); err != nil {
+		t.Fatalf("recording migration: %v", err)
+	}
+	t.Cleanup(func() {
+		_, _ = db.conn.ExecContext(context.Background(), removeMigrationQuery, LatestSchemaVersion()+1)
+	})
+
+	if _, err := New(ctx, os.Getenv(testDatabaseURLEnv)); !errors.Is(err, ErrUnknownSchemaVersion) {
+		t.Fatalf("expected ErrUnknownSchemaVersion, got %v", err)
+	}
+}
+
+func TestMigrateAdoptsBaselineSchema(t *testing.T) {
+	db := openTestDatabase(t)
+	ctx := context.Background()
+	restoreSchema(t, db)
+
+	if err := db.Migrate(ctx, 0); err != nil {
+		t.Fatalf("reverting migrations: %v", err)
+	}
+	if _, err := db.conn.ExecContext(ctx, baselineSchemaQuery); err != nil {
+		t.Fatalf("creating baseline schema: %v", err)
+	}
+	if err := db.Migrate(ctx, LatestSchemaVersion()); err != nil {
+		t.Fatalf("applying migrations: %v", err)
+	}
+
+	// pair is registered by orders, lifecycle columns are added and pair tables are moved to orders table
+	if _, err := db.GetPair(ctx, "BTC", "ETH"); err != nil {
+		t.Fatalf("expected pair registered, got %v", err)
+	}
+	// pair without orders is registered by its pair tables
+	if pair, err := db.GetPair(ctx, "USD_C", "BTC"); err != nil || pair.TokenBid != "BTC" || pair.TokenAsk != "USD_C" {
+		t.Fatalf("expected pair BTC_USD_C registered, got %v, %v", pair, err)
+	}
+	if pairs, err := db.ListPairs(ctx); err != nil || len(pairs) != 2 {
+		t.Fatalf("expected 2 pairs, got %v, %v", pairs, err)
+	}
+	if tableExists(t, db, `"BTC_ETH_rate"`) || tableExists(t, db, `"ETH_BTC_min_volume"`) || tableExists(t, db, `"USD_C_BTC_rate"`) {
+		t.Fatal("expected pair tables dropped")
+	}
+
+	order, err := db.GetOrderById(ctx, "1")
+	if err != nil || !order.Rate.Equal(decimal.NewFromInt(2)) || !order.MaxVolume.Equal(decimal.NewFromInt(10)) || order.Status != orderbook.OrderOpen {
+		t.Fatalf("expected open order 1 with rate 2 and volume 10, got %v, %v", order, err)
+	}
+	if best, err := db.GetOrderWithMaxRate(ctx, "ETH", "BTC"); err != nil || best.Id != "3" || best.Priority == order.Priority {
+		t.Fatalf("expected order 3 with own priority, got %v, %v", best, err)
+	}
+	if order, err := db.GetOrderById(ctx, "3"); err != nil || !order.MinVolume.IsZero() {
+		t.Fatalf("expected order 3 without min volume, got %v, %v", order, err)
+	}
+
+	// order without rate and volume can't be matched, so it is cancelled with history
+	if _, err := db.GetOrderById(ctx, "2"); !errors.Is(err, orderbook.ErrOrderNotFound) {
+		t.Fatalf("expected order 2 cancelled, got %v", err)
+	}
+	history, err := db.GetOrderHistory(ctx, "2")
+	if err != nil || len(history) == 0 || history[len(history)-1].Status != orderbook.OrderCancelled {
+		t.Fatalf("expected cancelled status of order 2, got %v, %v", history, err)
+	}
+
+	if err := db.AddOrder(ctx, booktest.Order("4", 2, 10)); err != nil {
+		t.Fatalf("adding order to adopted schema: %v", err)
+	}
+}
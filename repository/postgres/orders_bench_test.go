@@ -0,0 +1,149 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/SashaBokov/orderbook/internal/booktest"
+	"github.com/lib/pq"
+)
+
+// benchOrders is a number of orders of each pair direction in benchmarks
+const benchOrders = 10000
+
+// addBenchOrdersQuery inserts $1 live orders of pair direction $2_$3 with rates and volumes spread over them
+var addBenchOrdersQuery = `
+INSERT INTO orderbook_orders (id, maker_id, token_bid, token_ask, rate, max_volume, min_volume)
+SELECT convert_to($2::text || $3::text || n, 'UTF8'), convert_to('maker' || n % 100, 'UTF8'), $2, $3, 1 + n % 997, 1 + n % 991, 1
+FROM generate_series(1, $1) AS n;
+`
+
+// perPairTables creating tables of rate and volumes of pair direction like the layout before migration 6,
+// they are filled with live orders of orders table and dropped after benchmark
+func perPairTables(b *testing.B, db *Database, tokenBid, tokenAsk string) map[string]string {
+	b.Helper()
+
+	ctx := context.Background()
+	tables := make(map[string]string)
+	for _, suffix := range []string{"rate", "max_volume", "min_volume"} {
+		table := pq.QuoteIdentifier(tokenBid + "_" + tokenAsk + "_" + suffix)
+		query := fmt.Sprintf(`
+DROP TABLE IF EXISTS %[1]s;
+CREATE TABLE %[1]s (id BYTEA PRIMARY KEY NOT NULL, %[2]s DECIMAL NOT NULL, FOREIGN KEY (id) REFERENCES orderbook_orders (id) ON DELETE CASCADE);
+CREATE INDEX ON %[1]s USING btree (%[2]s);
+INSERT INTO %[1]s SELECT id, %[2]s FROM orderbook_orders WHERE token_bid = %[3]s AND token_ask = %[4]s;
+ANALYZE %[1]s;
+`, table, suffix, pq.QuoteLiteral(tokenBid), pq.QuoteLiteral(tokenAsk))
+		if _, err := db.conn.ExecContext(ctx, query); err != nil {
+			b.Fatalf("creating pair table: %v", err)
+		}
+		tables[suffix] = table
+	}
+	b.Cleanup(func() {
+		for _, table := range tables {
+			_, _ = db.conn.ExecContext(context.Background(), "DROP TABLE IF EXISTS "+table)
+		}
+	})
+
+	return tables
+}
+
+// openBenchDatabase returning database with benchOrders orders of both directions of test pair
+// and tables of per-pair layout for direction BTC_ETH
+func openBenchDatabase(b *testing.B) (*Database, map[string]string) {
+	b.Helper()
+
+	db := openTestDatabase(b)
+	booktest.AddOrders(b, db)
+
+	ctx := context.Background()
+	for _, direction := range [][2]string{{"BTC", "ETH"}, {"ETH", "BTC"}} {
+		if _, err := db.conn.ExecContext(ctx, addBenchOrdersQuery, benchOrders, direction[0], direction[1]); err != nil {
+			b.Fatalf("adding orders: %v", err)
+		}
+	}
+	if _, err := db.conn.ExecContext(ctx, "ANALYZE orderbook_orders"); err != nil {
+		b.Fatalf("analyzing orders: %v", err)
+	}
+
+	return db, perPairTables(b, db, "BTC", "ETH")
+}
+
+// benchQuery running query returning single order b.N times
+func benchQuery(b *testing.B, db *Database, query string, args ...interface{}) {
+	b.Helper()
+
+	ctx := context.Background()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rows, err := db.conn.QueryContext(ctx, query, args...)
+		if err != nil {
+			b.Fatalf("querying: %v", err)
+		}
+		orders, err := db.parseSQLRowsToOrders(rows)
+		if err != nil || len(orders) != 1 {
+			b.Fatalf("expected single order, got %v, %v", orders, err)
+		}
+	}
+}
+
+// perPairJoin returning select of orders joined with tables of pair direction, as orders were read before migration 6
+func perPairJoin(tables map[string]string) string {
+	return fmt.Sprintf(`
+SELECT orderbook_orders.id, orderbook_orders.maker_id, orderbook_orders.token_bid, orderbook_orders.token_ask,
+    %[1]s.rate, %[2]s.max_volume, %[3]s.min_volume,
+    orderbook_orders.priority, orderbook_orders.expires_at, orderbook_orders.status
+FROM %[1]s
+    JOIN %[3]s ON %[3]s.id = %[1]s.id
+    JOIN %[2]s ON %[2]s.id = %[1]s.id
+    JOIN orderbook_orders ON orderbook_orders.id = %[1]s.id
+`, tables["rate"], tables["max_volume"], tables["min_volume"])
+}
+
+func BenchmarkGetOrderWithMaxRate(b *testing.B) {
+	db, tables := openBenchDatabase(b)
+	now := db.options.Clock.Now()
+
+	b.Run("orders_table", func(b *testing.B) {
+		benchQuery(b, db, getOrderWithMaxRateQuery, "BTC", "ETH", now)
+	})
+	b.Run("pair_tables", func(b *testing.B) {
+		query := perPairJoin(tables) + fmt.Sprintf(`
+WHERE orderbook_orders.expires_at IS NULL OR orderbook_orders.expires_at > $1
+ORDER BY %s.rate DESC, orderbook_orders.priority
+LIMIT 1;`, tables["rate"])
+		benchQuery(b, db, query, now)
+	})
+}
+
+func BenchmarkGetOrderWithMaxVolume(b *testing.B) {
+	db, tables := openBenchDatabase(b)
+	now := db.options.Clock.Now()
+
+	b.Run("orders_table", func(b *testing.B) {
+		benchQuery(b, db, getOrderWithMaxVolumeQuery, "BTC", "ETH", now)
+	})
+	b.Run("pair_tables", func(b *testing.B) {
+		query := perPairJoin(tables) + fmt.Sprintf(`
+WHERE orderbook_orders.expires_at IS NULL OR orderbook_orders.expires_at > $1
+ORDER BY %s.max_volume DESC, orderbook_orders.id DESC
+LIMIT 1;`, tables["max_volume"])
+		benchQuery(b, db, query, now)
+	})
+}
+
+func BenchmarkGetOrderById(b *testing.B) {
+	db, tables := openBenchDatabase(b)
+	now := db.options.Clock.Now()
+	orderId := "BTCETH5000"
+
+	b.Run("orders_table", func(b *testing.B) {
+		benchQuery(b, db, getOrderByIdQuery, orderId, now)
+	})
+	b.Run("pair_tables", func(b *testing.B) {
+		query := perPairJoin(tables) + `
+WHERE orderbook_orders.id = $1 AND (orderbook_orders.expires_at IS NULL OR orderbook_orders.expires_at > $2);`
+		benchQuery(b, db, query, orderId, now)
+	})
+}
@@ -0,0 +1,209 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/SashaBokov/orderbook"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+// DefaultRelayBatch is a number of outbox events read by relay at once when batch is not positive
+const DefaultRelayBatch = 100
+
+// DefaultRelayLease is a time for which relay claims batch of outbox events before they may be claimed by other relays
+const DefaultRelayLease = time.Minute
+
+// outboxClaimsLockKey is a key of advisory lock taken while claiming outbox events, so claims see each other
+const outboxClaimsLockKey int64 = 0x6f7574626f78
+
+// Publisher publishes outbox events downstream.
+// Events may be published again after failures, pair tokens and Event.Seq identify event for deduplication.
+type Publisher interface {
+	Publish(ctx context.Context, event orderbook.Event) error
+}
+
+// Relay publishes events of Database outbox written in the same transactions as mutations.
+// Events of pair are published in order of sequences, event is marked delivered only after it is published.
+// Relays claim batches of events for a lease, so several relays publish different events without holding locks.
+type Relay struct {
+	db        *Database
+	publisher Publisher
+	interval  time.Duration
+	batch     int
+	// Lease is a time for which events are claimed, events of relay which stopped are published by others after it.
+	// Events published longer than Lease may be published again by other relays.
+	Lease time.Duration
+	// OnError is called when publishing fails, relay keeps running after errors
+	OnError func(err error)
+	// OnPublish is called after every run with number of published events
+	OnPublish func(published int)
+}
+
+func NewRelay(db *Database, publisher Publisher, interval time.Duration, batch int) *Relay {
+	if batch <= 0 {
+		batch = DefaultRelayBatch
+	}
+
+	return &Relay{db: db, publisher: publisher, interval: interval, batch: batch, Lease: DefaultRelayLease}
+}
+
+// Run publishing pending events until outbox is empty, then waiting interval before the next run, returns ctx error
+func (r *Relay) Run(ctx context.Context) error {
+	for {
+		published, err := r.PublishPending(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if r.OnError != nil {
+				r.OnError(err)
+			}
+		}
+		if r.OnPublish != nil {
+			r.OnPublish(published)
+		}
+
+		if err == nil && published == r.batch {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-r.db.options.Clock.After(r.interval):
+		}
+	}
+}
+
+// PublishPending claiming one batch of pending events, publishing them in outbox order after the claim is committed,
+// then marking published events delivered. Returns number of published events.
+// Publishing stops at the first failed event, the rest of batch is released, so it is claimed again in order.
+// Event isn't claimed while earlier event of its pair is under lease of other relay, so pair events are published in order.
+func (r *Relay) PublishPending(ctx context.Context) (int, error) {
+	ids, events, err := r.claim(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	published := make([]int64, 0, len(ids))
+	var publishErr error
+	for i, event := range events {
+		if publishErr = r.publisher.Publish(ctx, event); publishErr != nil {
+			publishErr = errors.Wrapf(publishErr, "publishing outbox event %d", ids[i])
+			break
+		}
+		published = append(published, ids[i])
+	}
+
+	err = r.db.withTx(ctx, func(tx *sql.Tx) error {
+		if len(published) > 0 {
+			if _, err := tx.ExecContext(ctx, markOutboxEventsDeliveredQuery, pq.Array(published), r.db.options.Clock.Now()); err != nil {
+				return errors.Wrap(err, "marking outbox events delivered")
+			}
+		}
+		if len(published) < len(ids) {
+			if _, err := tx.ExecContext(ctx, releaseOutboxEventsQuery, pq.Array(ids[len(published):])); err != nil {
+				return errors.Wrap(err, "releasing outbox events")
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return len(published), publishErr
+}
+
+// claim claiming batch of pending events for lease, returns ids and events in outbox order.
+// Claims are serialized by advisory lock, rows changed by other relays are skipped.
+func (r *Relay) claim(ctx context.Context) ([]int64, []orderbook.Event, error) {
+	lease := r.Lease
+	if lease <= 0 {
+		lease = DefaultRelayLease
+	}
+
+	var (
+		ids    []int64
+		events []orderbook.Event
+	)
+	err := r.db.withTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, lockOutboxClaimsQuery, outboxClaimsLockKey); err != nil {
+			return errors.Wrap(err, "locking outbox claims")
+		}
+
+		now := r.db.options.Clock.Now()
+		rows, err := tx.QueryContext(ctx, claimOutboxEventsQuery, r.batch, now.Add(lease), now)
+		if err != nil {
+			return errors.Wrap(err, "claiming outbox events")
+		}
+
+		ids, events, err = parseSQLRowsToOutboxEvents(rows)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return ids, events, nil
+}
+
+// parseSQLRowsToOutboxEvents parsing sql.Rows to outbox ids and events sorted by ids
+func parseSQLRowsToOutboxEvents(rows *sql.Rows) ([]int64, []orderbook.Event, error) {
+	defer rows.Close()
+
+	ids := make([]int64, 0)
+	events := make([]orderbook.Event, 0)
+	for rows.Next() {
+		var (
+			id      int64
+			payload []byte
+			event   orderbook.Event
+		)
+		if err := rows.Scan(&id, &payload); err != nil {
+			return nil, nil, errors.Wrap(err, "scanning rows")
+		}
+		if err := json.Unmarshal(payload, &event); err != nil {
+			return nil, nil, errors.Wrapf(err, "unmarshalling outbox event %d", id)
+		}
+
+		ids = append(ids, id)
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, errors.Wrap(err, "iterating rows")
+	}
+
+	// rows returned by UPDATE are not ordered
+	sort.Sort(outboxEvents{ids: ids, events: events})
+
+	return ids, events, nil
+}
+
+// outboxEvents sorts outbox events by ids
+type outboxEvents struct {
+	ids    []int64
+	events []orderbook.Event
+}
+
+func (e outboxEvents) Len() int {
+	return len(e.ids)
+}
+
+func (e outboxEvents) Less(i, j int) bool {
+	return e.ids[i] < e.ids[j]
+}
+
+func (e outboxEvents) Swap(i, j int) {
+	e.ids[i], e.ids[j] = e.ids[j], e.ids[i]
+	e.events[i], e.events[j] = e.events[j], e.events[i]
+}
@@ -0,0 +1,140 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/SashaBokov/orderbook"
+	"github.com/SashaBokov/orderbook/internal/booktest"
+)
+
+// testPublisher recording published events, it fails to publish event with seq failSeq of pair BTC_ETH once
+type testPublisher struct {
+	published []orderbook.Event
+	failSeq   uint64
+	// onPublish is called before event is recorded
+	onPublish func(event orderbook.Event)
+}
+
+func (p *testPublisher) Publish(_ context.Context, event orderbook.Event) error {
+	if p.failSeq != 0 && event.Seq == p.failSeq && event.TokenAsk == "ETH" {
+		p.failSeq = 0
+		return errors.New("broker is down")
+	}
+	if p.onPublish != nil {
+		p.onPublish(event)
+	}
+
+	p.published = append(p.published, event)
+	return nil
+}
+
+// seqs returning pairs and seqs of published events
+func (p *testPublisher) seqs() string {
+	seqs := make([]string, 0, len(p.published))
+	for _, event := range p.published {
+		seqs = append(seqs, fmt.Sprintf("%s_%s:%d", event.TokenBid, event.TokenAsk, event.Seq))
+	}
+
+	return fmt.Sprint(seqs)
+}
+
+func TestOutboxEventsSort(t *testing.T) {
+	events := outboxEvents{
+		ids:    []int64{3, 1, 2},
+		events: []orderbook.Event{{Seq: 3}, {Seq: 1}, {Seq: 2}},
+	}
+	sort.Sort(events)
+
+	for i := range events.ids {
+		if events.ids[i] != int64(i+1) || events.events[i].Seq != uint64(i+1) {
+			t.Fatalf("expected events sorted with ids, got %v", events)
+		}
+	}
+}
+
+func TestRelayPublishesInOrder(t *testing.T) {
+	db := openTestDatabase(t)
+	ctx := context.Background()
+	booktest.AddOrders(t, db, booktest.Order("1", 2, 10), booktest.Order("2", 2, 10))
+
+	// the second event fails, so it and the rest of batch are published by the next run
+	publisher := &testPublisher{failSeq: 2}
+	relay := NewRelay(db, publisher, time.Second, 0)
+	if published, err := relay.PublishPending(ctx); err == nil || published != 1 {
+		t.Fatalf("expected publishing error after 1 event, got %d, %v", published, err)
+	}
+	if published, err := relay.PublishPending(ctx); err != nil || published != 2 {
+		t.Fatalf("expected 2 released events published, got %d, %v", published, err)
+	}
+	if published, err := relay.PublishPending(ctx); err != nil || published != 0 {
+		t.Fatalf("expected empty outbox, got %d, %v", published, err)
+	}
+
+	if seqs := publisher.seqs(); seqs != "[BTC_ETH:1 BTC_ETH:2 BTC_ETH:3]" {
+		t.Fatalf("expected events published once in order, got %s", seqs)
+	}
+}
+
+func TestRelayWaitsForEarlierEventsOfPair(t *testing.T) {
+	db := openTestDatabase(t)
+	ctx := context.Background()
+	booktest.AddOrders(t, db, booktest.Order("1", 2, 10))
+	if err := db.AddNewPair(ctx, orderbook.Pair{TokenBid: "BTC", TokenAsk: "USDT"}); err != nil {
+		t.Fatalf("adding pair: %v", err)
+	}
+
+	// while the first relay publishes event 1 of BTC_ETH, the other one skips the next event of the pair
+	other := &testPublisher{}
+	otherRelay := NewRelay(db, other, time.Second, 0)
+	publisher := &testPublisher{}
+	publisher.onPublish = func(event orderbook.Event) {
+		if published, err := otherRelay.PublishPending(ctx); err != nil || published != 1 {
+			t.Errorf("expected other relay to publish 1 event, got %d, %v", published, err)
+		}
+	}
+	if published, err := NewRelay(db, publisher, time.Second, 1).PublishPending(ctx); err != nil || published != 1 {
+		t.Fatalf("expected 1 event published, got %d, %v", published, err)
+	}
+
+	if seqs := other.seqs(); seqs != "[BTC_USDT:1]" {
+		t.Fatalf("expected other relay to publish event of other pair only, got %s", seqs)
+	}
+	publisher.onPublish = nil
+	if published, err := otherRelay.PublishPending(ctx); err != nil || published != 1 {
+		t.Fatalf("expected the rest event published after the earlier one, got %d, %v", published, err)
+	}
+	if seqs := other.seqs(); seqs != "[BTC_USDT:1 BTC_ETH:2]" {
+		t.Fatalf("expected event 2 of BTC_ETH published, got %s", seqs)
+	}
+}
+
+func TestRelayClaimsEventsOfStoppedRelayAfterLease(t *testing.T) {
+	clock := booktest.NewClock()
+	db := openTestDatabase(t, orderbook.WithClock(clock))
+	ctx := context.Background()
+	booktest.AddOrders(t, db, booktest.Order("1", 2, 10))
+
+	// stopped relay claims events and never publishes them
+	if ids, _, err := NewRelay(db, &testPublisher{}, time.Second, 0).claim(ctx); err != nil || len(ids) != 2 {
+		t.Fatalf("expected 2 claimed events, got %v, %v", ids, err)
+	}
+
+	publisher := &testPublisher{}
+	relay := NewRelay(db, publisher, time.Second, 0)
+	if published, err := relay.PublishPending(ctx); err != nil || published != 0 {
+		t.Fatalf("expected claimed events skipped, got %d, %v", published, err)
+	}
+
+	clock.Advance(DefaultRelayLease)
+	if published, err := relay.PublishPending(ctx); err != nil || published != 2 {
+		t.Fatalf("expected events published after lease, got %d, %v", published, err)
+	}
+	if seqs := publisher.seqs(); seqs != "[BTC_ETH:1 BTC_ETH:2]" {
+		t.Fatalf("expected events published in order, got %s", seqs)
+	}
+}
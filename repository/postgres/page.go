@@ -0,0 +1,29 @@
+package postgres
+
+import (
+	"github.com/SashaBokov/orderbook"
+)
+
+// tradePage returning page of trades selected with limit+1, the extra trade means there is the next page
+func tradePage(trades []orderbook.Trade, limit int) orderbook.TradePage {
+	if len(trades) <= limit {
+		return orderbook.TradePage{Trades: trades}
+	}
+
+	trades = trades[:limit]
+	return orderbook.TradePage{Trades: trades, NextCursor: orderbook.Position{Seq: trades[limit-1].Id}.Cursor()}
+}
+
+// decodeCursor returning cursor position and true if cursor is not empty, so list starts after position
+func decodeCursor(cursor orderbook.Cursor) (orderbook.Position, bool, error) {
+	if cursor == "" {
+		return orderbook.Position{}, false, nil
+	}
+
+	position, err := cursor.Position()
+	if err != nil {
+		return orderbook.Position{}, false, err
+	}
+
+	return position, true, nil
+}
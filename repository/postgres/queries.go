@@ -1,261 +1,337 @@
 package postgres
 
-var newOrdersTableQuery = `
-CREATE TABLE IF NOT EXISTS orderbook_orders (
-    id BYTEA PRIMARY KEY NOT NULL,
-    maker_id BYTEA NOT NULL,
-    token_bid VARCHAR(255) NOT NULL,
-    token_ask VARCHAR(255) NOT NULL
+var newMigrationsTableQuery = `
+CREATE TABLE IF NOT EXISTS orderbook_schema_migrations (
+    version INT PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    applied_at TIMESTAMPTZ NOT NULL
 );
+`
 
-CREATE INDEX orderbook_orders_maker_id ON orderbook_orders USING hash (maker_id);
+var lockMigrationsQuery = `
+SELECT pg_advisory_xact_lock($1);
 `
 
-var addPairTablesQuery = `
-CREATE TABLE IF NOT EXISTS $1_$2_min_volume (
-    id BYTEA PRIMARY KEY NOT NULL,
-    min_volume DECIMAL,
-    FOREIGN KEY (id) REFERENCES orderbook_orders (id) ON DELETE CASCADE
-);
-CREATE INDEX orderbook_orders_tree_$1_$2_min_volume ON $1_$2_min_volume using btree (min_volume);
-
-CREATE TABLE IF NOT EXISTS $1_$2_max_volume (
-    id BYTEA PRIMARY KEY NOT NULL,
-    max_volume DECIMAL NOT NULL,
-    FOREIGN KEY (id) REFERENCES orderbook_orders (id) ON DELETE CASCADE
-    );
-CREATE INDEX orderbook_orders_tree_$1_$2_max_volume ON $1_$2_max_volume using btree (max_volume);
-
-CREATE TABLE IF NOT EXISTS $1_$2_rate (
-    id BYTEA PRIMARY KEY NOT NULL,
-    rate DECIMAL NOT NULL,
-    FOREIGN KEY (id) REFERENCES orderbook_orders (id) ON DELETE CASCADE
-);
-CREATE INDEX orderbook_orders_tree_$1_$2_rate ON $1_$2_rate using btree (rate);
+var getSchemaVersionQuery = `
+SELECT COALESCE(MAX(version), 0) FROM orderbook_schema_migrations;
+`
 
+var addMigrationQuery = `
+INSERT INTO orderbook_schema_migrations (version, name, applied_at) VALUES ($1, $2, $3);
+`
 
-CREATE TABLE IF NOT EXISTS $2_$1_min_volume (
-    id BYTEA PRIMARY KEY NOT NULL,
-    min_volume DECIMAL,
-    FOREIGN KEY (id) REFERENCES orderbook_orders (id) ON DELETE CASCADE
-);
-CREATE INDEX orderbook_orders_tree_asks_$2_$1_min_volume ON $2_$1_min_volume using btree (min_volume);
+var removeMigrationQuery = `
+DELETE FROM orderbook_schema_migrations WHERE version = $1;
+`
 
-CREATE TABLE IF NOT EXISTS $2_$1_max_volume (
-    id BYTEA PRIMARY KEY NOT NULL,
-    max_volume DECIMAL NOT NULL,
-    FOREIGN KEY (id) REFERENCES orderbook_orders (id) ON DELETE CASCADE
-);
-CREATE INDEX orderbook_orders_tree_$2_$1_max_volume ON $2_$1_max_volume using btree (max_volume);
+var addPairQuery = `
+INSERT INTO orderbook_pairs (token_bid, token_ask, tick_size, lot_size, min_notional, status) VALUES ($1, $2, $3, $4, $5, $6);
+`
 
-CREATE TABLE IF NOT EXISTS $2_$1_rate (
-    id BYTEA PRIMARY KEY NOT NULL,
-    rate DECIMAL NOT NULL,
-    FOREIGN KEY (id) REFERENCES orderbook_orders (id) ON DELETE CASCADE
-);
-CREATE INDEX orderbook_orders_tree_$2_$1_rate ON $2_$1_rate using btree (rate);
+var getPairQuery = `
+SELECT token_bid, token_ask, tick_size, lot_size, min_notional, status
+FROM orderbook_pairs
+WHERE (token_bid = $1 AND token_ask = $2) OR (token_bid = $2 AND token_ask = $1);
+`
+
+var getPairForShareQuery = `
+SELECT token_bid, token_ask, tick_size, lot_size, min_notional, status
+FROM orderbook_pairs
+WHERE (token_bid = $1 AND token_ask = $2) OR (token_bid = $2 AND token_ask = $1)
+FOR SHARE;
+`
+
+var listPairsQuery = `
+SELECT token_bid, token_ask, tick_size, lot_size, min_notional, status
+FROM orderbook_pairs
+ORDER BY token_bid, token_ask;
+`
+
+var updatePairQuery = `
+UPDATE orderbook_pairs SET tick_size = $3, lot_size = $4, min_notional = $5, status = $6
+WHERE (token_bid = $1 AND token_ask = $2) OR (token_bid = $2 AND token_ask = $1)
+RETURNING token_bid, token_ask;
 `
 
 var addOrderQuery = `
-	INSERT INTO orderbook_orders VALUES ($1, $2, $3, $4);
-	INSERT INTO $3_$4_rate VALUES ($1, $5);
-	INSERT INTO $3_$4_max_volume VALUES ($1, $6);
-	INSERT INTO $3_$4_min_volume VALUES ($1, $7);
+INSERT INTO orderbook_orders (id, maker_id, token_bid, token_ask, rate, max_volume, min_volume, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING priority;
 `
 
-var getOrderFromOrdersTableQuery = `
-SELECT orderbook_orders.id,
-    orderbook_orders.maker_id,
-    orderbook_orders.token_bid,
-    orderbook_orders.token_ask
+var updateOrderQuery = `
+UPDATE orderbook_orders SET rate = $2, max_volume = $3, min_volume = $4 WHERE id = $1;
+`
+
+var resetOrderPriorityQuery = `
+UPDATE orderbook_orders SET priority = nextval(pg_get_serial_sequence('orderbook_orders', 'priority'))
+WHERE id = $1
+RETURNING priority;
+`
+
+// Orders table keeps closed orders with their history, so order queries read live orders only.
+// Live orders of pair direction are read by partial composite indexes of pair tokens and rate, max volume or min volume.
+
+// getOrderByIdQuery getting live order $1, orders expired at time $2 are skipped, null $2 includes expired orders
+var getOrderByIdQuery = `
+SELECT id, maker_id, token_bid, token_ask, rate, max_volume, min_volume, priority, expires_at, status
 FROM orderbook_orders
-WHERE orderbook_orders.id = $1;
+WHERE id = $1 AND status IN ('open', 'partially_filled')
+    AND ($2::timestamptz IS NULL OR expires_at IS NULL OR expires_at > $2);
 `
 
-var getOrderByIdAndPairQuery = `
-SELECT order.id,
-    orderbook_orders.maker_id,
-    orderbook_orders.token_bid,
-    orderbook_orders.token_ask,
-    $2_$3_rate.rate,
-    $2_$3_max_volume.max_volume,
-    $2_$3_min_volume.min_volume
+var getOrderByIdForUpdateQuery = `
+SELECT id, maker_id, token_bid, token_ask, rate, max_volume, min_volume, priority, expires_at, status
 FROM orderbook_orders
-    JOIN $2_$3_max_volume ON $2_$3_max_volume.id = orderbook_orders.id
-    JOIN $2_$3_min_volume ON $2_$3_min_volume.id = orderbook_orders.id
-    JOIN $2_$3_rate ON $2_$3_rate.id = orderbook_orders.id
-WHERE orderbook_orders.id = $1;
+WHERE id = $1 AND status IN ('open', 'partially_filled')
+    AND ($2::timestamptz IS NULL OR expires_at IS NULL OR expires_at > $2)
+FOR UPDATE;
 `
 
+// Orders of pair direction $1, $2 expired at time $3 are skipped.
+// Orders with equal rate are ordered by time priority, the earliest order is the best of them.
+
 var getOrderWithMaxRateQuery = `
-SELECT orderbook_orders.id,
-    orderbook_orders.maker_id,
-    orderbook_orders.token_bid,
-    orderbook_orders.token_ask,
-    $1_$2_rate.rate
-    $1_$2_max_volume.max_volume,
-    $1_$2_min_volume.min_volume
-FROM $1_$2_rate
-    JOIN $1_$2_min_volume ON $1_$2_min_volume.id = $1_$2_rate.id
-    JOIN $1_$2_max_volume ON $1_$2_max_volume.id = $1_$2_rate.id
-    JOIN orderbook_orders ON orderbook_orders.id = $1_$2_rate.id
-WHERE $1_$2_rate.rate = (SELECT MAX($1_$2_rate.rate) FROM $1_$2_rate) LIMIT 1;
+SELECT id, maker_id, token_bid, token_ask, rate, max_volume, min_volume, priority, expires_at, status
+FROM orderbook_orders
+WHERE token_bid = $1 AND token_ask = $2 AND status IN ('open', 'partially_filled')
+    AND (expires_at IS NULL OR expires_at > $3)
+ORDER BY rate DESC, priority
+LIMIT 1;
 `
 
 var getOrderWithMinRateQuery = `
-SELECT orderbook_orders.id,
-    orderbook_orders.maker_id,
-    orderbook_orders.token_bid,
-    orderbook_orders.token_ask,
-    $1_$2_rate.rate
-    $1_$2_max_volume.max_volume,
-    $1_$2_min_volume.min_volume
-FROM $1_$2_rate
-    JOIN $1_$2_min_volume ON $1_$2_min_volume.id = $1_$2_rate.id
-    JOIN $1_$2_max_volume ON $1_$2_max_volume.id = $1_$2_rate.id
-    JOIN orderbook_orders ON orderbook_orders.id = $1_$2_rate.id
-WHERE $1_$2_rate.rate = (SELECT MIN($1_$2_rate.rate) FROM $1_$2_rate) LIMIT 1;
+SELECT id, maker_id, token_bid, token_ask, rate, max_volume, min_volume, priority, expires_at, status
+FROM orderbook_orders
+WHERE token_bid = $1 AND token_ask = $2 AND status IN ('open', 'partially_filled')
+    AND (expires_at IS NULL OR expires_at > $3)
+ORDER BY rate, priority DESC
+LIMIT 1;
 `
 
 var getOrderWithMaxVolumeQuery = `
-SELECT orderbook_orders.id,
-    orderbook_orders.maker_id,
-    orderbook_orders.token_bid,
-    orderbook_orders.token_ask,
-    $1_$2_rate.rate,
-    $1_$2_max_volume.max_volume,
-    $1_$2_min_volume.min_volume
-FROM $1_$2_max_volume
-    JOIN $1_$2_rate ON $1_$2_rate.id = $1_$2_min_volume.id
-    JOIN $1_$2_max_volume ON $1_$2_max_volume.id = $1_$2_min_volume.id
-    JOIN orderbook_orders ON orderbook_orders.id = $1_$2_min_volume.id
-WHERE $1_$2_max_volume.max_volume = (SELECT MAX($1_$2_max_volume.max_volume) FROM $1_$2_max_volume) LIMIT 1;
+SELECT id, maker_id, token_bid, token_ask, rate, max_volume, min_volume, priority, expires_at, status
+FROM orderbook_orders
+WHERE token_bid = $1 AND token_ask = $2 AND status IN ('open', 'partially_filled')
+    AND (expires_at IS NULL OR expires_at > $3)
+ORDER BY max_volume DESC, id DESC
+LIMIT 1;
 `
 
 var getOrderWithMinVolumeQuery = `
-SELECT orderbook_orders.id,
-    orderbook_orders.maker_id,
-    orderbook_orders.token_bid,
-    orderbook_orders.token_ask,
-    $1_$2_rate.rate,
-    $1_$2_max_volume.max_volume,
-    $1_$2_min_volume.min_volume
-FROM $1_$2_min_volume
-    JOIN $1_$2_rate ON $1_$2_rate.id = $1_$2_min_volume.id
-    JOIN $1_$2_max_volume ON $1_$2_max_volume.id = $1_$2_min_volume.id
-    JOIN orderbook_orders ON orderbook_orders.id = $1_$2_min_volume.id
-WHERE $1_$2_min_volume.min_volume = (SELECT MIN($1_$2_min_volume.min_volume) FROM $1_$2_min_volume) LIMIT 1;
-`
-
-var listOrdersByPairQuery = `
-SELECT order.id,
-    orderbook_orders.maker_id,
-    orderbook_orders.token_bid,
-    orderbook_orders.token_ask,
-    $1_$2_rate.rate,
-    $1_$2_max_volume.max_volume,
-    $1_$2_min_volume.min_volume
+SELECT id, maker_id, token_bid, token_ask, rate, max_volume, min_volume, priority, expires_at, status
+FROM orderbook_orders
+WHERE token_bid = $1 AND token_ask = $2 AND status IN ('open', 'partially_filled')
+    AND (expires_at IS NULL OR expires_at > $3)
+ORDER BY min_volume, id
+LIMIT 1;
+`
+
+// listPairOrdersForUpdateQuery locking live orders of pair direction $1, $2 including expired ones
+var listPairOrdersForUpdateQuery = `
+SELECT id, maker_id, token_bid, token_ask, rate, max_volume, min_volume, priority, expires_at, status
+FROM orderbook_orders
+WHERE token_bid = $1 AND token_ask = $2 AND status IN ('open', 'partially_filled')
+ORDER BY priority
+FOR UPDATE;
+`
+
+// getTopOfBookQuery selecting orders with max rate of pair direction $1, $2 and of reversed direction,
+// orders expired at $3 are skipped
+var getTopOfBookQuery = `
+(
+SELECT id, maker_id, token_bid, token_ask, rate, max_volume, min_volume, priority, expires_at, status
+FROM orderbook_orders
+WHERE token_bid = $1 AND token_ask = $2 AND status IN ('open', 'partially_filled')
+    AND (expires_at IS NULL OR expires_at > $3)
+ORDER BY rate DESC, priority
+LIMIT 1
+)
+UNION ALL
+(
+SELECT id, maker_id, token_bid, token_ask, rate, max_volume, min_volume, priority, expires_at, status
+FROM orderbook_orders
+WHERE token_bid = $2 AND token_ask = $1 AND status IN ('open', 'partially_filled')
+    AND (expires_at IS NULL OR expires_at > $3)
+ORDER BY rate DESC, priority
+LIMIT 1
+);
+`
+
+// listMatchingOrdersQuery locking orders of pair direction crossing taker rate $3 in order of matching
+var listMatchingOrdersQuery = `
+SELECT id, maker_id, token_bid, token_ask, rate, max_volume, min_volume, priority, expires_at, status
+FROM orderbook_orders
+WHERE token_bid = $1 AND token_ask = $2 AND status IN ('open', 'partially_filled')
+    AND (expires_at IS NULL OR expires_at > $4) AND rate * $3 >= 1
+ORDER BY rate DESC, priority
+FOR UPDATE;
+`
+
+// listQuoteOrdersQuery reading orders of pair direction in order of matching without locking them
+var listQuoteOrdersQuery = `
+SELECT id, maker_id, token_bid, token_ask, rate, max_volume, min_volume, priority, expires_at, status
+FROM orderbook_orders
+WHERE token_bid = $1 AND token_ask = $2 AND status IN ('open', 'partially_filled')
+    AND (expires_at IS NULL OR expires_at > $3)
+ORDER BY rate DESC, priority;
+`
+
+var updateOrderMaxVolumeQuery = `
+UPDATE orderbook_orders SET max_volume = $2, status = $3 WHERE id = $1;
+`
+
+var closeOrderQuery = `
+UPDATE orderbook_orders SET status = $2 WHERE id = $1;
+`
+
+var addStatusChangeQuery = `
+INSERT INTO orderbook_order_history (order_id, status, rate, max_volume, min_volume, time) VALUES ($1, $2, $3, $4, $5, $6);
+`
+
+var getOrderHistoryQuery = `
+SELECT order_id, status, rate, max_volume, min_volume, time
+FROM orderbook_order_history
+WHERE order_id = $1
+ORDER BY id;
+`
+
+var addTradeQuery = `
+INSERT INTO orderbook_trades (maker_order_id, maker_id, taker_order_id, taker_id, token_bid, token_ask, rate, volume, time)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+RETURNING id;
+`
+
+var getTradeQuery = `
+SELECT id, maker_order_id, maker_id, taker_order_id, taker_id, token_bid, token_ask, rate, volume, time
+FROM orderbook_trades
+WHERE id = $1;
+`
+
+// Time range bounds of trade lists are nullable parameters, null bound means open range.
+// Lists are paged by keyset, rows after the last row of previous page are selected when after parameter is true.
+
+var listTradesByPairQuery = `
+SELECT id, maker_order_id, maker_id, taker_order_id, taker_id, token_bid, token_ask, rate, volume, time
+FROM orderbook_trades
+WHERE ((token_bid = $1 AND token_ask = $2) OR (token_bid = $2 AND token_ask = $1))
+    AND ($3::timestamptz IS NULL OR time >= $3) AND ($4::timestamptz IS NULL OR time < $4)
+    AND (NOT $5 OR id > $6)
+ORDER BY id
+LIMIT $7;
+`
+
+var listTradesByMakerQuery = `
+SELECT id, maker_order_id, maker_id, taker_order_id, taker_id, token_bid, token_ask, rate, volume, time
+FROM orderbook_trades
+WHERE maker_id = $1
+    AND ($2::timestamptz IS NULL OR time >= $2) AND ($3::timestamptz IS NULL OR time < $3)
+    AND (NOT $4 OR id > $5)
+ORDER BY id
+LIMIT $6;
+`
+
+// listOrdersQuery is a frame of ListOrders query built by buildListOrdersQuery,
+// it is formatted with filters, ORDER BY columns and LIMIT placeholder
+var listOrdersQuery = `
+SELECT id, maker_id, token_bid, token_ask, rate, max_volume, min_volume, priority, expires_at, status
 FROM orderbook_orders
-    JOIN $1_$2_max_volume ON $1_$2_max_volume.id = orderbook_orders.id
-    JOIN $1_$2_min_volume ON $1_$2_min_volume.id = orderbook_orders.id
-    JOIN $1_$2_rate ON $1_$2_rate.id = orderbook_orders.id
-WHERE orderbook_orders.token_bid = $1 AND orderbook_orders.token_ask = $2 ORDER BY order.id $3;
+WHERE %s
+ORDER BY %s
+LIMIT %s;
 `
 
-var listOrdersByMakerIdFromOrdersTableQuery = `
-SELECT orderbook_orders.id,
-    orderbook_orders.maker_id,
-    orderbook_orders.token_bid,
-    orderbook_orders.token_ask
+// getDepthQuery aggregating orders of pair direction $1, $2 not expired at $4 into levels of rates rounded down to tick $3,
+// null limit $5 is LIMIT ALL
+var getDepthQuery = `
+SELECT CASE WHEN $3::numeric = 0 THEN rate ELSE floor(rate / $3::numeric) * $3::numeric END AS level_rate,
+    SUM(max_volume),
+    COUNT(*)
 FROM orderbook_orders
-WHERE orderbook_orders.maker_id = $1$2;
+WHERE token_bid = $1 AND token_ask = $2 AND status IN ('open', 'partially_filled')
+    AND (expires_at IS NULL OR expires_at > $4)
+GROUP BY level_rate
+ORDER BY level_rate DESC
+LIMIT $5;
 `
 
-var listOrdersByMakerIdQuery = `
-SELECT order.id,
-    orderbook_orders.maker_id,
-    orderbook_orders.token_bid,
-    orderbook_orders.token_ask,
-    $2_$3_rate.rate,
-    $2_$3_max_volume.max_volume,
-    $2_$3_min_volume.min_volume
+// listExpiredOrdersQuery locking live orders expired at time $1
+var listExpiredOrdersQuery = `
+SELECT id, maker_id, token_bid, token_ask, rate, max_volume, min_volume, priority, expires_at, status
 FROM orderbook_orders
-    JOIN $2_$3_max_volume ON $2_$3_max_volume.id = orderbook_orders.id
-    JOIN $2_$3_min_volume ON $2_$3_min_volume.id = orderbook_orders.id
-    JOIN $2_$3_rate ON $2_$3_rate.id = orderbook_orders.id
-WHERE maker_id = $1$4;
-`
-
-var listMaxRateOrdersQuery = `
-SELECT orderbook_orders.id,
-    orderbook_orders.maker_id,
-    orderbook_orders.token_bid,
-    orderbook_orders.token_ask,
-    $1_$2_rate.rate
-    $1_$2_max_volume.max_volume,
-    $1_$2_min_volume.min_volume
-FROM $1_$2_rate
-    JOIN $1_$2_min_volume ON $1_$2_min_volume.id = $1_$2_rate.id
-    JOIN $1_$2_max_volume ON $1_$2_max_volume.id = $1_$2_rate.id
-    JOIN orderbook_orders ON orderbook_orders.id = $1_$2_rate.id
-WHERE $1_$2_rate.rate = (SELECT $1_$2_rate.rate FROM $1_$2_rate ORDER BY $1_$2_rate.rate DESC$3);
-`
-
-var listMinRateOrdersQuery = `
-SELECT orderbook_orders.id,
-    orderbook_orders.maker_id,
-    orderbook_orders.token_bid,
-    orderbook_orders.token_ask,
-    $1_$2_rate.rate
-    $1_$2_max_volume.max_volume,
-    $1_$2_min_volume.min_volume
-FROM $1_$2_rate
-    JOIN $1_$2_min_volume ON $1_$2_min_volume.id = $1_$2_rate.id
-    JOIN $1_$2_max_volume ON $1_$2_max_volume.id = $1_$2_rate.id
-    JOIN orderbook_orders ON orderbook_orders.id = $1_$2_rate.id
-WHERE $1_$2_rate.rate = (SELECT $1_$2_rate.rate FROM $1_$2_rate ORDER BY $1_$2_rate.rate ASK$3);
-`
-
-var listMaxVolumeOrdersQuery = `
-SELECT orderbook_orders.id,
-    orderbook_orders.maker_id,
-    orderbook_orders.token_bid,
-    orderbook_orders.token_ask,
-    $1_$2_rate.rate,
-    $1_$2_max_volume.max_volume,
-    $1_$2_min_volume.min_volume
-FROM $1_$2_max_volume
-    JOIN $1_$2_rate ON $1_$2_rate.id = $1_$2_min_volume.id
-    JOIN $1_$2_max_volume ON $1_$2_max_volume.id = $1_$2_min_volume.id
-    JOIN orderbook_orders ON orderbook_orders.id = $1_$2_min_volume.id
-WHERE $1_$2_max_volume.max_volume = (SELECT $1_$2_max_volume.max_volume FROM $1_$2_max_volume ORDER BY $1_$2_max_volume.max_volume DESK$3);
-`
-
-var listMinVolumeOrdersQuery = `
-SELECT orderbook_orders.id,
-    orderbook_orders.maker_id,
-    orderbook_orders.token_bid,
-    orderbook_orders.token_ask,
-    $1_$2_rate.rate,
-    $1_$2_max_volume.max_volume,
-    $1_$2_min_volume.min_volume
-FROM $1_$2_min_volume
-    JOIN $1_$2_rate ON $1_$2_rate.id = $1_$2_min_volume.id
-    JOIN $1_$2_max_volume ON $1_$2_max_volume.id = $1_$2_min_volume.id
-    JOIN orderbook_orders ON orderbook_orders.id = $1_$2_min_volume.id
-WHERE $1_$2_min_volume.min_volume = (SELECT $1_$2_min_volume.min_volume FROM $1_$2_min_volume ORDER BY $1_$2_min_volume.min_volume ASK$3);
-`
-
-var removePairQuery = `
-DROP TABLE IF EXISTS $1_$2_rate;
-DROP TABLE IF EXISTS $1_$2_max_volume;
-DROP TABLE IF EXISTS $1_$2_min_volume;
-
-DROP TABLE IF EXISTS $2_$1_rate;
-DROP TABLE IF EXISTS $2_$1_max_volume;
-DROP TABLE IF EXISTS $2_$1_min_volume;
-`
-
-var removeOrderQuery = `
-DELETE FROM orderbook_orders WHERE id = $1;
+WHERE status IN ('open', 'partially_filled') AND expires_at <= $1
+ORDER BY id
+FOR UPDATE;
+`
+
+var removePairFromPairsTableQuery = `
+DELETE FROM orderbook_pairs WHERE (token_bid = $1 AND token_ask = $2) OR (token_bid = $2 AND token_ask = $1);
+`
+
+// addPairOrdersCancelledStatusQuery adding cancelled status at time $3 to history of live orders of pair direction $1, $2
+var addPairOrdersCancelledStatusQuery = `
+INSERT INTO orderbook_order_history (order_id, status, rate, max_volume, min_volume, time)
+SELECT id, 'cancelled', rate, max_volume, min_volume, $3
+FROM orderbook_orders
+WHERE token_bid = $1 AND token_ask = $2 AND status IN ('open', 'partially_filled')
+ORDER BY priority;
+`
+
+// cancelPairOrdersQuery cancelling live orders of both pair directions
+var cancelPairOrdersQuery = `
+UPDATE orderbook_orders SET status = 'cancelled'
+WHERE ((token_bid = $1 AND token_ask = $2) OR (token_bid = $2 AND token_ask = $1)) AND status IN ('open', 'partially_filled');
+`
+
+// nextEventSeqQuery incrementing event sequence of pair tokens sorted by eventPair, the row is locked until commit
+var nextEventSeqQuery = `
+INSERT INTO orderbook_event_seqs (token_a, token_b, seq) VALUES ($1, $2, 1)
+ON CONFLICT (token_a, token_b) DO UPDATE SET seq = orderbook_event_seqs.seq + 1
+RETURNING seq;
+`
+
+var notifyEventQuery = `
+SELECT pg_notify($1, $2);
+`
+
+var listEventSeqsQuery = `
+SELECT token_a, token_b, seq FROM orderbook_event_seqs;
+`
+
+var addOutboxEventQuery = `
+INSERT INTO orderbook_outbox (event, created_at) VALUES ($1, $2);
+`
+
+var lockOutboxClaimsQuery = `
+SELECT pg_advisory_xact_lock($1);
+`
+
+// claimOutboxEventsQuery claiming up to $1 pending events until $2, events claimed by others are skipped until their lease ends at $3,
+// event isn't claimed while earlier event of its pair is claimed by others
+var claimOutboxEventsQuery = `
+UPDATE orderbook_outbox SET claimed_until = $2
+WHERE id IN (
+    SELECT id
+    FROM orderbook_outbox AS pending
+    WHERE delivered_at IS NULL AND (claimed_until IS NULL OR claimed_until <= $3)
+        AND NOT EXISTS (
+            SELECT 1
+            FROM orderbook_outbox AS claimed
+            WHERE claimed.delivered_at IS NULL AND claimed.claimed_until > $3 AND claimed.id < pending.id
+                AND LEAST(claimed.event->>'token_bid', claimed.event->>'token_ask') = LEAST(pending.event->>'token_bid', pending.event->>'token_ask')
+                AND GREATEST(claimed.event->>'token_bid', claimed.event->>'token_ask') = GREATEST(pending.event->>'token_bid', pending.event->>'token_ask')
+        )
+    ORDER BY id
+    LIMIT $1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, event;
+`
+
+var markOutboxEventsDeliveredQuery = `
+UPDATE orderbook_outbox SET delivered_at = $2, claimed_until = NULL WHERE id = ANY($1);
+`
+
+var releaseOutboxEventsQuery = `
+UPDATE orderbook_outbox SET claimed_until = NULL WHERE id = ANY($1) AND delivered_at IS NULL;
 `
@@ -0,0 +1,144 @@
+package postgres
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/SashaBokov/orderbook"
+)
+
+// sortColumns are columns of ListOrders query sorting orders by sort key
+type sortColumns struct {
+	column string
+	// tie is a column sorting orders with equal column, it is empty when column is unique
+	tie string
+	// tieReversed is true when tie column is sorted in opposite direction
+	tieReversed bool
+	// position returning cursor position of order
+	position func(order orderbook.Order) orderbook.Position
+	// args returning values of column and tie column of position
+	args func(position orderbook.Position) (value, tie interface{})
+}
+
+var sortKeyColumns = map[orderbook.SortKey]sortColumns{
+	orderbook.SortById: {
+		column:   "id",
+		position: func(order orderbook.Order) orderbook.Position { return orderbook.Position{Id: order.Id} },
+		args:     func(position orderbook.Position) (interface{}, interface{}) { return position.Id, nil },
+	},
+	orderbook.SortByRate: {
+		column:      "rate",
+		tie:         "priority",
+		tieReversed: true,
+		position: func(order orderbook.Order) orderbook.Position {
+			return orderbook.Position{Value: order.Rate, Seq: order.Priority, Id: order.Id}
+		},
+		args: func(position orderbook.Position) (interface{}, interface{}) { return position.Value, position.Seq },
+	},
+	orderbook.SortByMaxVolume: {
+		column: "max_volume",
+		tie:    "id",
+		position: func(order orderbook.Order) orderbook.Position {
+			return orderbook.Position{Value: order.MaxVolume, Id: order.Id}
+		},
+		args: func(position orderbook.Position) (interface{}, interface{}) { return position.Value, position.Id },
+	},
+	orderbook.SortByMinVolume: {
+		column: "min_volume",
+		tie:    "id",
+		position: func(order orderbook.Order) orderbook.Position {
+			return orderbook.Position{Value: order.MinVolume, Id: order.Id}
+		},
+		args: func(position orderbook.Position) (interface{}, interface{}) { return position.Value, position.Id },
+	},
+}
+
+// querySortColumns returning sort columns of query, empty sort is SortById
+func querySortColumns(q orderbook.Query) sortColumns {
+	if q.Sort == "" {
+		return sortKeyColumns[orderbook.SortById]
+	}
+
+	return sortKeyColumns[q.Sort]
+}
+
+// page returning page of orders selected with limit+1, the extra order means there is the next page
+func (key sortColumns) page(orders []orderbook.Order, limit int) orderbook.Page {
+	if len(orders) <= limit {
+		return orderbook.Page{Orders: orders}
+	}
+
+	orders = orders[:limit]
+	return orderbook.Page{Orders: orders, NextCursor: key.position(orders[limit-1]).Cursor()}
+}
+
+// queryArgs are arguments of query built in code
+type queryArgs []interface{}
+
+// add adding argument and returning its placeholder
+func (args *queryArgs) add(arg interface{}) string {
+	*args = append(*args, arg)
+	return fmt.Sprintf("$%d", len(*args))
+}
+
+// buildListOrdersQuery building ListOrders query of live orders not expired at now, selecting limit+1 orders.
+// Query of pair is served by composite index of pair tokens and sort column.
+func buildListOrdersQuery(q orderbook.Query, key sortColumns, now time.Time) (string, []interface{}, error) {
+	var args queryArgs
+	conditions := []string{"status IN ('open', 'partially_filled')", "(expires_at IS NULL OR expires_at > " + args.add(now) + ")"}
+	if q.HasPair() {
+		conditions = append(conditions, "token_bid = "+args.add(q.TokenBid), "token_ask = "+args.add(q.TokenAsk))
+	}
+	if q.MakerId != "" {
+		conditions = append(conditions, "maker_id = "+args.add(q.MakerId))
+	}
+	if q.MinRate != nil {
+		conditions = append(conditions, "rate >= "+args.add(*q.MinRate))
+	}
+	if q.MaxRate != nil {
+		conditions = append(conditions, "rate <= "+args.add(*q.MaxRate))
+	}
+	if q.MinVolume != nil {
+		conditions = append(conditions, "max_volume >= "+args.add(*q.MinVolume))
+	}
+	if q.MaxVolume != nil {
+		conditions = append(conditions, "max_volume <= "+args.add(*q.MaxVolume))
+	}
+
+	direction, after := sortDirection(!q.Descending)
+	tieDirection, tieAfter := sortDirection(!q.Descending != key.tieReversed)
+
+	orderBy := key.column + " " + direction
+	if key.tie != "" {
+		orderBy += ", " + key.tie + " " + tieDirection
+	}
+
+	if q.Cursor != "" {
+		position, err := q.Cursor.Position()
+		if err != nil {
+			return "", nil, err
+		}
+
+		value, tie := key.args(position)
+		v := args.add(value)
+		if key.tie == "" {
+			conditions = append(conditions, key.column+" "+after+" "+v)
+		} else {
+			conditions = append(conditions, fmt.Sprintf("(%[1]s %[2]s %[3]s OR (%[1]s = %[3]s AND %[4]s %[5]s %[6]s))", key.column, after, v, key.tie, tieAfter, args.add(tie)))
+		}
+	}
+
+	query := fmt.Sprintf(listOrdersQuery, strings.Join(conditions, " AND "), orderBy, args.add(orderbook.PageLimit(q.Limit)+1))
+
+	return query, args, nil
+}
+
+// sortDirection returning ORDER BY direction and operator comparing the next rows with the last one
+func sortDirection(ascending bool) (string, string) {
+	if ascending {
+		return "ASC", ">"
+	}
+
+	return "DESC", "<"
+}
@@ -0,0 +1,154 @@
+package orderbook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/shopspring/decimal"
+)
+
+// DefaultMaxHops is a max number of pairs in route used by Router when max hops is not positive
+const DefaultMaxHops = 3
+
+// Route is a path of trades from TokenBid to TokenAsk through intermediate tokens,
+// taker gives Cost of TokenBid to get Amount of TokenAsk.
+type Route struct {
+	TokenBid string          `json:"token_bid"`
+	TokenAsk string          `json:"token_ask"`
+	Amount   decimal.Decimal `json:"amount"`
+	Cost     decimal.Decimal `json:"cost"`
+	// Rate is an effective rate of route, Amount divided by Cost
+	Rate decimal.Decimal `json:"rate"`
+	// Tokens are tokens of route from TokenBid to TokenAsk
+	Tokens []string `json:"tokens"`
+	// Hops are quotes of route pairs in order of Tokens, every hop gets Cost of the next hop
+	Hops []Quote `json:"hops"`
+}
+
+// Router finds the best route between tokens over graph of registered pairs, pairs are edges of both directions.
+// Halted pairs and routes which can't fill the amount are skipped.
+type Router struct {
+	book    OrderBook
+	maxHops int
+	// intermediates are tokens allowed in the middle of route, nil allows any token
+	intermediates map[string]bool
+}
+
+// NewRouter returns router of book with routes of at most maxHops pairs, non-positive maxHops is DefaultMaxHops.
+// Routes go only through intermediates tokens, no intermediates allow any token.
+func NewRouter(book OrderBook, maxHops int, intermediates ...string) *Router {
+	if maxHops <= 0 {
+		maxHops = DefaultMaxHops
+	}
+
+	r := &Router{book: book, maxHops: maxHops}
+	if len(intermediates) > 0 {
+		r.intermediates = make(map[string]bool, len(intermediates))
+		for _, token := range intermediates {
+			r.intermediates[token] = true
+		}
+	}
+
+	return r
+}
+
+// Route finding route getting amount of tokenAsk for the least cost of tokenBid, every hop is quoted with OrderBook.Quote.
+// Returns ErrNoRoute when there is no route filling amount.
+func (r *Router) Route(ctx context.Context, tokenBid, tokenAsk string, amount decimal.Decimal) (Route, error) {
+	if tokenBid == tokenAsk {
+		return Route{}, fmt.Errorf("%w: tokens must differ", ErrInvalidQuery)
+	}
+	if !amount.IsPositive() {
+		return Route{}, fmt.Errorf("%w: amount must be positive", ErrInvalidQuery)
+	}
+
+	pairs, err := r.book.ListPairs(ctx)
+	if err != nil {
+		return Route{}, err
+	}
+
+	graph := make(map[string][]string)
+	for _, pair := range pairs {
+		if pair.Status == PairHalted {
+			continue
+		}
+		graph[pair.TokenBid] = append(graph[pair.TokenBid], pair.TokenAsk)
+		graph[pair.TokenAsk] = append(graph[pair.TokenAsk], pair.TokenBid)
+	}
+
+	var best Route
+	found := false
+	for _, path := range r.paths(graph, tokenBid, tokenAsk) {
+		route, ok, err := r.quote(ctx, path, amount)
+		if err != nil {
+			return Route{}, err
+		}
+		if ok && (!found || route.Rate.GreaterThan(best.Rate)) {
+			best, found = route, true
+		}
+	}
+
+	if !found {
+		return Route{}, fmt.Errorf("%w: from %s to %s", ErrNoRoute, tokenBid, tokenAsk)
+	}
+
+	return best, nil
+}
+
+// paths returning paths without repeated tokens of at most maxHops pairs, shorter paths go first
+func (r *Router) paths(graph map[string][]string, from, to string) [][]string {
+	paths := make([][]string, 0)
+	visited := map[string]bool{from: true}
+	var walk func(path []string)
+	walk = func(path []string) {
+		last := path[len(path)-1]
+		for _, next := range graph[last] {
+			switch {
+			case next == to:
+				paths = append(paths, append(append([]string(nil), path...), to))
+			case visited[next] || len(path) == r.maxHops:
+			case r.intermediates != nil && !r.intermediates[next]:
+			default:
+				visited[next] = true
+				walk(append(path, next))
+				visited[next] = false
+			}
+		}
+	}
+	walk([]string{from})
+
+	return paths
+}
+
+// quote quoting path from the last hop, so every hop gets cost of the next one, ok is false when path can't fill amount
+func (r *Router) quote(ctx context.Context, path []string, amount decimal.Decimal) (Route, bool, error) {
+	hops := make([]Quote, len(path)-1)
+	cost := amount
+	for i := len(path) - 2; i >= 0; i-- {
+		quote, err := r.book.Quote(ctx, path[i], path[i+1], cost)
+		if errors.Is(err, ErrPairHalted) || errors.Is(err, ErrPairNotFound) {
+			// pair is halted or removed after listing
+			return Route{}, false, nil
+		}
+		if err != nil {
+			return Route{}, false, err
+		}
+		if !quote.Filled() {
+			return Route{}, false, nil
+		}
+
+		hops[i] = quote
+		cost = quote.Cost
+	}
+
+	return Route{
+		TokenBid: path[0],
+		TokenAsk: path[len(path)-1],
+		Amount:   amount,
+		Cost:     cost,
+		Rate:     amount.Div(cost),
+		Tokens:   path,
+		Hops:     hops,
+	}, true, nil
+}
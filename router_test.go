@@ -0,0 +1,139 @@
+package orderbook_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/SashaBokov/orderbook"
+	"github.com/SashaBokov/orderbook/repository/memory"
+	"github.com/shopspring/decimal"
+)
+
+// newRouterBook returning book where 100 USDT cost 10 BTC directly and 5 BTC through ETH
+func newRouterBook(t *testing.T) *memory.Book {
+	t.Helper()
+
+	book := memory.New()
+	ctx := context.Background()
+	for _, pair := range []orderbook.Pair{{TokenBid: "BTC", TokenAsk: "ETH"}, {TokenBid: "ETH", TokenAsk: "USDT"}, {TokenBid: "BTC", TokenAsk: "USDT"}} {
+		if err := book.AddNewPair(ctx, pair); err != nil {
+			t.Fatalf("adding pair: %v", err)
+		}
+	}
+
+	orders := []struct {
+		id, tokenBid, tokenAsk string
+		rate, volume           int64
+	}{
+		{"1", "USDT", "BTC", 10, 100},
+		{"2", "USDT", "ETH", 5, 100},
+		{"3", "ETH", "BTC", 4, 100},
+	}
+	for _, o := range orders {
+		order := orderbook.Order{
+			Id:        o.id,
+			MakerId:   "maker",
+			TokenBid:  o.tokenBid,
+			TokenAsk:  o.tokenAsk,
+			Rate:      decimal.NewFromInt(o.rate),
+			MaxVolume: decimal.NewFromInt(o.volume),
+			MinVolume: decimal.NewFromInt(1),
+		}
+		if err := book.AddOrder(ctx, order); err != nil {
+			t.Fatalf("adding order: %v", err)
+		}
+	}
+
+	return book
+}
+
+func TestRouterFindsBestRoute(t *testing.T) {
+	book := newRouterBook(t)
+	ctx := context.Background()
+	amount := decimal.NewFromInt(100)
+
+	tests := []struct {
+		name   string
+		router *orderbook.Router
+		tokens string
+		cost   int64
+	}{
+		{"any route", orderbook.NewRouter(book, 0), "[BTC ETH USDT]", 5},
+		{"single hop", orderbook.NewRouter(book, 1), "[BTC USDT]", 10},
+		{"other intermediates", orderbook.NewRouter(book, 0, "ADA"), "[BTC USDT]", 10},
+	}
+
+	for _, test := range tests {
+		route, err := test.router.Route(ctx, "BTC", "USDT", amount)
+		if err != nil {
+			t.Fatalf("%s: finding route: %v", test.name, err)
+		}
+		if tokens := fmt.Sprint(route.Tokens); tokens != test.tokens || !route.Cost.Equal(decimal.NewFromInt(test.cost)) {
+			t.Errorf("%s: expected route %s for %d BTC, got %s for %s BTC", test.name, test.tokens, test.cost, tokens, route.Cost)
+		}
+		if len(route.Hops) != len(route.Tokens)-1 || !route.Rate.Equal(amount.Div(route.Cost)) {
+			t.Errorf("%s: unexpected hops or rate of route %v", test.name, route)
+		}
+	}
+
+	// every hop gets cost of the next one
+	route, _ := orderbook.NewRouter(book, 0).Route(ctx, "BTC", "USDT", amount)
+	if !route.Hops[0].Fillable.Equal(route.Hops[1].Cost) || !route.Hops[1].Fillable.Equal(amount) {
+		t.Errorf("expected hops chained by cost, got %v", route.Hops)
+	}
+}
+
+func TestRouterAcceptsRoundedHop(t *testing.T) {
+	book := newRouterBook(t)
+	ctx := context.Background()
+
+	// 1 USDT for rate 3 is rounded down to 0.999999999999999999 USDT
+	if err := book.AddNewPair(ctx, orderbook.Pair{TokenBid: "ADA", TokenAsk: "USDT"}); err != nil {
+		t.Fatalf("adding pair: %v", err)
+	}
+	maker := orderbook.Order{Id: "4", MakerId: "maker", TokenBid: "USDT", TokenAsk: "ADA", Rate: decimal.NewFromInt(3), MaxVolume: decimal.NewFromInt(10)}
+	if err := book.AddOrder(ctx, maker); err != nil {
+		t.Fatalf("adding order: %v", err)
+	}
+	route, err := orderbook.NewRouter(book, 1).Route(ctx, "ADA", "USDT", decimal.NewFromInt(1))
+	if err != nil {
+		t.Fatalf("expected route of rounded hop, got %v", err)
+	}
+	if !route.Hops[0].Filled() || route.Hops[0].Fillable.Equal(decimal.NewFromInt(1)) {
+		t.Errorf("expected filled hop with rounded rest, got %v", route.Hops[0])
+	}
+}
+
+func TestRouterSkipsHaltedPairs(t *testing.T) {
+	book := newRouterBook(t)
+	ctx := context.Background()
+
+	if err := book.UpdatePair(ctx, orderbook.Pair{TokenBid: "BTC", TokenAsk: "ETH", Status: orderbook.PairHalted}); err != nil {
+		t.Fatalf("updating pair: %v", err)
+	}
+	route, err := orderbook.NewRouter(book, 0).Route(ctx, "BTC", "USDT", decimal.NewFromInt(100))
+	if err != nil || fmt.Sprint(route.Tokens) != "[BTC USDT]" {
+		t.Fatalf("expected direct route, got %v, %v", route, err)
+	}
+}
+
+func TestRouterErrors(t *testing.T) {
+	router := orderbook.NewRouter(newRouterBook(t), 0)
+	ctx := context.Background()
+
+	// direct route gives at most 1000 USDT and route through ETH at most 500 USDT
+	if _, err := router.Route(ctx, "BTC", "USDT", decimal.NewFromInt(2000)); !errors.Is(err, orderbook.ErrNoRoute) {
+		t.Fatalf("expected ErrNoRoute, got %v", err)
+	}
+	if _, err := router.Route(ctx, "BTC", "ADA", decimal.NewFromInt(1)); !errors.Is(err, orderbook.ErrNoRoute) {
+		t.Fatalf("expected ErrNoRoute of unknown token, got %v", err)
+	}
+	if _, err := router.Route(ctx, "BTC", "BTC", decimal.NewFromInt(1)); !errors.Is(err, orderbook.ErrInvalidQuery) {
+		t.Fatalf("expected ErrInvalidQuery of the same tokens, got %v", err)
+	}
+	if _, err := router.Route(ctx, "BTC", "USDT", decimal.Zero); !errors.Is(err, orderbook.ErrInvalidQuery) {
+		t.Fatalf("expected ErrInvalidQuery of zero amount, got %v", err)
+	}
+}
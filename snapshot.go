@@ -0,0 +1,462 @@
+package orderbook
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// SnapshotFormat is a format of Export and Import
+type SnapshotFormat string
+
+const (
+	// SnapshotJSON is JSON lines, every line is an object with record kind and pair or order marshaled by json tags
+	SnapshotJSON SnapshotFormat = "json"
+	// SnapshotCSV is CSV with header, record column is a record kind and other columns are db tags of Pair and Order,
+	// columns of the other record kind are empty
+	SnapshotCSV SnapshotFormat = "csv"
+)
+
+const (
+	recordPair  = "pair"
+	recordOrder = "order"
+	// recordColumn is a CSV column of record kind
+	recordColumn = "record"
+	// maxSnapshotLine is a maximal size of JSON line
+	maxSnapshotLine = 1 << 20
+)
+
+// snapshotRecord is a pair or order row of snapshot
+type snapshotRecord struct {
+	Record string `json:"record"`
+	Pair   *Pair  `json:"pair,omitempty"`
+	Order  *Order `json:"order,omitempty"`
+}
+
+// RowError is an error of imported row, Row is a number of record starting from 1 not counting CSV header
+type RowError struct {
+	Row int   `json:"row"`
+	Err error `json:"error"`
+}
+
+func (e RowError) Error() string {
+	return fmt.Sprintf("row %d: %s", e.Row, e.Err)
+}
+
+func (e RowError) Unwrap() error {
+	return e.Err
+}
+
+// MarshalJSON writing Err as its message, error values have no exported fields to marshal
+func (e RowError) MarshalJSON() ([]byte, error) {
+	var message string
+	if e.Err != nil {
+		message = e.Err.Error()
+	}
+
+	return json.Marshal(struct {
+		Row int    `json:"row"`
+		Err string `json:"error"`
+	}{Row: e.Row, Err: message})
+}
+
+// ImportResult is a number of imported pairs and orders and errors of rows which are not imported
+type ImportResult struct {
+	Pairs  int        `json:"pairs"`
+	Orders int        `json:"orders"`
+	Errors []RowError `json:"errors"`
+}
+
+// Export writing every pair and live order of book to w in format, pairs go first.
+// Orders are read page by page in matching order of every pair direction, so imported orders keep their time priority.
+func Export(ctx context.Context, book OrderBook, w io.Writer, format SnapshotFormat) error {
+	writer, err := newSnapshotWriter(w, format)
+	if err != nil {
+		return err
+	}
+
+	pairs, err := book.ListPairs(ctx)
+	if err != nil {
+		return fmt.Errorf("listing pairs: %w", err)
+	}
+
+	for i := range pairs {
+		if err := writer.write(snapshotRecord{Record: recordPair, Pair: &pairs[i]}); err != nil {
+			return fmt.Errorf("writing pair: %w", err)
+		}
+	}
+
+	for _, pair := range pairs {
+		for _, direction := range [][2]string{{pair.TokenBid, pair.TokenAsk}, {pair.TokenAsk, pair.TokenBid}} {
+			q := Query{TokenBid: direction[0], TokenAsk: direction[1], Sort: SortByRate, Descending: true}
+			for {
+				page, err := book.ListOrders(ctx, q)
+				if err != nil {
+					return fmt.Errorf("listing orders: %w", err)
+				}
+
+				for i := range page.Orders {
+					if err := writer.write(snapshotRecord{Record: recordOrder, Order: &page.Orders[i]}); err != nil {
+						return fmt.Errorf("writing order: %w", err)
+					}
+				}
+
+				if page.NextCursor == "" {
+					break
+				}
+				q.Cursor = page.NextCursor
+			}
+		}
+	}
+
+	return writer.flush()
+}
+
+// Import adding pairs and orders read from r in format to book, rows which can't be read or added are skipped
+// and reported in ImportResult.Errors. Orders get new priorities in order of rows.
+// Halted pairs are imported as active and halted after all orders are added.
+// Returned error means that reading is stopped, result has rows imported before it.
+func Import(ctx context.Context, book OrderBook, r io.Reader, format SnapshotFormat) (ImportResult, error) {
+	result := ImportResult{Errors: make([]RowError, 0)}
+	reader, err := newSnapshotReader(r, format)
+	if err != nil {
+		return result, err
+	}
+
+	type haltedPair struct {
+		row  int
+		pair Pair
+	}
+	halted := make([]haltedPair, 0)
+	for row := 1; ; row++ {
+		record, rowErr, err := reader.read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return result, fmt.Errorf("reading row %d: %w", row, err)
+		}
+
+		if rowErr == nil {
+			rowErr = importRecord(ctx, book, record, &result)
+			if rowErr == nil && record.Pair != nil && record.Pair.Status == PairHalted {
+				halted = append(halted, haltedPair{row: row, pair: *record.Pair})
+			}
+		}
+		if rowErr != nil {
+			if err := ctx.Err(); err != nil {
+				return result, err
+			}
+			result.Errors = append(result.Errors, RowError{Row: row, Err: rowErr})
+		}
+	}
+
+	for _, h := range halted {
+		if err := book.UpdatePair(ctx, h.pair); err != nil {
+			result.Errors = append(result.Errors, RowError{Row: h.row, Err: fmt.Errorf("halting pair: %w", err)})
+		}
+	}
+
+	return result, nil
+}
+
+// importRecord adding pair or order of record to book and counting it in result, halted pair is added as active
+func importRecord(ctx context.Context, book OrderBook, record snapshotRecord, result *ImportResult) error {
+	switch {
+	case record.Record == recordPair && record.Pair != nil:
+		pair := *record.Pair
+		if pair.Status == PairHalted {
+			pair.Status = PairActive
+		}
+		if err := book.AddNewPair(ctx, pair); err != nil {
+			return err
+		}
+		result.Pairs++
+	case record.Record == recordOrder && record.Order != nil:
+		if err := book.AddOrder(ctx, *record.Order); err != nil {
+			return err
+		}
+		result.Orders++
+	default:
+		return fmt.Errorf("%w: unknown record %q", ErrInvalidSnapshot, record.Record)
+	}
+
+	return nil
+}
+
+// snapshotWriter writes records of snapshot in format
+type snapshotWriter interface {
+	write(record snapshotRecord) error
+	flush() error
+}
+
+// snapshotReader reads records of snapshot in format, rowErr is an error of single row, err stops reading
+type snapshotReader interface {
+	read() (record snapshotRecord, rowErr error, err error)
+}
+
+func newSnapshotWriter(w io.Writer, format SnapshotFormat) (snapshotWriter, error) {
+	switch format {
+	case SnapshotJSON:
+		buffered := bufio.NewWriter(w)
+		return &jsonSnapshotWriter{buffered: buffered, encoder: json.NewEncoder(buffered)}, nil
+	case SnapshotCSV:
+		writer := csv.NewWriter(w)
+		if err := writer.Write(snapshotColumns); err != nil {
+			return nil, fmt.Errorf("writing header: %w", err)
+		}
+		return &csvSnapshotWriter{writer: writer}, nil
+	default:
+		return nil, fmt.Errorf("%w: unknown format %q", ErrInvalidSnapshot, format)
+	}
+}
+
+func newSnapshotReader(r io.Reader, format SnapshotFormat) (snapshotReader, error) {
+	switch format {
+	case SnapshotJSON:
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), maxSnapshotLine)
+		return &jsonSnapshotReader{scanner: scanner}, nil
+	case SnapshotCSV:
+		return newCSVSnapshotReader(r)
+	default:
+		return nil, fmt.Errorf("%w: unknown format %q", ErrInvalidSnapshot, format)
+	}
+}
+
+type jsonSnapshotWriter struct {
+	buffered *bufio.Writer
+	encoder  *json.Encoder
+}
+
+func (w *jsonSnapshotWriter) write(record snapshotRecord) error {
+	return w.encoder.Encode(record)
+}
+
+func (w *jsonSnapshotWriter) flush() error {
+	return w.buffered.Flush()
+}
+
+type jsonSnapshotReader struct {
+	scanner *bufio.Scanner
+}
+
+func (r *jsonSnapshotReader) read() (snapshotRecord, error, error) {
+	if !r.scanner.Scan() {
+		if err := r.scanner.Err(); err != nil {
+			return snapshotRecord{}, nil, err
+		}
+		return snapshotRecord{}, nil, io.EOF
+	}
+
+	var record snapshotRecord
+	if err := json.Unmarshal(r.scanner.Bytes(), &record); err != nil {
+		return snapshotRecord{}, fmt.Errorf("%w: %s", ErrInvalidSnapshot, err), nil
+	}
+
+	return record, nil, nil
+}
+
+// snapshotColumns are CSV columns, record column and db tags of Pair and Order fields, shared tags are listed once
+var snapshotColumns = func() []string {
+	columns := []string{recordColumn}
+	seen := map[string]bool{recordColumn: true}
+	for _, t := range []reflect.Type{reflect.TypeOf(Pair{}), reflect.TypeOf(Order{})} {
+		for _, column := range dbFields(t).columns {
+			if !seen[column] {
+				seen[column] = true
+				columns = append(columns, column)
+			}
+		}
+	}
+
+	return columns
+}()
+
+var (
+	pairFields  = dbFields(reflect.TypeOf(Pair{}))
+	orderFields = dbFields(reflect.TypeOf(Order{}))
+)
+
+// dbFields returning db tags of struct type fields with field indexes
+func dbFields(t reflect.Type) taggedFields {
+	fields := taggedFields{index: make(map[string]int)}
+	for i := 0; i < t.NumField(); i++ {
+		if tag := t.Field(i).Tag.Get("db"); tag != "" && tag != "-" {
+			fields.columns = append(fields.columns, tag)
+			fields.index[tag] = i
+		}
+	}
+
+	return fields
+}
+
+// taggedFields are db tags in order of struct fields and field indexes by tags
+type taggedFields struct {
+	columns []string
+	index   map[string]int
+}
+
+type csvSnapshotWriter struct {
+	writer *csv.Writer
+}
+
+func (w *csvSnapshotWriter) write(record snapshotRecord) error {
+	value, fields := recordValue(record)
+	row := make([]string, len(snapshotColumns))
+	row[0] = record.Record
+	for i, column := range snapshotColumns[1:] {
+		if index, ok := fields.index[column]; ok {
+			row[i+1] = formatField(value.Field(index))
+		}
+	}
+
+	return w.writer.Write(row)
+}
+
+func (w *csvSnapshotWriter) flush() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+type csvSnapshotReader struct {
+	reader  *csv.Reader
+	columns []string
+}
+
+func newCSVSnapshotReader(r io.Reader) (*csvSnapshotReader, error) {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: reading header: %s", ErrInvalidSnapshot, err)
+	}
+
+	known := make(map[string]bool, len(snapshotColumns))
+	for _, column := range snapshotColumns {
+		known[column] = true
+	}
+	hasRecord := false
+	for _, column := range header {
+		if !known[column] {
+			return nil, fmt.Errorf("%w: unknown column %q", ErrInvalidSnapshot, column)
+		}
+		hasRecord = hasRecord || column == recordColumn
+	}
+	if !hasRecord {
+		return nil, fmt.Errorf("%w: missing column %q", ErrInvalidSnapshot, recordColumn)
+	}
+
+	return &csvSnapshotReader{reader: reader, columns: header}, nil
+}
+
+func (r *csvSnapshotReader) read() (snapshotRecord, error, error) {
+	row, err := r.reader.Read()
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return snapshotRecord{}, fmt.Errorf("%w: %s", ErrInvalidSnapshot, err), nil
+	}
+	if err != nil {
+		return snapshotRecord{}, nil, err
+	}
+
+	values := make(map[string]string, len(row))
+	for i, column := range r.columns {
+		values[column] = row[i]
+	}
+
+	record := snapshotRecord{Record: values[recordColumn]}
+	switch record.Record {
+	case recordPair:
+		record.Pair = &Pair{}
+	case recordOrder:
+		record.Order = &Order{}
+	default:
+		return snapshotRecord{}, fmt.Errorf("%w: unknown record %q", ErrInvalidSnapshot, record.Record), nil
+	}
+
+	value, fields := recordValue(record)
+	for _, column := range fields.columns {
+		text, ok := values[column]
+		if !ok {
+			continue
+		}
+		if err := parseField(value.Field(fields.index[column]), text); err != nil {
+			return snapshotRecord{}, fmt.Errorf("%w: column %s: %s", ErrInvalidSnapshot, column, err), nil
+		}
+	}
+
+	return record, nil, nil
+}
+
+// recordValue returning settable struct value of record pair or order and its fields
+func recordValue(record snapshotRecord) (reflect.Value, taggedFields) {
+	if record.Pair != nil {
+		return reflect.ValueOf(record.Pair).Elem(), pairFields
+	}
+
+	return reflect.ValueOf(record.Order).Elem(), orderFields
+}
+
+// formatField formatting field of Pair or Order to CSV value, nil time is empty
+func formatField(field reflect.Value) string {
+	switch value := field.Interface().(type) {
+	case decimal.Decimal:
+		return value.String()
+	case *time.Time:
+		if value == nil {
+			return ""
+		}
+		return value.Format(time.RFC3339Nano)
+	case int64:
+		return strconv.FormatInt(value, 10)
+	default:
+		return field.String()
+	}
+}
+
+// parseField parsing CSV value to field of Pair or Order, empty decimal is zero and empty time is nil
+func parseField(field reflect.Value, text string) error {
+	switch field.Interface().(type) {
+	case decimal.Decimal:
+		value := decimal.Zero
+		if text != "" {
+			var err error
+			if value, err = decimal.NewFromString(text); err != nil {
+				return err
+			}
+		}
+		field.Set(reflect.ValueOf(value))
+	case *time.Time:
+		if text == "" {
+			field.Set(reflect.Zero(field.Type()))
+			return nil
+		}
+		value, err := time.Parse(time.RFC3339Nano, text)
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(&value))
+	case int64:
+		if text == "" {
+			return nil
+		}
+		value, err := strconv.ParseInt(text, 10, 64)
+		if err != nil {
+			return err
+		}
+		field.SetInt(value)
+	default:
+		field.SetString(text)
+	}
+
+	return nil
+}
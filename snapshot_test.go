@@ -0,0 +1,195 @@
+package orderbook_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SashaBokov/orderbook"
+	"github.com/SashaBokov/orderbook/repository/memory"
+	"github.com/shopspring/decimal"
+)
+
+func snapshotOrder(id, tokenBid, tokenAsk, rate string, volume int64) orderbook.Order {
+	return orderbook.Order{
+		Id:        id,
+		MakerId:   "maker" + id,
+		TokenBid:  tokenBid,
+		TokenAsk:  tokenAsk,
+		Rate:      decimal.RequireFromString(rate),
+		MaxVolume: decimal.NewFromInt(volume),
+		MinVolume: decimal.RequireFromString("0.5"),
+	}
+}
+
+// newSnapshotBook returning book with active and halted pairs and orders of both directions
+func newSnapshotBook(t *testing.T) *memory.Book {
+	t.Helper()
+
+	book := memory.New()
+	ctx := context.Background()
+	pairs := []orderbook.Pair{
+		{TokenBid: "BTC", TokenAsk: "ETH", TickSize: decimal.RequireFromString("0.5")},
+		{TokenBid: "BTC", TokenAsk: "USDT"},
+	}
+	for _, pair := range pairs {
+		if err := book.AddNewPair(ctx, pair); err != nil {
+			t.Fatalf("adding pair: %v", err)
+		}
+	}
+
+	expiresAt := time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)
+	expiring := snapshotOrder("3", "BTC", "ETH", "2.5", 10)
+	expiring.ExpiresAt = &expiresAt
+	orders := []orderbook.Order{
+		snapshotOrder("1", "BTC", "ETH", "2", 10),
+		snapshotOrder("2", "BTC", "ETH", "2.5", 10),
+		expiring,
+		snapshotOrder("4", "ETH", "BTC", "0.5", 7),
+		snapshotOrder("5", "USDT", "BTC", "100", 1),
+	}
+	for _, order := range orders {
+		if err := book.AddOrder(ctx, order); err != nil {
+			t.Fatalf("adding order: %v", err)
+		}
+	}
+
+	if err := book.UpdatePair(ctx, orderbook.Pair{TokenBid: "BTC", TokenAsk: "USDT", Status: orderbook.PairHalted}); err != nil {
+		t.Fatalf("halting pair: %v", err)
+	}
+
+	return book
+}
+
+// bookContents returning pairs and orders of book in matching order of every pair direction
+func bookContents(t *testing.T, book orderbook.OrderBook) string {
+	t.Helper()
+
+	ctx := context.Background()
+	pairs, err := book.ListPairs(ctx)
+	if err != nil {
+		t.Fatalf("listing pairs: %v", err)
+	}
+
+	var contents strings.Builder
+	for _, pair := range pairs {
+		fmt.Fprintf(&contents, "%s_%s %s %s\n", pair.TokenBid, pair.TokenAsk, pair.TickSize, pair.Status)
+		for _, direction := range [][2]string{{pair.TokenBid, pair.TokenAsk}, {pair.TokenAsk, pair.TokenBid}} {
+			page, err := book.ListOrders(ctx, orderbook.Query{TokenBid: direction[0], TokenAsk: direction[1], Sort: orderbook.SortByRate, Descending: true})
+			if err != nil {
+				t.Fatalf("listing orders: %v", err)
+			}
+			for _, order := range page.Orders {
+				fmt.Fprintf(&contents, "%s %s %s_%s %s %s %s %v\n", order.Id, order.MakerId, order.TokenBid, order.TokenAsk,
+					order.Rate, order.MaxVolume, order.MinVolume, order.ExpiresAt)
+			}
+		}
+	}
+
+	return contents.String()
+}
+
+func TestSnapshotRoundtrip(t *testing.T) {
+	source := newSnapshotBook(t)
+	ctx := context.Background()
+
+	for _, format := range []orderbook.SnapshotFormat{orderbook.SnapshotJSON, orderbook.SnapshotCSV} {
+		var snapshot bytes.Buffer
+		if err := orderbook.Export(ctx, source, &snapshot, format); err != nil {
+			t.Fatalf("%s: exporting book: %v", format, err)
+		}
+
+		imported := memory.New()
+		result, err := orderbook.Import(ctx, imported, &snapshot, format)
+		if err != nil {
+			t.Fatalf("%s: importing book: %v", format, err)
+		}
+		if result.Pairs != 2 || result.Orders != 5 || len(result.Errors) != 0 {
+			t.Fatalf("%s: expected 2 pairs and 5 orders imported, got %v", format, result)
+		}
+
+		// orders keep their matching order and halted pair is halted after its orders are added
+		if want, got := bookContents(t, source), bookContents(t, imported); got != want {
+			t.Errorf("%s: expected imported book\n%s\ngot\n%s", format, want, got)
+		}
+	}
+}
+
+func TestImportRowErrors(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		format   orderbook.SnapshotFormat
+		snapshot string
+	}{
+		{orderbook.SnapshotJSON, `{"record":"pair","pair":{"token_bid":"BTC","token_ask":"ETH"}}
+not json
+{"record":"trade"}
+{"record":"order","order":{"id":"1","maker_id":"maker","token_bid":"BTC","token_ask":"ETH","rate":"2","max_volume":"10","min_volume":"1"}}
+{"record":"order","order":{"id":"1","maker_id":"maker","token_bid":"BTC","token_ask":"ETH","rate":"2","max_volume":"10","min_volume":"1"}}
+{"record":"order","order":{"id":"2","maker_id":"maker","token_bid":"BTC","token_ask":"USDT","rate":"2","max_volume":"10","min_volume":"1"}}
+`},
+		{orderbook.SnapshotCSV, `record,token_bid,token_ask,id,maker_id,rate,max_volume,min_volume
+pair,BTC,ETH,,,,,
+order,BTC,ETH,1,maker,two,10,1
+trade,,,,,,,
+order,BTC,ETH,1,maker,2,10,1
+order,BTC,ETH,1,maker,2,10,1
+order,BTC,USDT,2,maker,2,10,1
+`},
+	}
+
+	for _, test := range tests {
+		book := memory.New()
+		result, err := orderbook.Import(ctx, book, strings.NewReader(test.snapshot), test.format)
+		if err != nil {
+			t.Fatalf("%s: importing book: %v", test.format, err)
+		}
+		if result.Pairs != 1 || result.Orders != 1 || len(result.Errors) != 4 {
+			t.Fatalf("%s: expected 1 pair, 1 order and 4 row errors, got %v", test.format, result)
+		}
+
+		wantErrors := []error{orderbook.ErrInvalidSnapshot, orderbook.ErrInvalidSnapshot, orderbook.ErrDuplicateOrder, orderbook.ErrPairNotFound}
+		for i, rowErr := range result.Errors {
+			if rowErr.Row != []int{2, 3, 5, 6}[i] || !errors.Is(rowErr, wantErrors[i]) {
+				t.Errorf("%s: expected %v at row %d, got %v", test.format, wantErrors[i], []int{2, 3, 5, 6}[i], rowErr)
+			}
+		}
+	}
+}
+
+func TestSnapshotFormatErrors(t *testing.T) {
+	ctx := context.Background()
+	book := memory.New()
+
+	if err := orderbook.Export(ctx, book, &bytes.Buffer{}, "xml"); !errors.Is(err, orderbook.ErrInvalidSnapshot) {
+		t.Fatalf("expected ErrInvalidSnapshot of unknown format, got %v", err)
+	}
+	if _, err := orderbook.Import(ctx, book, strings.NewReader(""), "xml"); !errors.Is(err, orderbook.ErrInvalidSnapshot) {
+		t.Fatalf("expected ErrInvalidSnapshot of unknown format, got %v", err)
+	}
+	if _, err := orderbook.Import(ctx, book, strings.NewReader("record,color\n"), orderbook.SnapshotCSV); !errors.Is(err, orderbook.ErrInvalidSnapshot) {
+		t.Fatalf("expected ErrInvalidSnapshot of unknown column, got %v", err)
+	}
+	if _, err := orderbook.Import(ctx, book, strings.NewReader("id,rate\n"), orderbook.SnapshotCSV); !errors.Is(err, orderbook.ErrInvalidSnapshot) {
+		t.Fatalf("expected ErrInvalidSnapshot without record column, got %v", err)
+	}
+}
+
+func TestRowErrorMarshalJSON(t *testing.T) {
+	result := orderbook.ImportResult{Errors: []orderbook.RowError{{Row: 2, Err: fmt.Errorf("order 1: %w", orderbook.ErrDuplicateOrder)}}}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		t.Fatalf("marshalling result: %v", err)
+	}
+	want := fmt.Sprintf(`{"pairs":0,"orders":0,"errors":[{"row":2,"error":"order 1: %s"}]}`, orderbook.ErrDuplicateOrder)
+	if string(data) != want {
+		t.Fatalf("expected %s, got %s", want, data)
+	}
+}
@@ -0,0 +1,50 @@
+package orderbook
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// OrderStatus is a lifecycle status of order
+type OrderStatus string
+
+const (
+	// OrderOpen order is added and not filled yet
+	OrderOpen OrderStatus = "open"
+	// OrderPartiallyFilled part of order volume is taken
+	OrderPartiallyFilled OrderStatus = "partially_filled"
+	// OrderFilled order is taken and closed, its rest is less than MinVolume
+	OrderFilled OrderStatus = "filled"
+	// OrderCancelled order is removed by RemoveOrder or RemovePair
+	OrderCancelled OrderStatus = "cancelled"
+	// OrderExpired order is removed by RemoveExpiredOrders
+	OrderExpired OrderStatus = "expired"
+)
+
+// Live checks that order with status is in the book and may be matched
+func (s OrderStatus) Live() bool {
+	return s == OrderOpen || s == OrderPartiallyFilled
+}
+
+// StatusChange is a record of order status history with order rate and volumes after the change
+type StatusChange struct {
+	OrderId   string          `json:"order_id" db:"order_id"`
+	Status    OrderStatus     `json:"status" db:"status"`
+	Rate      decimal.Decimal `json:"rate" db:"rate"`
+	MaxVolume decimal.Decimal `json:"max_volume" db:"max_volume"`
+	MinVolume decimal.Decimal `json:"min_volume" db:"min_volume"`
+	Time      time.Time       `json:"time" db:"time"`
+}
+
+// NewStatusChange returns record of order current status at time
+func NewStatusChange(order Order, at time.Time) StatusChange {
+	return StatusChange{
+		OrderId:   order.Id,
+		Status:    order.Status,
+		Rate:      order.Rate,
+		MaxVolume: order.MaxVolume,
+		MinVolume: order.MinVolume,
+		Time:      at,
+	}
+}
@@ -0,0 +1,39 @@
+package orderbook
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOrderStatusLive(t *testing.T) {
+	tests := []struct {
+		status OrderStatus
+		live   bool
+	}{
+		{OrderOpen, true},
+		{OrderPartiallyFilled, true},
+		{OrderFilled, false},
+		{OrderCancelled, false},
+		{OrderExpired, false},
+	}
+
+	for _, test := range tests {
+		if got := test.status.Live(); got != test.live {
+			t.Errorf("%s: expected live %v, got %v", test.status, test.live, got)
+		}
+	}
+}
+
+func TestNewStatusChange(t *testing.T) {
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	order := makerOrder("1", "2", "10", "1")
+	order.Status = OrderPartiallyFilled
+
+	change := NewStatusChange(order, at)
+	if change.OrderId != "1" || change.Status != OrderPartiallyFilled || !change.Time.Equal(at) {
+		t.Fatalf("unexpected status change %v", change)
+	}
+	requireDecimal(t, "rate", change.Rate, "2")
+	requireDecimal(t, "max volume", change.MaxVolume, "10")
+	requireDecimal(t, "min volume", change.MinVolume, "1")
+}
@@ -0,0 +1,52 @@
+package orderbook
+
+import (
+	"github.com/shopspring/decimal"
+)
+
+// InverseRate converting rate of one pair direction to rate of the other one, it is used for every such conversion
+func InverseRate(rate decimal.Decimal) decimal.Decimal {
+	return decimal.NewFromInt(1).Div(rate)
+}
+
+// TopOfBook is the best orders of both pair directions, rates and sizes are in TokenBid per TokenAsk and TokenAsk.
+// Bid is the best order TokenBid_TokenAsk, its maker buys TokenAsk for Rate of TokenBid.
+// Ask is the best order TokenAsk_TokenBid, its maker sells TokenAsk for InverseRate of Rate of TokenBid.
+type TopOfBook struct {
+	TokenBid string `json:"token_bid"`
+	TokenAsk string `json:"token_ask"`
+	// Bid is nil when there are no orders TokenBid_TokenAsk
+	Bid *Order `json:"bid,omitempty"`
+	// Ask is nil when there are no orders TokenAsk_TokenBid
+	Ask *Order `json:"ask,omitempty"`
+
+	BidRate decimal.Decimal `json:"bid_rate"`
+	AskRate decimal.Decimal `json:"ask_rate"`
+	// BidSize is MaxVolume of Bid
+	BidSize decimal.Decimal `json:"bid_size"`
+	// AskSize is MaxVolume of Ask converted to TokenAsk
+	AskSize decimal.Decimal `json:"ask_size"`
+	// Spread is AskRate minus BidRate, it is zero when any side is empty
+	Spread decimal.Decimal `json:"spread"`
+	// MidRate is an average of BidRate and AskRate, it is zero when any side is empty
+	MidRate decimal.Decimal `json:"mid_rate"`
+}
+
+// NewTopOfBook returns top of book of pair direction tokenBid_tokenAsk with the best orders of both directions, nil orders are empty sides
+func NewTopOfBook(tokenBid, tokenAsk string, bid, ask *Order) TopOfBook {
+	top := TopOfBook{TokenBid: tokenBid, TokenAsk: tokenAsk, Bid: bid, Ask: ask}
+	if bid != nil {
+		top.BidRate = bid.Rate
+		top.BidSize = bid.MaxVolume
+	}
+	if ask != nil {
+		top.AskRate = InverseRate(ask.Rate)
+		top.AskSize = ask.MaxVolume.Mul(ask.Rate)
+	}
+	if bid != nil && ask != nil {
+		top.Spread = top.AskRate.Sub(top.BidRate)
+		top.MidRate = top.AskRate.Add(top.BidRate).Div(decimal.NewFromInt(2))
+	}
+
+	return top
+}
@@ -0,0 +1,40 @@
+package orderbook
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestInverseRate(t *testing.T) {
+	requireDecimal(t, "inverse rate", InverseRate(decimal.NewFromInt(4)), "0.25")
+	requireDecimal(t, "inverse rate", InverseRate(decimal.RequireFromString("0.5")), "2")
+}
+
+func TestNewTopOfBook(t *testing.T) {
+	// bid maker gives 0.4 BTC for every ETH, ask maker gives 2 ETH for every BTC, so it sells ETH for 0.5 BTC
+	bid := takerOrder("0.4", "10")
+	ask := makerOrder("1", "2", "3", "1")
+
+	top := NewTopOfBook("BTC", "ETH", &bid, &ask)
+	if top.Bid.Id != bid.Id || top.Ask.Id != ask.Id {
+		t.Fatalf("expected orders of both sides, got %v", top)
+	}
+	requireDecimal(t, "bid rate", top.BidRate, "0.4")
+	requireDecimal(t, "bid size", top.BidSize, "10")
+	requireDecimal(t, "ask rate", top.AskRate, "0.5")
+	requireDecimal(t, "ask size", top.AskSize, "6")
+	requireDecimal(t, "spread", top.Spread, "0.1")
+	requireDecimal(t, "mid rate", top.MidRate, "0.45")
+
+	top = NewTopOfBook("BTC", "ETH", &bid, nil)
+	if top.Ask != nil || !top.AskRate.IsZero() || !top.Spread.IsZero() || !top.MidRate.IsZero() {
+		t.Fatalf("expected empty ask side without spread, got %v", top)
+	}
+	requireDecimal(t, "bid rate", top.BidRate, "0.4")
+
+	top = NewTopOfBook("BTC", "ETH", nil, nil)
+	if top.Bid != nil || top.Ask != nil || !top.BidRate.IsZero() || !top.Spread.IsZero() {
+		t.Fatalf("expected empty top of book, got %v", top)
+	}
+}
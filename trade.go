@@ -0,0 +1,51 @@
+package orderbook
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Trade is a record of maker order fill, it is kept after the order and its pair are removed
+type Trade struct {
+	// Id is assigned by orderbook in order of trades
+	Id           int64  `json:"id" db:"id"`
+	MakerOrderId string `json:"maker_order_id" db:"maker_order_id"`
+	MakerId      string `json:"maker_id" db:"maker_id"`
+	// TakerOrderId and TakerId are empty when maker order is filled by FillOrder
+	TakerOrderId string `json:"taker_order_id,omitempty" db:"taker_order_id"`
+	TakerId      string `json:"taker_id,omitempty" db:"taker_id"`
+	// TokenBid and TokenAsk are tokens of maker order
+	TokenBid string          `json:"token_bid" db:"token_bid"`
+	TokenAsk string          `json:"token_ask" db:"token_ask"`
+	Rate     decimal.Decimal `json:"rate" db:"rate"`
+	// Volume is taken volume in maker TokenAsk
+	Volume decimal.Decimal `json:"volume" db:"volume"`
+	Time   time.Time       `json:"time" db:"time"`
+}
+
+// NewTrade returns trade of fill at time, taker is zero Order for fills without taker
+func NewTrade(taker Order, fill Fill, at time.Time) Trade {
+	return Trade{
+		MakerOrderId: fill.Order.Id,
+		MakerId:      fill.Order.MakerId,
+		TakerOrderId: taker.Id,
+		TakerId:      taker.MakerId,
+		TokenBid:     fill.Order.TokenBid,
+		TokenAsk:     fill.Order.TokenAsk,
+		Rate:         fill.Order.Rate,
+		Volume:       fill.Volume,
+		Time:         at,
+	}
+}
+
+// TimeRange is a filter of trades by time, From is inclusive and To is exclusive, zero bounds are open
+type TimeRange struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// Contains checks that t is in range
+func (r TimeRange) Contains(t time.Time) bool {
+	return (r.From.IsZero() || !t.Before(r.From)) && (r.To.IsZero() || t.Before(r.To))
+}
@@ -0,0 +1,56 @@
+package orderbook
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestNewTrade(t *testing.T) {
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	maker := makerOrder("1", "2", "10", "1")
+	fill, err := maker.Take(decimal.NewFromInt(4))
+	if err != nil {
+		t.Fatalf("taking volume: %v", err)
+	}
+
+	trade := NewTrade(takerOrder("0.5", "8"), fill, at)
+	if trade.MakerOrderId != "1" || trade.MakerId != "maker1" || trade.TakerOrderId != "taker" || trade.TakerId != "taker" {
+		t.Fatalf("unexpected parties of trade %v", trade)
+	}
+	if trade.TokenBid != "ETH" || trade.TokenAsk != "BTC" || !trade.Time.Equal(at) {
+		t.Fatalf("expected trade of maker tokens at time, got %v", trade)
+	}
+	requireDecimal(t, "rate", trade.Rate, "2")
+	requireDecimal(t, "volume", trade.Volume, "4")
+
+	// fills without taker have no taker
+	if trade := NewTrade(Order{}, fill, at); trade.TakerOrderId != "" || trade.TakerId != "" {
+		t.Fatalf("expected trade without taker, got %v", trade)
+	}
+}
+
+func TestTimeRangeContains(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(time.Hour)
+
+	tests := []struct {
+		name     string
+		period   TimeRange
+		t        time.Time
+		contains bool
+	}{
+		{"open range", TimeRange{}, from, true},
+		{"at from", TimeRange{From: from, To: to}, from, true},
+		{"before from", TimeRange{From: from}, from.Add(-time.Second), false},
+		{"at to", TimeRange{From: from, To: to}, to, false},
+		{"before to", TimeRange{To: to}, to.Add(-time.Second), true},
+	}
+
+	for _, test := range tests {
+		if got := test.period.Contains(test.t); got != test.contains {
+			t.Errorf("%s: expected contains %v, got %v", test.name, test.contains, got)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package orderbook
+
+import (
+	"strings"
+)
+
+// FieldError is an error of a single order field
+type FieldError struct {
+	// Field is a json name of order field
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+}
+
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Reason
+}
+
+// ValidationError is a list of order field errors, errors.Is(err, ErrInvalidOrder) is true for it
+type ValidationError struct {
+	Fields []FieldError `json:"fields"`
+}
+
+func (e *ValidationError) Error() string {
+	reasons := make([]string, 0, len(e.Fields))
+	for _, field := range e.Fields {
+		reasons = append(reasons, field.Error())
+	}
+
+	return ErrInvalidOrder.Error() + ": " + strings.Join(reasons, "; ")
+}
+
+func (e *ValidationError) Unwrap() error {
+	return ErrInvalidOrder
+}
+
+// Rule checks order and returns errors of invalid fields
+type Rule func(order Order) []FieldError
+
+// Validator checks orders with set of rules, OrderBook implementations run it before persisting orders
+type Validator struct {
+	rules []Rule
+}
+
+// DefaultRules are rules of DefaultValidator
+var DefaultRules = []Rule{RequireIds, RequireDistinctTokens, RequirePositiveRate, RequireValidVolumes}
+
+// DefaultValidator is a validator used by Order.Validate and OrderBook implementations by default
+var DefaultValidator = NewValidator(DefaultRules...)
+
+func NewValidator(rules ...Rule) *Validator {
+	return &Validator{rules: rules}
+}
+
+// Validate checking order with every rule, returns *ValidationError with all invalid fields
+func (v *Validator) Validate(order Order) error {
+	fields := make([]FieldError, 0)
+	for _, rule := range v.rules {
+		fields = append(fields, rule(order)...)
+	}
+
+	if len(fields) == 0 {
+		return nil
+	}
+
+	return &ValidationError{Fields: fields}
+}
+
+// Validate checking order with DefaultValidator
+func (o Order) Validate() error {
+	return DefaultValidator.Validate(o)
+}
+
+// RequireIds checks that order id and maker id are not empty
+func RequireIds(order Order) []FieldError {
+	fields := make([]FieldError, 0)
+	if order.Id == "" {
+		fields = append(fields, FieldError{Field: "id", Reason: "must not be empty"})
+	}
+	if order.MakerId == "" {
+		fields = append(fields, FieldError{Field: "maker_id", Reason: "must not be empty"})
+	}
+
+	return fields
+}
+
+// RequireDistinctTokens checks that order tokens are not empty and not equal
+func RequireDistinctTokens(order Order) []FieldError {
+	fields := make([]FieldError, 0)
+	if order.TokenBid == "" {
+		fields = append(fields, FieldError{Field: "token_bid", Reason: "must not be empty"})
+	}
+	if order.TokenAsk == "" {
+		fields = append(fields, FieldError{Field: "token_ask", Reason: "must not be empty"})
+	}
+	if order.TokenBid != "" && order.TokenBid == order.TokenAsk {
+		fields = append(fields, FieldError{Field: "token_ask", Reason: "must differ from token_bid"})
+	}
+
+	return fields
+}
+
+// RequirePositiveRate checks that order rate is greater than zero
+func RequirePositiveRate(order Order) []FieldError {
+	if !order.Rate.IsPositive() {
+		return []FieldError{{Field: "rate", Reason: "must be positive"}}
+	}
+
+	return nil
+}
+
+// RequireValidVolumes checks that max volume is positive and min volume is from zero to max volume
+func RequireValidVolumes(order Order) []FieldError {
+	fields := make([]FieldError, 0)
+	if !order.MaxVolume.IsPositive() {
+		fields = append(fields, FieldError{Field: "max_volume", Reason: "must be positive"})
+	}
+	if order.MinVolume.IsNegative() {
+		fields = append(fields, FieldError{Field: "min_volume", Reason: "must not be negative"})
+	}
+	if order.MinVolume.GreaterThan(order.MaxVolume) {
+		fields = append(fields, FieldError{Field: "min_volume", Reason: "must not be greater than max_volume"})
+	}
+
+	return fields
+}
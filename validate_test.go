@@ -0,0 +1,86 @@
+package orderbook
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func validOrder() Order {
+	return Order{
+		Id:        "1",
+		MakerId:   "maker",
+		TokenBid:  "BTC",
+		TokenAsk:  "ETH",
+		Rate:      decimal.NewFromInt(2),
+		MaxVolume: decimal.NewFromInt(10),
+		MinVolume: decimal.NewFromInt(1),
+	}
+}
+
+func TestValidatorReportsEveryInvalidField(t *testing.T) {
+	order := validOrder()
+	order.Id = ""
+	order.TokenAsk = order.TokenBid
+	order.Rate = decimal.Zero
+	order.MinVolume = decimal.NewFromInt(11)
+
+	err := order.Validate()
+	if !errors.Is(err, ErrInvalidOrder) {
+		t.Fatalf("expected ErrInvalidOrder, got %v", err)
+	}
+
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("expected *ValidationError, got %T", err)
+	}
+
+	fields := make(map[string]bool)
+	for _, field := range validationErr.Fields {
+		fields[field.Field] = true
+	}
+	for _, field := range []string{"id", "token_ask", "rate", "min_volume"} {
+		if !fields[field] {
+			t.Errorf("expected error of field %s, got %v", field, validationErr.Fields)
+		}
+	}
+}
+
+func TestValidatorAcceptsValidOrder(t *testing.T) {
+	if err := validOrder().Validate(); err != nil {
+		t.Fatalf("expected valid order, got %v", err)
+	}
+}
+
+func TestCustomRules(t *testing.T) {
+	requireMaker := func(order Order) []FieldError {
+		if order.MakerId != "trusted" {
+			return []FieldError{{Field: "maker_id", Reason: "must be trusted"}}
+		}
+		return nil
+	}
+	validator := NewValidator(append(DefaultRules, requireMaker)...)
+
+	if err := validator.Validate(validOrder()); !errors.Is(err, ErrInvalidOrder) {
+		t.Fatalf("expected ErrInvalidOrder, got %v", err)
+	}
+
+	order := validOrder()
+	order.MakerId = "trusted"
+	if err := validator.Validate(order); err != nil {
+		t.Fatalf("expected valid order, got %v", err)
+	}
+}
+
+func TestWithValidatorIgnoresNil(t *testing.T) {
+	options := NewOptions(WithValidator(nil))
+	if options.Validator != DefaultValidator {
+		t.Fatalf("expected DefaultValidator, got %v", options.Validator)
+	}
+
+	validator := NewValidator()
+	if options := NewOptions(WithValidator(validator)); options.Validator != validator {
+		t.Fatalf("expected custom validator, got %v", options.Validator)
+	}
+}